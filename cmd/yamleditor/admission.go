@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glesirok/yamleditor/pkg/processor"
+)
+
+// admissionReview AdmissionReview，admission.k8s.io/v1 与 v1beta1 结构相同，只解析用到的字段
+type admissionReview struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Request    *admissionRequest  `json:"request,omitempty"`
+	Response   *admissionResponse `json:"response,omitempty"`
+}
+
+type admissionRequest struct {
+	UID  string `json:"uid"`
+	Kind struct {
+		Group   string `json:"group"`
+		Version string `json:"version"`
+		Kind    string `json:"kind"`
+	} `json:"kind"`
+	Namespace string          `json:"namespace,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Operation string          `json:"operation"`
+	Object    json.RawMessage `json:"object,omitempty"` // DELETE 时为空
+}
+
+type admissionResponse struct {
+	UID       string           `json:"uid"`
+	Allowed   bool             `json:"allowed"`
+	Status    *admissionStatus `json:"status,omitempty"`    // 拒绝的原因
+	PatchType string           `json:"patchType,omitempty"` // 有修改时为 JSONPatch
+	Patch     []byte           `json:"patch,omitempty"`     // JSON Patch（RFC 6902），编码为 base64
+	Warnings  []string         `json:"warnings,omitempty"`  // 返回给客户端（kubectl 等）显示的警告
+}
+
+type admissionStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// patchOp JSON Patch 的一个操作，remove 没有 value
+type patchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// admissionHandler 以规则文件修改准入请求中的对象，返回 JSON Patch
+// Engine 保存了当前文档的状态，所有请求共用一个 Processor 并逐个执行
+type admissionHandler struct {
+	mu   sync.Mutex
+	proc *processor.Processor
+}
+
+// newAdmissionHandler 加载 -c 指定的规则文件与参数；规则由部署者提供，可以使用 value_from 与环境变量
+func newAdmissionHandler() (*admissionHandler, error) {
+	proc, err := processor.NewProcessor(ruleFile)
+	if err != nil {
+		return nil, fmt.Errorf("create processor: %w", err)
+	}
+	values, err := loadParams()
+	if err != nil {
+		return nil, err
+	}
+	if err := proc.SetParams(values); err != nil {
+		return nil, err
+	}
+	proc.SetEncoder(processor.NewJSONEncoder(0))
+	proc.SetOptions(processor.Options{
+		RecordChanges: true,
+		RegexTimeout:  serveRegexTimeout,
+		RuleTimeout:   serveRuleTimeout,
+	})
+	return &admissionHandler{proc: proc}, nil
+}
+
+// ServeHTTP 处理 POST /mutate；对象经规则修改后与原对象比较得到 JSON Patch，执行规则失败时拒绝请求
+// 请求体不是 AdmissionReview 时返回 400，API server 按 webhook 的 failurePolicy 处理
+func (h *admissionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	var review admissionReview
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, serveMaxBody)).Decode(&review); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeJSON(w, status, errorResponse{Error: fmt.Sprintf("decode admission review: %v", err)})
+		return
+	}
+	if review.Request == nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "admission review has no request"})
+		return
+	}
+
+	req := review.Request
+	name := admissionName(req)
+	resp, changes, err := h.review(req, name)
+	switch {
+	case err != nil:
+		log.Printf("mutate %s %s: denied: %v", req.Kind.Kind, name, err)
+	case len(resp.Patch) > 0:
+		log.Printf("mutate %s %s: %d change(s) in %s", req.Kind.Kind, name, changes, time.Since(start).Round(time.Millisecond))
+	}
+
+	apiVersion := review.APIVersion
+	if apiVersion == "" {
+		apiVersion = "admission.k8s.io/v1"
+	}
+	writeJSON(w, http.StatusOK, admissionReview{APIVersion: apiVersion, Kind: "AdmissionReview", Response: resp})
+}
+
+// review 对请求中的对象应用规则，返回响应与修改的节点数；出错时响应为拒绝，同时返回错误供日志使用
+func (h *admissionHandler) review(req *admissionRequest, name string) (*admissionResponse, int, error) {
+	resp := &admissionResponse{UID: req.UID, Allowed: true}
+	if len(req.Object) == 0 || string(req.Object) == "null" {
+		return resp, 0, nil
+	}
+
+	deny := func(code int, err error) (*admissionResponse, int, error) {
+		resp.Allowed = false
+		resp.Status = &admissionStatus{Code: code, Message: fmt.Sprintf("yamleditor: %v", err)}
+		return resp, 0, err
+	}
+
+	h.mu.Lock()
+	output, result, err := h.proc.Eval(name, req.Object)
+	h.mu.Unlock()
+	if err != nil {
+		return deny(http.StatusUnprocessableEntity, err)
+	}
+	resp.Warnings = result.Warnings
+
+	ops, err := jsonPatch(req.Object, output)
+	if err != nil {
+		return deny(http.StatusInternalServerError, err)
+	}
+	if len(ops) > 0 {
+		if resp.Patch, err = json.Marshal(ops); err != nil {
+			return deny(http.StatusInternalServerError, err)
+		}
+		resp.PatchType = "JSONPatch"
+	}
+	return resp, len(result.Changes), nil
+}
+
+// admissionName 对象在规则中的文件名（files 匹配与模板中的 .File）：命名空间内的对象为 命名空间/名称，
+// 集群级对象只有名称；CREATE 时名称尚未生成则使用 generateName
+func admissionName(req *admissionRequest) string {
+	name := req.Name
+	if name == "" {
+		var obj struct {
+			Metadata struct {
+				Name         string `json:"name"`
+				GenerateName string `json:"generateName"`
+			} `json:"metadata"`
+		}
+		_ = json.Unmarshal(req.Object, &obj)
+		if name = obj.Metadata.Name; name == "" {
+			name = obj.Metadata.GenerateName
+		}
+	}
+	if req.Namespace != "" {
+		return req.Namespace + "/" + name
+	}
+	return name
+}
+
+// jsonPatch 比较两个 JSON 文档，返回把 from 变为 to 的 JSON Patch 操作，内容相同时返回空列表
+func jsonPatch(from, to []byte) ([]patchOp, error) {
+	a, err := decodeJSONValue(from)
+	if err != nil {
+		return nil, fmt.Errorf("decode object: %w", err)
+	}
+	b, err := decodeJSONValue(to)
+	if err != nil {
+		return nil, fmt.Errorf("decode mutated object: %w", err)
+	}
+	return diffJSON(nil, "", a, b), nil
+}
+
+// decodeJSONValue 解码单个 JSON 值，数字保留原文以免精度损失
+func decodeJSONValue(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("expected a single JSON value")
+	}
+	return v, nil
+}
+
+// diffJSON 递归比较 a 与 b，把操作追加到 ops：对象逐键比较；数组逐个比较共同部分，
+// 多出的元素追加，少掉的元素从末尾删除；其他情况整体替换
+func diffJSON(ops []patchOp, p string, a, b interface{}) []patchOp {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		if b, ok := b.(map[string]interface{}); ok {
+			for _, k := range sortedKeys(a) {
+				if v, ok := b[k]; ok {
+					ops = diffJSON(ops, p+"/"+escapePointer(k), a[k], v)
+				} else {
+					ops = append(ops, patchOp{Op: "remove", Path: p + "/" + escapePointer(k)})
+				}
+			}
+			for _, k := range sortedKeys(b) {
+				if _, ok := a[k]; !ok {
+					ops = append(ops, patchOp{Op: "add", Path: p + "/" + escapePointer(k), Value: rawJSON(b[k])})
+				}
+			}
+			return ops
+		}
+	case []interface{}:
+		if b, ok := b.([]interface{}); ok {
+			for i := 0; i < len(a) && i < len(b); i++ {
+				ops = diffJSON(ops, p+"/"+strconv.Itoa(i), a[i], b[i])
+			}
+			for i := len(a); i < len(b); i++ {
+				ops = append(ops, patchOp{Op: "add", Path: p + "/" + strconv.Itoa(i), Value: rawJSON(b[i])})
+			}
+			for i := len(a) - 1; i >= len(b); i-- {
+				ops = append(ops, patchOp{Op: "remove", Path: p + "/" + strconv.Itoa(i)})
+			}
+			return ops
+		}
+	}
+	if !bytes.Equal(rawJSON(a), rawJSON(b)) {
+		ops = append(ops, patchOp{Op: "replace", Path: p, Value: rawJSON(b)})
+	}
+	return ops
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// rawJSON 编码解码得到的值；这些值总能编码成功
+func rawJSON(v interface{}) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+// escapePointer 按 JSON Pointer（RFC 6901）转义键名中的 ~ 与 /
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
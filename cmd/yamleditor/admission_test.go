@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// jsonPatch 生成的操作按顺序应用到 from 上得到 to：数组缩短时从末尾删除，
+// 键名按 RFC 6901 转义，数字保留原文
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     string
+	}{
+		{"unchanged", `{"a": [1, 2], "b": {"c": "x"}}`, `{"b": {"c": "x"}, "a": [1, 2]}`, `[]`},
+		{"array shrink removes from the end",
+			`{"a": [1, 2, 3, 4]}`, `{"a": [1, 5]}`,
+			`[{"op":"replace","path":"/a/1","value":5},{"op":"remove","path":"/a/3"},{"op":"remove","path":"/a/2"}]`},
+		{"array grow appends",
+			`{"a": [1]}`, `{"a": [1, {"b": 2}, 3]}`,
+			`[{"op":"add","path":"/a/1","value":{"b":2}},{"op":"add","path":"/a/2","value":3}]`},
+		{"escape ~ and /",
+			`{"metadata": {"annotations": {"example.com/a~b": "1", "x": "y"}}}`,
+			`{"metadata": {"annotations": {"example.com/a~b": "2", "k8s.io/new": "z"}}}`,
+			`[{"op":"replace","path":"/metadata/annotations/example.com~1a~0b","value":"2"},{"op":"remove","path":"/metadata/annotations/x"},{"op":"add","path":"/metadata/annotations/k8s.io~1new","value":"z"}]`},
+		{"large integers keep precision",
+			`{"n": 12345678901234567890, "m": 9007199254740993}`,
+			`{"n": 12345678901234567891, "m": 9007199254740993}`,
+			`[{"op":"replace","path":"/n","value":12345678901234567891}]`},
+		{"number spelling is a change", `{"n": 1}`, `{"n": 1.0}`, `[{"op":"replace","path":"/n","value":1.0}]`},
+		{"type change replaces", `{"a": [1]}`, `{"a": {"0": 1}}`, `[{"op":"replace","path":"/a","value":{"0":1}}]`},
+		{"root replaced", `[1]`, `"x"`, `[{"op":"replace","path":"","value":"x"}]`},
+	}
+	for _, tt := range tests {
+		ops, err := jsonPatch([]byte(tt.from), []byte(tt.to))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if ops == nil {
+			ops = []patchOp{}
+		}
+		got, err := json.Marshal(ops)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s:\ngot  %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestJSONPatchInvalid(t *testing.T) {
+	for _, tt := range []struct{ from, to string }{
+		{`{`, `{}`},
+		{`{}`, `{} {}`},
+	} {
+		if _, err := jsonPatch([]byte(tt.from), []byte(tt.to)); err == nil {
+			t.Errorf("jsonPatch(%s, %s): expected error", tt.from, tt.to)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/glesirok/yamleditor/pkg/processor"
+)
+
+// exitDrift --check 发现文件需要修改时的退出码，与处理失败（1）区分
+const exitDrift = 3
+
+// exitError 携带退出码的错误
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// drifted --check 时内容会被规则修改的文件
+var drifted []string
+
+// recordDrift 记录 --check 时内容会被修改的文件
+func recordDrift(f processor.FileReport) {
+	if check && f.Error == nil && f.Status == processor.StatusUpdated {
+		drifted = append(drifted, f.Path)
+	}
+}
+
+// checkDrift 列出会被修改的文件，有这类文件时返回退出码为 exitDrift 的错误
+// 设置了 --diff-format 时 stdout 是差异内容，列表写到 stderr
+func checkDrift() error {
+	out := os.Stdout
+	if diffFormat != "" {
+		out = os.Stderr
+	}
+	if len(drifted) == 0 {
+		if !quiet {
+			fmt.Fprintln(out, "✓ 所有文件已符合规则")
+		}
+		return nil
+	}
+	if !quiet {
+		fmt.Fprintf(out, "需要修改的文件: %d\n", len(drifted))
+		for _, f := range drifted {
+			fmt.Fprintf(out, "  ✗ %s\n", f)
+		}
+	}
+	return &exitError{code: exitDrift, err: fmt.Errorf("%d file(s) would be modified by the rules", len(drifted))}
+}
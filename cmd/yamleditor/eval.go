@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/glesirok/yamleditor/pkg/processor"
+	"github.com/glesirok/yamleditor/pkg/rule"
+)
+
+var (
+	evalRule   string
+	evalDoc    string
+	evalParams []string
+)
+
+// newEvalCmd eval 子命令：对内联给出的文档应用内联规则并输出结果，不读写文件
+func newEvalCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "eval",
+		Short: "Apply inline rules to an inline document and print the result",
+		Example: `  yamleditor eval --rule '{action: replace, path: spec.replicas, value: 3}' --doc 'spec: {replicas: 1}'
+  yamleditor eval --rule "$(cat rules.yaml)" <<'EOF'
+  spec:
+    replicas: 1
+  EOF`,
+		Args: cobra.NoArgs,
+		RunE: runEval,
+	}
+
+	cmd.Flags().StringVar(&evalRule, "rule", "", "Inline rules: a single rule, a list of rules or a full rule file (required)")
+	cmd.Flags().StringVar(&evalDoc, "doc", "-", "Inline YAML document, - reads from stdin")
+	cmd.Flags().StringVar(&outputFormat, "output-format", processor.FormatPreserve, "Output format: preserve, k8s or json")
+	cmd.Flags().StringArrayVar(&evalParams, "param", nil, "Rule parameter as name=value (repeatable)")
+	cmd.MarkFlagRequired("rule")
+	return cmd
+}
+
+func runEval(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	config, err := rule.ParseInline([]byte(evalRule))
+	if err != nil {
+		return fmt.Errorf("parse --rule: %w", err)
+	}
+	proc := processor.NewProcessorFromConfig(config)
+	proc.SetWarnings(os.Stderr)
+
+	enc, err := processor.NewEncoder(outputFormat)
+	if err != nil {
+		return err
+	}
+	proc.SetEncoder(enc)
+
+	values := map[string]string{}
+	if err := parseParams(evalParams, values); err != nil {
+		return err
+	}
+	if err := proc.SetParams(values); err != nil {
+		return err
+	}
+
+	doc := []byte(evalDoc)
+	name := "<doc>"
+	if evalDoc == "-" {
+		if doc, err = io.ReadAll(os.Stdin); err != nil {
+			return fmt.Errorf("read stdin: %w", err)
+		}
+		name = "<stdin>"
+	}
+
+	output, _, err := proc.Eval(name, doc)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(output)
+	return err
+}
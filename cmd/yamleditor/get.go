@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/engine"
+	"github.com/glesirok/yamleditor/pkg/processor"
+)
+
+// get 的输出格式
+const (
+	getRaw  = "raw"  // 标量输出原值，集合输出 YAML
+	getYAML = "yaml" // 每个匹配一个 YAML 文档
+	getJSON = "json" // JSON 数组，每项带文件、文档序号与具体路径
+)
+
+var (
+	getOutput   string
+	getSelect   string
+	getWithPath bool
+)
+
+// newGetCmd get 子命令：按路径表达式查询输入文件并输出匹配到的值，不修改任何文件
+func newGetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get <path> [file|dir|-]...",
+		Short: "Print the values a path expression matches in the input files",
+		Example: `  yamleditor get 'spec.template.spec.containers[*].image' deploy.yaml
+  yamleditor get '..image' ./manifests/ --with-path
+  yamleditor get 'metadata.labels' ./manifests/ --select kind=Deployment -o json
+  kubectl get deploy web -o yaml | yamleditor get spec.replicas`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runGet,
+	}
+	cmd.Flags().StringVarP(&getOutput, "output", "o", getRaw, "Output format: raw (scalars as plain values, collections as YAML), yaml or json")
+	cmd.Flags().StringVar(&getSelect, "select", "", "Only query documents matching this selector, e.g. kind=Deployment,name=web")
+	cmd.Flags().BoolVar(&getWithPath, "with-path", false, "Prefix every value with its file, document index and concrete path (raw and yaml)")
+	return cmd
+}
+
+func runGet(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	switch getOutput {
+	case getRaw, getYAML, getJSON:
+	default:
+		return fmt.Errorf("unknown --output %q, expected raw, yaml or json", getOutput)
+	}
+	var sel *engine.Selector
+	if getSelect != "" {
+		var err error
+		if sel, err = engine.ParseSelector(getSelect); err != nil {
+			return fmt.Errorf("invalid --select: %w", err)
+		}
+	}
+
+	expr, inputs := args[0], args[1:]
+	if len(inputs) == 0 {
+		inputs = []string{stdio}
+	}
+	files, err := getFiles(inputs)
+	if err != nil {
+		return err
+	}
+
+	var matches []processor.QueryMatch
+	var misses []string
+	for _, file := range files {
+		var data []byte
+		name := filepath.ToSlash(file)
+		if file == stdio {
+			data, err = io.ReadAll(os.Stdin)
+			name = "<stdin>"
+		} else {
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			return fmt.Errorf("read %s: %w", file, err)
+		}
+		result, err := processor.Query(name, data, expr, sel)
+		if err != nil {
+			return err
+		}
+		matches = append(matches, result.Matches...)
+		for _, m := range result.Misses {
+			misses = append(misses, name+": "+m)
+		}
+	}
+
+	if err := printMatches(matches); err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		for _, m := range misses {
+			fmt.Fprintf(os.Stderr, "  %s\n", m)
+		}
+		return &exitError{code: 1, err: fmt.Errorf("no nodes matched %s", expr)}
+	}
+	return nil
+}
+
+// getFiles 展开输入：目录递归取其中的 .yaml、.yml 与 .json 文件，按路径排序
+func getFiles(inputs []string) ([]string, error) {
+	var files []string
+	for _, in := range inputs {
+		if in == stdio {
+			files = append(files, in)
+			continue
+		}
+		info, err := os.Stat(in)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, in)
+			continue
+		}
+		err = filepath.WalkDir(in, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			switch strings.ToLower(filepath.Ext(p)) {
+			case ".yaml", ".yml", ".json":
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// printMatches 按 --output 输出匹配到的节点
+func printMatches(matches []processor.QueryMatch) error {
+	if getOutput == getJSON {
+		type item struct {
+			File  string      `json:"file"`
+			Doc   int         `json:"doc"`
+			Path  string      `json:"path"`
+			Value interface{} `json:"value"`
+		}
+		items := []item{}
+		for _, m := range matches {
+			v, err := processor.QueryValue(m.Node)
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", m.File, m.Path, err)
+			}
+			items = append(items, item{File: m.File, Doc: m.Doc, Path: m.Path, Value: v})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+
+	for i, m := range matches {
+		location := fmt.Sprintf("%s:%d:%s", m.File, m.Doc, m.Path)
+		if getOutput == getYAML {
+			if i > 0 {
+				fmt.Println("---")
+			}
+			if getWithPath {
+				fmt.Printf("# %s\n", location)
+			}
+		}
+
+		if getOutput == getRaw && m.Node.Kind == yaml.ScalarNode {
+			if getWithPath {
+				fmt.Printf("%s: %s\n", location, m.Node.Value)
+			} else {
+				fmt.Println(m.Node.Value)
+			}
+			continue
+		}
+
+		out, err := processor.QueryYAML(m.Node)
+		if err != nil {
+			return fmt.Errorf("%s: %w", location, err)
+		}
+		if getOutput == getRaw && getWithPath {
+			fmt.Printf("%s:\n", location)
+			out = []byte("  " + strings.ReplaceAll(strings.TrimSuffix(string(out), "\n"), "\n", "\n  ") + "\n")
+		}
+		os.Stdout.Write(out)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/glesirok/yamleditor/pkg/processor"
+)
+
+// changedFiles 返回 git 工作区中 dir 之下有改动的文件：相对 HEAD 已暂存或未暂存的修改，以及未跟踪（且未被忽略）的文件；
+// since 非空时改为与 since 和 HEAD 的分叉点比较，同时包括此后的提交。已删除的文件不在其中，返回的路径以 dir 开头
+func changedFiles(dir, since string) ([]string, error) {
+	base := "HEAD"
+	if since != "" {
+		out, err := git(dir, "merge-base", since, "HEAD")
+		if err != nil {
+			return nil, err
+		}
+		base = strings.TrimSpace(string(out))
+	}
+
+	diff, err := git(dir, "diff", "--name-only", "-z", "--relative", "--diff-filter=d", base, "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git(dir, "ls-files", "--others", "--exclude-standard", "-z", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := processor.ReadFileList(bytes.NewReader(append(diff, untracked...)))
+	if err != nil {
+		return nil, err
+	}
+	for i, f := range files {
+		files[i] = filepath.Join(dir, f)
+	}
+	return files, nil
+}
+
+// git 在 dir 中执行 git 命令并返回 stdout，失败时错误中带上 git 的输出
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// processChanged 只处理 git 中有改动的文件，结果与报告同文件列表模式
+func processChanged(proc *processor.Processor) error {
+	files, err := changedFiles(input, since)
+	if err != nil {
+		return fmt.Errorf("list changed files: %w", err)
+	}
+	result, err := proc.ProcessFiles(files, "", dryRun, backup)
+	if err != nil {
+		return err
+	}
+	return reportBatch(result)
+}
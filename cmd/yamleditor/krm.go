@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/glesirok/yamleditor/pkg/processor"
+	"github.com/glesirok/yamleditor/pkg/rule"
+)
+
+// newKRMCmd krm 子命令：作为 KRM 函数（kustomize、kpt）运行，从 stdin 读取 ResourceList，
+// 以 functionConfig 为规则修改 items，把 ResourceList 写到 stdout
+func newKRMCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "krm",
+		Short: "Run as a KRM function: apply the rules in functionConfig to the items of a ResourceList read from stdin",
+		Example: `  kpt fn eval ./manifests --exec "yamleditor krm" --fn-config rules-fn.yaml
+  kustomize fn run ./manifests --enable-exec --exec-path ./yamleditor-krm.sh`,
+		Args: cobra.NoArgs,
+		RunE: runKRM,
+	}
+}
+
+// runKRM 出错时仍把带 results 的 ResourceList 写到 stdout，并以非零状态退出
+func runKRM(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("read stdin: %w", err)
+	}
+	list, err := processor.ReadResourceList(data)
+	if err != nil {
+		return err
+	}
+
+	proc, err := krmProcessor(list)
+	if err != nil {
+		list.Results = append(list.Results, processor.KRMResult{Message: err.Error(), Severity: "error"})
+		return writeResourceList(list, err)
+	}
+	if failed := proc.TransformResourceList(list); failed > 0 {
+		return writeResourceList(list, fmt.Errorf("%d resource(s) failed", failed))
+	}
+	return writeResourceList(list, nil)
+}
+
+// krmProcessor 由 functionConfig 创建处理器
+func krmProcessor(list *processor.ResourceList) (*processor.Processor, error) {
+	config, values, err := rule.ParseFunctionConfig(list.FunctionConfig)
+	if err != nil {
+		return nil, err
+	}
+	proc := processor.NewProcessorFromConfig(config)
+	if err := proc.SetParams(values); err != nil {
+		return nil, err
+	}
+	return proc, nil
+}
+
+// writeResourceList 写出 ResourceList 并返回 result
+func writeResourceList(list *processor.ResourceList, result error) error {
+	out, err := list.Encode()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stdout.Write(out); err != nil {
+		return err
+	}
+	return result
+}
@@ -1,11 +1,21 @@
 package main
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/glesirok/yamleditor/pkg/engine"
+	"github.com/glesirok/yamleditor/pkg/path"
 	"github.com/glesirok/yamleditor/pkg/processor"
+	"github.com/glesirok/yamleditor/pkg/rule"
+	"github.com/glesirok/yamleditor/pkg/schema"
 )
 
 var (
@@ -13,7 +23,53 @@ var (
 	input    string
 	output   string
 	dryRun   bool
+	check    bool
 	backup   bool
+	keyFile  string
+
+	compareOutput bool
+	cacheFile     string
+	journalDir    string
+	params        []string
+	varFiles      []string
+	envFiles      []string
+	eventsOut     string
+	reportOut     string
+	strict        bool
+	strictTypes   bool
+	skipMissing   bool
+	pruneEmpty    bool
+	onlyPrefix    string
+	selectDocs    string
+	extractDocs   bool
+	failOnUnused  bool
+	inPlace       bool
+	yes           bool
+	backupFormat  string
+	failFast      bool
+	nonAtomic     bool
+	outputFormat  string
+	inputFormat   string
+	jsonIndent    int
+	yamlIndent    int
+	normalize     bool
+	diffFormat    string
+	duplicateKeys string
+	regexTimeout  time.Duration
+	ruleTimeout   time.Duration
+	quiet         bool
+	verbose       bool
+	summaryOnly   bool
+	inputFrom     string
+	maxFiles      int
+	maxPerFile    int
+	includes      []string
+	templated     bool
+	changedOnly   bool
+	validateOut   bool
+	schemaDir     string
+	since         string
+	excludes      []string
 )
 
 func main() {
@@ -26,93 +82,744 @@ It supports path-based operations like replace, set, delete, and regex_replace.`
 	}
 
 	rootCmd.Flags().StringVarP(&ruleFile, "config", "c", "", "Rule configuration file (required)")
-	rootCmd.Flags().StringVarP(&input, "input", "i", "", "Input file or directory (required)")
-	rootCmd.Flags().StringVarP(&output, "output", "o", "", "Output file/directory (optional, defaults to in-place)")
+	rootCmd.Flags().StringVarP(&input, "input", "i", "", "Input file or directory, - reads YAML from stdin and writes the result to stdout (required unless --input-from)")
+	rootCmd.Flags().StringVar(&inputFrom, "input-from", "", "Read the list of input files (newline- or NUL-delimited) from this file, - for stdin")
+	rootCmd.Flags().BoolVar(&changedOnly, "changed-only", false, "Only process files under -i that git reports as modified or untracked in the working tree")
+	rootCmd.Flags().StringVar(&since, "since", "", "With --changed-only, also include files changed in commits since the merge base with this revision, e.g. origin/main (implies --changed-only)")
+	rootCmd.Flags().StringVarP(&output, "output", "o", "", "Output file/directory (required unless --in-place or --dry-run)")
+	rootCmd.Flags().BoolVar(&inPlace, "in-place", false, "Modify input files in place (asks for confirmation unless --yes)")
+	rootCmd.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask for confirmation before modifying files in place")
 	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Dry-run mode: preview changes without writing files")
+	rootCmd.Flags().BoolVar(&check, "check", false, "Write nothing and exit 3 if any input would be modified, listing those files (for CI drift detection)")
 	rootCmd.Flags().BoolVar(&backup, "backup", false, "Backup original files with .bak extension")
+	rootCmd.Flags().StringVar(&outputFormat, "output-format", processor.FormatPreserve, "Output format: preserve (keep blank lines and comments), k8s (canonical field order) or json")
+	rootCmd.Flags().StringArrayVar(&includes, "include", nil, "Batch mode: only process files matching this glob, relative to the input directory; ** matches any directories (repeatable, replaces settings.include)")
+	rootCmd.Flags().StringArrayVar(&excludes, "exclude", nil, "Batch mode: skip files and directories matching this glob, e.g. '**/charts/**' (repeatable, added to settings.exclude)")
+	rootCmd.Flags().StringVar(&inputFormat, "format", processor.FormatAuto, "Input format: auto (detect JSON by .json extension or content), yaml or json (directory mode then picks .json files)")
+	rootCmd.Flags().BoolVar(&templated, "templated", false, "Inputs are Go-templated YAML such as Helm chart templates: {{ ... }} is kept verbatim and not parsed")
+	rootCmd.Flags().IntVar(&yamlIndent, "indent", 0, "Indentation in spaces for YAML output, 2-9 (0 = keep the input's indentation)")
+	rootCmd.Flags().BoolVar(&normalize, "normalize", false, "After the rules, sort mapping keys, write booleans and nulls as true/false/null and indent YAML output by 2 spaces (unless --indent)")
+	rootCmd.Flags().IntVar(&jsonIndent, "json-indent", 0, "Indentation in spaces for JSON output (0 = keep the input's indentation, default 2)")
+	rootCmd.Flags().StringVar(&diffFormat, "diff-format", "", "Dry-run preview as a diff instead of full output: unified, side-by-side or json")
+	rootCmd.Flags().StringVar(&duplicateKeys, "duplicate-keys", string(processor.DuplicateKeysWarn), "Duplicate mapping keys in inputs: warn, error, first-wins or last-wins (the latter two drop the other occurrences)")
+	rootCmd.Flags().StringVar(&backupFormat, "backup-format", string(processor.BackupCopy), "Backup format: copy (.bak) or patch (reverse patch .orig.patch)")
+	rootCmd.Flags().BoolVar(&compareOutput, "compare-output", false, "Only rewrite outputs whose content changed and report stale files in the output directory")
+	rootCmd.Flags().StringArrayVar(&params, "param", nil, "Rule file parameter as name=value (repeatable)")
+	rootCmd.Flags().StringArrayVar(&varFiles, "var-file", nil, "YAML file of parameter values (repeatable, later files win)")
+	rootCmd.Flags().StringArrayVar(&envFiles, "env-file", nil, ".env file of parameter values (repeatable, later files win)")
+	rootCmd.Flags().BoolVar(&strict, "strict", false, "Treat warnings as errors: unmatched rules and skipped elements fail the file")
+	rootCmd.Flags().BoolVar(&strictTypes, "strict-types", false, "Fail rules that would change a value's YAML type, e.g. a string onto an integer field (rules with coerce convert instead)")
+	rootCmd.Flags().BoolVar(&skipMissing, "skip-missing", false, "Skip rules whose path is not found instead of failing the file (continue_on_not_found for every rule)")
+	rootCmd.Flags().BoolVar(&pruneEmpty, "prune-empty", false, "Also remove mappings and sequences left empty by delete rules, up the tree (prune_empty for every delete rule)")
+	rootCmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Print nothing but errors; the exit code reports failures")
+	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "List the rules that matched in each file with their matched and changed node counts")
+	rootCmd.Flags().BoolVar(&summaryOnly, "summary-only", false, "Directory mode: print only the summary and failed files, no per-file lines or rule stats")
+	rootCmd.Flags().StringVar(&onlyPrefix, "only-path-prefix", "", "Only run rules whose path falls under this prefix, e.g. spec.template")
+	rootCmd.Flags().StringVar(&selectDocs, "select", "", "Only apply rules to documents matching this selector, e.g. kind=Deployment,name=web (name/namespace are regexes)")
+	rootCmd.Flags().BoolVar(&extractDocs, "extract", false, "With --select, write only the selected documents instead of passing the others through")
+	rootCmd.Flags().BoolVar(&failFast, "fail-fast", false, "Stop a directory run at the first failed file (default: record failures and continue)")
+	rootCmd.Flags().BoolVar(&nonAtomic, "allow-non-atomic", false, "When the owner of an existing file cannot be preserved, overwrite it in place with a warning instead of failing (not crash-safe)")
+	rootCmd.Flags().IntVar(&maxFiles, "max-changed-files", 0, "Abort without writing anything if more than N files would change (0 = no limit)")
+	rootCmd.Flags().IntVar(&maxPerFile, "max-changes-per-file", 0, "Abort without writing anything if any file would have more than N changed nodes (0 = no limit)")
+	rootCmd.Flags().BoolVar(&validateOut, "validate-schema", false, "Validate every output document against the built-in Kubernetes OpenAPI subset; type errors and unknown fields fail the file")
+	rootCmd.Flags().StringVar(&schemaDir, "schema-dir", "", "Validate output against the OpenAPI v2/v3 JSON files in this directory instead of the built-in subset (implies --validate-schema)")
+	rootCmd.Flags().BoolVar(&failOnUnused, "fail-on-unused-rules", false, "Exit nonzero if any rule matched no nodes across the whole run")
+	rootCmd.Flags().DurationVar(&regexTimeout, "regex-timeout", 5*time.Second, "Timeout for a single regex match in path conditions and regex_replace (0 = no limit)")
+	rootCmd.Flags().DurationVar(&ruleTimeout, "rule-timeout", 0, "Time budget for all regex matching of one rule on one document (0 = no limit)")
+	rootCmd.Flags().StringVar(&eventsOut, "events-out", "", "Stream one JSON event per node change to this file (NDJSON)")
+	rootCmd.Flags().StringVar(&reportOut, "report", "", "Write a JSON audit report of every file and node change to this file")
+	rootCmd.Flags().StringVar(&cacheFile, "cache", "", "Incremental cache file: skip inputs whose content and rules are unchanged since the last run")
+	rootCmd.Flags().StringVar(&journalDir, "journal", "", "Record the previous content of every written file in this directory so that 'yamleditor undo' can restore it")
+	rootCmd.Flags().StringVar(&keyFile, "key-file", "", "Base64-encoded 32-byte AES key for encrypt/decrypt rules")
 
 	rootCmd.MarkFlagRequired("config")
-	rootCmd.MarkFlagRequired("input")
+	rootCmd.MarkFlagsOneRequired("input", "input-from")
+	rootCmd.MarkFlagsMutuallyExclusive("input", "input-from")
+
+	rootCmd.AddCommand(newEvalCmd())
+	rootCmd.AddCommand(newSimulateCmd())
+	rootCmd.AddCommand(newVersionCmd())
+	rootCmd.AddCommand(newValidateCmd())
+	rootCmd.AddCommand(newUndoCmd())
+	rootCmd.AddCommand(newGetCmd())
+	rootCmd.AddCommand(newServeCmd())
+	rootCmd.AddCommand(newKRMCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		os.Exit(1)
 	}
 }
 
 func run(cmd *cobra.Command, args []string) error {
+	// 参数已解析完成，之后的错误与用法无关，不再打印 usage
+	cmd.SilenceUsage = true
+
 	// 创建处理器
 	proc, err := processor.NewProcessor(ruleFile)
 	if err != nil {
 		return fmt.Errorf("create processor: %w", err)
 	}
+	proc.SetWarnings(os.Stderr)
 
-	// 判断输入类型
-	info, err := os.Stat(input)
+	if quiet && summaryOnly {
+		return fmt.Errorf("--quiet and --summary-only are mutually exclusive")
+	}
+
+	if check {
+		switch {
+		case output != "" || inPlace:
+			return fmt.Errorf("--check compares against the inputs and takes no -o or --in-place")
+		case compareOutput:
+			return fmt.Errorf("--check and --compare-output are mutually exclusive")
+		case input == stdio:
+			return fmt.Errorf("--check cannot be used with -i -")
+		}
+		dryRun = true
+	}
+
+	if since != "" {
+		changedOnly = true
+	}
+	if changedOnly {
+		if output != "" {
+			return fmt.Errorf("--changed-only edits the changed files in place and takes no -o")
+		}
+		if inputFrom != "" {
+			return fmt.Errorf("--changed-only and --input-from are mutually exclusive")
+		}
+		if info, err := os.Stat(input); err != nil || !info.IsDir() {
+			return fmt.Errorf("--changed-only needs -i to be a directory in a git work tree")
+		}
+	}
+
+	if err := checkInPlace(proc.Settings()); err != nil {
+		return err
+	}
+
+	if onlyPrefix != "" {
+		if _, err := path.Parse(onlyPrefix); err != nil {
+			return fmt.Errorf("invalid --only-path-prefix: %w", err)
+		}
+	}
+
+	var selector *engine.Selector
+	if selectDocs != "" {
+		if selector, err = engine.ParseSelector(selectDocs); err != nil {
+			return fmt.Errorf("invalid --select: %w", err)
+		}
+	}
+	if extractDocs && selector == nil {
+		return fmt.Errorf("--extract requires --select")
+	}
+
+	settings := proc.Settings()
+	if len(includes) == 0 {
+		includes = settings.Include
+	}
+	excludes = append(slices.Clip(settings.Exclude), excludes...)
+	if err := rule.ValidateGlobs(includes); err != nil {
+		return fmt.Errorf("--include: %w", err)
+	}
+	if err := rule.ValidateGlobs(excludes); err != nil {
+		return fmt.Errorf("--exclude: %w", err)
+	}
+
+	var outSchema *schema.Schema
+	switch {
+	case schemaDir != "":
+		outSchema, err = schema.LoadDir(schemaDir)
+	case validateOut:
+		outSchema, err = schema.Builtin()
+	}
 	if err != nil {
-		return fmt.Errorf("stat input: %w", err)
+		return fmt.Errorf("load schema: %w", err)
+	}
+	if outSchema != nil && templated {
+		return fmt.Errorf("--validate-schema cannot be used with --templated")
+	}
+
+	switch processor.BackupFormat(backupFormat) {
+	case processor.BackupCopy, processor.BackupPatch:
+	default:
+		return fmt.Errorf("unknown --backup-format %q, expected copy or patch", backupFormat)
+	}
+
+	switch inputFormat {
+	case processor.FormatAuto, processor.FormatYAML, processor.FormatJSON:
+	default:
+		return fmt.Errorf("unknown --format %q, expected auto, yaml or json", inputFormat)
+	}
+	if templated && (outputFormat == processor.FormatJSON || inputFormat == processor.FormatJSON) {
+		return fmt.Errorf("--templated cannot be used with JSON input or output")
+	}
+	if jsonIndent < 0 {
+		return fmt.Errorf("--json-indent must not be negative")
+	}
+	if yamlIndent != 0 && (yamlIndent < 2 || yamlIndent > 9) {
+		return fmt.Errorf("--indent must be between 2 and 9")
+	}
+
+	switch processor.DuplicateKeys(duplicateKeys) {
+	case processor.DuplicateKeysWarn, processor.DuplicateKeysError, processor.DuplicateKeysFirstWins, processor.DuplicateKeysLastWins:
+	default:
+		return fmt.Errorf("unknown --duplicate-keys %q, expected warn, error, first-wins or last-wins", duplicateKeys)
 	}
 
-	if info.IsDir() {
+	switch processor.DiffFormat(diffFormat) {
+	case processor.DiffNone, processor.DiffUnified, processor.DiffSideBySide, processor.DiffJSON:
+	default:
+		return fmt.Errorf("unknown --diff-format %q, expected unified, side-by-side or json", diffFormat)
+	}
+	if diffFormat != "" && !dryRun {
+		return fmt.Errorf("--diff-format only applies to --dry-run or --check")
+	}
+
+	proc.SetOptions(processor.Options{
+		Backup:             backup,
+		BackupFormat:       processor.BackupFormat(backupFormat),
+		Format:             inputFormat,
+		JSONIndent:         jsonIndent,
+		Indent:             yamlIndent,
+		Normalize:          normalize,
+		Templated:          templated,
+		Include:            includes,
+		Exclude:            excludes,
+		CompareOutput:      compareOutput,
+		DiffFormat:         processor.DiffFormat(diffFormat),
+		DuplicateKeys:      processor.DuplicateKeys(duplicateKeys),
+		Check:              check,
+		Strict:             strict,
+		StrictTypes:        strictTypes,
+		ContinueOnNotFound: skipMissing,
+		PruneEmpty:         pruneEmpty,
+		OnlyPathPrefix:     onlyPrefix,
+		Select:             selector,
+		Schema:             outSchema,
+		Extract:            extractDocs,
+		FailFast:           failFast,
+		AllowNonAtomic:     nonAtomic,
+		MaxChangedFiles:    maxFiles,
+		MaxChangesPerFile:  maxPerFile,
+		RecordChanges:      reportOut != "",
+		RegexTimeout:       regexTimeout,
+		RuleTimeout:        ruleTimeout,
+	})
+
+	enc, err := processor.NewEncoder(outputFormat)
+	if err != nil {
+		return err
+	}
+	proc.SetEncoder(enc)
+
+	values, err := loadParams()
+	if err != nil {
+		return err
+	}
+	if err := proc.SetParams(values); err != nil {
+		return err
+	}
+
+	if keyFile != "" {
+		c, err := loadCipher(keyFile)
+		if err != nil {
+			return fmt.Errorf("load key: %w", err)
+		}
+		proc.SetCipher(c)
+	}
+
+	if eventsOut != "" {
+		f, err := os.Create(eventsOut)
+		if err != nil {
+			return fmt.Errorf("create events file: %w", err)
+		}
+		defer f.Close()
+		proc.SetEvents(f)
+	}
+
+	var cache *processor.Cache
+	if cacheFile != "" {
+		if cache, err = processor.LoadCache(cacheFile); err != nil {
+			return fmt.Errorf("load cache: %w", err)
+		}
+		proc.SetCache(cache)
+	}
+
+	if journalDir != "" {
+		j, err := processor.OpenJournal(journalDir)
+		if err != nil {
+			return err
+		}
+		proc.SetJournal(j)
+	}
+
+	if reportOut != "" {
+		report = newReport()
+	}
+
+	// 判断输入类型
+	if changedOnly {
+		// git 改动模式
+		err = processChanged(proc)
+	} else if inputFrom != "" {
+		// 文件列表模式
+		err = processFileList(proc, inputFrom, output)
+	} else if input == stdio {
+		// 管道模式
+		err = processStdin(proc)
+	} else if info, statErr := os.Stat(input); statErr != nil {
+		return fmt.Errorf("stat input: %w", statErr)
+	} else if info.IsDir() {
 		// 目录模式
-		return processDirectory(proc, input, output)
+		err = processDirectory(proc, input, output)
+	} else {
+		// 文件模式
+		err = processFile(proc, input, output)
+	}
+	if err == nil && check {
+		err = checkDrift()
 	}
 
-	// 文件模式
-	return processFile(proc, input, output)
+	// 部分文件失败或检查未通过时，已成功处理的文件仍写入缓存，审计报告同样写出
+	if cache != nil {
+		if saveErr := cache.Save(); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}
+	if report != nil {
+		if reportErr := writeReport(reportOut); reportErr != nil && err == nil {
+			err = reportErr
+		}
+	}
+	return err
 }
 
-func processFile(proc *processor.Processor, inputFile, outputFile string) error {
-	if outputFile == "" {
-		outputFile = inputFile // 默认原地覆盖
+// loadParams 合并参数值，优先级：--param > --var-file > --env-file
+func loadParams() (map[string]string, error) {
+	values := map[string]string{}
+	for _, f := range envFiles {
+		vars, err := rule.LoadEnvFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("load env file %s: %w", f, err)
+		}
+		for k, v := range vars {
+			values[k] = v
+		}
 	}
-
-	if backup && !dryRun && outputFile == inputFile {
-		// 只有原地覆盖才备份
-		backupPath := inputFile + ".bak"
-		data, err := os.ReadFile(inputFile)
+	for _, f := range varFiles {
+		vars, err := rule.LoadVarFile(f)
 		if err != nil {
-			return fmt.Errorf("read file for backup: %w", err)
+			return nil, fmt.Errorf("load var file %s: %w", f, err)
 		}
-		if err := os.WriteFile(backupPath, data, 0644); err != nil {
-			return fmt.Errorf("create backup: %w", err)
+		for k, v := range vars {
+			values[k] = v
+		}
+	}
+	if err := parseParams(params, values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// checkInPlace 原地修改须显式指定 --in-place，并在交互终端中确认
+// 规则文件设置 settings.in_place: true 时保持旧行为：未指定 -o 即原地修改，不确认
+func checkInPlace(settings rule.Settings) error {
+	if input == stdio {
+		return checkStdio()
+	}
+	if output != "" || dryRun {
+		if inPlace && output != "" {
+			return fmt.Errorf("--in-place and -o are mutually exclusive")
+		}
+		return nil
+	}
+	if settings.InPlace {
+		return nil
+	}
+	target := input
+	if inputFrom != "" {
+		target = "the listed files"
+	}
+	if !inPlace {
+		return fmt.Errorf("no output specified: pass -o to write elsewhere, --in-place to modify %s, or --dry-run to preview", target)
+	}
+	if yes {
+		return nil
+	}
+
+	// 非交互运行无法确认，需要 --yes
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return fmt.Errorf("--in-place needs --yes when not running interactively")
+	}
+
+	fmt.Fprintf(os.Stderr, "Modify %s in place? [y/N] ", target)
+	var answer string
+	fmt.Scanln(&answer)
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return fmt.Errorf("aborted")
+	}
+}
+
+// parseParams 解析 --param name=value，写入 values
+func parseParams(args []string, values map[string]string) error {
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("invalid --param %q, expected name=value", arg)
 		}
+		values[name] = value
 	}
+	return nil
+}
 
-	if err := proc.ProcessFile(inputFile, outputFile, dryRun); err != nil {
+// loadCipher 从密钥文件创建 AES-256-GCM 后端
+// 生成密钥: head -c 32 /dev/urandom | base64
+func loadCipher(path string) (engine.Cipher, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("decode key: %w", err)
+	}
+	return engine.NewAESCipher(key)
+}
+
+func processFile(proc *processor.Processor, inputFile, outputFile string) error {
+	// 统一路径分隔符，Windows 上 a/b.yaml 与 a\b.yaml 视为同一文件
+	inputFile = filepath.Clean(inputFile)
+	if outputFile == "" {
+		outputFile = inputFile // 默认原地覆盖
+	}
+	outputFile = filepath.Clean(outputFile)
+
+	result, err := proc.ProcessFile(inputFile, outputFile, dryRun)
+	if err != nil {
+		recordFile(processor.FileReport{Path: filepath.ToSlash(inputFile), Error: err})
 		return err
 	}
+	report := processor.FileReport{
+		Path:    filepath.ToSlash(inputFile),
+		Output:  filepath.ToSlash(outputFile),
+		Status:  result.Status,
+		Cached:  result.Cached,
+		Rules:   result.Rules,
+		Changes: result.Changes,
+	}
+	recordFile(report)
+	recordDrift(report)
 
-	if !dryRun {
-		if outputFile == inputFile {
+	if !dryRun && !quiet {
+		if result.Cached {
+			fmt.Printf("= Cached: %s\n", outputFile)
+		} else if result.Status == processor.StatusUnchanged {
+			fmt.Printf("= Unchanged: %s\n", outputFile)
+		} else if outputFile == inputFile {
 			fmt.Printf("✓ Processed: %s\n", inputFile)
 		} else {
 			fmt.Printf("✓ Processed: %s → %s\n", inputFile, outputFile)
 		}
 	}
+	printVerbose([]processor.FileReport{report})
+	printSkipped(result.Rules)
+	return checkUnused(result.Rules)
+}
+
+// stdio -i 取该值时从 stdin 读取、结果写到 stdout
+const stdio = "-"
+
+// checkStdio 校验管道模式的参数：结果只能写到 stdout，不支持依赖文件路径的选项
+func checkStdio() error {
+	switch {
+	case output != "" && output != stdio:
+		return fmt.Errorf("-i - writes the result to stdout; redirect it instead of passing -o")
+	case inPlace:
+		return fmt.Errorf("--in-place cannot be used with -i -")
+	case compareOutput:
+		return fmt.Errorf("--compare-output cannot be used with -i -")
+	case cacheFile != "":
+		return fmt.Errorf("--cache cannot be used with -i -")
+	}
+	return nil
+}
+
+// processStdin 从 stdin 读取 YAML，处理结果写到 stdout，报告与警告写到 stderr
+func processStdin(proc *processor.Processor) error {
+	result, err := proc.ProcessStream("<stdin>", os.Stdin, os.Stdout, dryRun)
+	if err != nil {
+		recordFile(processor.FileReport{Path: "<stdin>", Error: err})
+		return err
+	}
+	report := processor.FileReport{
+		Path:    "<stdin>",
+		Output:  "<stdout>",
+		Status:  result.Status,
+		Rules:   result.Rules,
+		Changes: result.Changes,
+	}
+	recordFile(report)
+	printVerbose([]processor.FileReport{report})
+	printSkipped(result.Rules)
+	return checkUnused(result.Rules)
+}
+
+// reportOutput 规则报告的输出位置：stdout 是预览或处理结果（dry-run、-i -）以及 --quiet 时写到 stderr
+func reportOutput() *os.File {
+	if dryRun || quiet || input == stdio {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// printRuleStats 打印每条规则在整个批次上的执行统计
+func printRuleStats(rules []processor.RuleStats) {
+	if len(rules) == 0 {
+		return
+	}
+
+	fmt.Println("\n规则统计:")
+	for i, s := range rules {
+		fmt.Printf("  %s %s %s: 文件 %d | 匹配 %d | 修改 %d | 耗时 %s%s\n",
+			ruleLabel(i, s.Rule), s.Rule.Action, s.Rule.Path, s.FilesMatched, s.NodesMatched, s.NodesChanged,
+			s.Duration.Round(time.Microsecond), ruleNote(s.Rule))
+	}
+}
+
+// printVerbose --verbose 时逐个文件列出匹配到节点的规则；
+// 非 dry-run 的目录模式已在文件列表中列出，这里只处理其余情况
+func printVerbose(files []processor.FileReport) {
+	if !verbose || quiet {
+		return
+	}
+	out := reportOutput()
+	for _, f := range files {
+		if f.Error != nil {
+			continue
+		}
+		fmt.Fprintf(out, "%s:\n", f.Path)
+		printFileRules(out, f, "  ")
+	}
+}
+
+// printFileRules 打印规则在单个文件上的统计，只列出匹配到节点的规则
+func printFileRules(out *os.File, f processor.FileReport, indent string) {
+	if f.Cached {
+		fmt.Fprintf(out, "%s(缓存，未执行规则)\n", indent)
+		return
+	}
+	matched := 0
+	for i, s := range f.Rules {
+		if s.NodesMatched == 0 {
+			continue
+		}
+		matched++
+		fmt.Fprintf(out, "%s%s %s %s: 匹配 %d | 修改 %d%s\n",
+			indent, ruleLabel(i, s.Rule), s.Rule.Action, s.Rule.Path, s.NodesMatched, s.NodesChanged, ruleNote(s.Rule))
+	}
+	if matched == 0 {
+		fmt.Fprintf(out, "%s(没有规则匹配)\n", indent)
+	}
+}
+
+// ruleLabel 报告中的规则名：序号，设置了 id 时附上 id
+func ruleLabel(i int, r *engine.Rule) string {
+	if r.ID == "" {
+		return fmt.Sprintf("#%d", i)
+	}
+	return fmt.Sprintf("#%d %s", i, r.ID)
+}
+
+// ruleID 规则设置了 id 时返回放在动作之前的 id
+func ruleID(r *engine.Rule) string {
+	if r.ID == "" {
+		return ""
+	}
+	return r.ID + " "
+}
+
+// ruleNote 规则有 description 时返回附在报告行尾的说明
+func ruleNote(r *engine.Rule) string {
+	if r.Description == "" {
+		return ""
+	}
+	return " — " + r.Description
+}
+
+// printSkipped 报告因路径未找到而在部分文件上跳过的规则（continue_on_not_found 或 --skip-missing）
+func printSkipped(rules []processor.RuleStats) {
+	skipped := processor.Skipped(rules)
+	if len(skipped) == 0 {
+		return
+	}
+
+	out := reportOutput()
+	fmt.Fprintf(out, "\n路径未找到而跳过的规则: %d\n", len(skipped))
+	for _, s := range skipped {
+		fmt.Fprintf(out, "  - %s%s %s: 文件 %d%s\n", ruleID(s.Rule), s.Rule.Action, s.Rule.Path, s.FilesSkipped, ruleNote(s.Rule))
+	}
+}
+
+// checkUnused 报告整个运行中未匹配到任何节点的规则，--fail-on-unused-rules 时返回错误
+func checkUnused(rules []processor.RuleStats) error {
+	unused := processor.Unused(rules)
+	if len(unused) == 0 {
+		return nil
+	}
+
+	out := reportOutput()
+	fmt.Fprintf(out, "\n未使用的规则: %d\n", len(unused))
+	for _, s := range unused {
+		fmt.Fprintf(out, "  - %s%s %s%s\n", ruleID(s.Rule), s.Rule.Action, s.Rule.Path, ruleNote(s.Rule))
+	}
+	if failOnUnused {
+		return fmt.Errorf("%d rule(s) matched no nodes", len(unused))
+	}
 	return nil
 }
 
+// printCompareSummary 打印 --compare-output 的比较结果
+func printCompareSummary(result *processor.ProcessResult) {
+	fmt.Printf("\n输出目录中无对应输入: %d\n", len(result.Stale))
+	for _, f := range result.Stale {
+		fmt.Printf("  - %s\n", f)
+	}
+}
+
+// errorMode 目录模式遇到失败文件时的处理方式
+func errorMode() string {
+	if failFast {
+		return "fail-fast (首个失败即停止)"
+	}
+	return "collect-all (记录失败并继续)"
+}
+
 func processDirectory(proc *processor.Processor, inputDir, outputDir string) error {
 	result, err := proc.ProcessDirectory(inputDir, outputDir, dryRun, backup)
 	if err != nil {
 		return err
 	}
+	return reportBatch(result)
+}
+
+// processFileList 处理 --input-from 列出的文件，- 表示从 stdin 读取列表
+func processFileList(proc *processor.Processor, listFile, outputDir string) error {
+	r := os.Stdin
+	if listFile != "-" {
+		f, err := os.Open(listFile)
+		if err != nil {
+			return fmt.Errorf("open file list: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	files, err := processor.ReadFileList(r)
+	if err != nil {
+		return err
+	}
+
+	result, err := proc.ProcessFiles(files, outputDir, dryRun, backup)
+	if err != nil {
+		return err
+	}
+	return reportBatch(result)
+}
 
-	if !dryRun {
+// reportBatch 输出目录或文件列表批量处理的结果，有失败文件时返回错误
+func reportBatch(result *processor.ProcessResult) error {
+	for _, f := range result.Files {
+		recordFile(f)
+		recordDrift(f)
+	}
+
+	if !quiet {
+		if !dryRun && !summaryOnly {
+			printFileTable(result.Files)
+		} else if dryRun {
+			printVerbose(result.Files)
+		}
+		if compareOutput {
+			printCompareSummary(result)
+		}
+	}
+
+	if !dryRun && !quiet {
 		fmt.Printf("\n=== 处理完成 ===\n")
-		fmt.Printf("总计: %d | 成功: %d | 失败: %d\n",
-			result.TotalFiles, result.SuccessFiles, len(result.FailedFiles))
+		fmt.Printf("错误模式: %s\n", errorMode())
+		fmt.Printf("总计: %d | 成功: %d | 未变化: %d | 失败: %d\n",
+			result.TotalFiles, result.SuccessFiles, len(result.Unchanged), len(result.FailedFiles))
+		if result.Aborted {
+			fmt.Println("已在首个失败文件处停止，其余文件未处理")
+		}
+
+		if cacheFile != "" {
+			fmt.Printf("缓存命中: %d\n", result.Cached)
+		}
+
+		if !summaryOnly {
+			printRuleStats(result.Rules)
+		}
 
 		if len(result.FailedFiles) > 0 {
 			fmt.Println("\n失败文件:")
 			for _, f := range result.FailedFiles {
 				fmt.Printf("  ✗ %s\n    原因: %v\n", f.Path, f.Error)
 			}
-			return nil
+		} else {
+			fmt.Println("✓ 所有文件处理成功")
+		}
+	} else {
+		// dry-run 的 stdout 是预览内容，--quiet 不输出汇总，失败原因写到 stderr
+		for _, f := range result.FailedFiles {
+			fmt.Fprintf(os.Stderr, "✗ %s: %v\n", f.Path, f.Error)
 		}
+	}
+
+	if result.Aborted {
+		f := result.FailedFiles[0]
+		return fmt.Errorf("%s: %w (fail-fast)", f.Path, f.Error)
+	}
 
-		fmt.Println("✓ 所有文件处理成功")
+	printSkipped(result.Rules)
+	if err := checkUnused(result.Rules); err != nil {
+		return err
+	}
+
+	if len(result.FailedFiles) > 0 {
+		return fmt.Errorf("%d file(s) failed", len(result.FailedFiles))
 	}
 	return nil
 }
+
+// printFileTable 逐个文件打印处理结果
+func printFileTable(files []processor.FileReport) {
+	if len(files) == 0 {
+		return
+	}
+
+	fmt.Println("文件:")
+	for _, f := range files {
+		switch {
+		case f.Error != nil:
+			fmt.Printf("  ✗ %s\n", f.Path)
+		case f.Cached:
+			fmt.Printf("  = %s (缓存)\n", f.Path)
+		case f.Status == processor.StatusUnchanged:
+			fmt.Printf("  = %s (未变化)\n", f.Path)
+		case f.Output == f.Path:
+			fmt.Printf("  ✓ %s\n", f.Path)
+		default:
+			fmt.Printf("  ✓ %s → %s\n", f.Path, f.Output)
+		}
+		if verbose && f.Error == nil {
+			printFileRules(os.Stdout, f, "      ")
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/glesirok/yamleditor/pkg/processor"
+	"github.com/glesirok/yamleditor/pkg/version"
+)
+
+// auditReport --report 输出的审计报告：每个文件的处理结果与逐节点修改
+type auditReport struct {
+	Version     string       `json:"version"` // 工具版本
+	Config      string       `json:"config"`  // 规则文件
+	GeneratedAt time.Time    `json:"generated_at"`
+	DryRun      bool         `json:"dry_run"`
+	Summary     auditSummary `json:"summary"`
+	Files       []auditFile  `json:"files"`
+}
+
+// auditSummary 审计报告的汇总
+type auditSummary struct {
+	Files        int `json:"files"`
+	ChangedFiles int `json:"changed_files"` // 有节点被修改的文件数
+	Changes      int `json:"changes"`
+	Failed       int `json:"failed"`
+}
+
+// auditFile 单个文件的审计记录，失败的文件只有 error
+type auditFile struct {
+	File    string               `json:"file"`
+	Output  string               `json:"output,omitempty"`
+	Status  processor.FileStatus `json:"status,omitempty"`
+	Cached  bool                 `json:"cached,omitempty"` // 命中缓存，未执行规则，没有修改记录
+	Error   string               `json:"error,omitempty"`
+	Rules   []auditRule          `json:"rules,omitempty"` // 在该文件上匹配到节点的规则
+	Changes []processor.Event    `json:"changes"`
+}
+
+// auditRule 单条规则在一个文件上的统计
+type auditRule struct {
+	Rule        int    `json:"rule"` // 规则序号
+	ID          string `json:"id,omitempty"`
+	Description string `json:"description,omitempty"`
+	Matched     int    `json:"matched"`
+	Changed     int    `json:"changed"`
+}
+
+// report 非 nil 时收集审计记录，运行结束后写入 --report 指定的文件
+var report *auditReport
+
+// recordFile 把文件的处理结果记入审计报告
+func recordFile(f processor.FileReport) {
+	if report == nil {
+		return
+	}
+	entry := auditFile{File: f.Path, Output: f.Output, Status: f.Status, Cached: f.Cached, Changes: f.Changes}
+	if entry.Changes == nil {
+		entry.Changes = []processor.Event{}
+	}
+	for i, s := range f.Rules {
+		if s.NodesMatched > 0 {
+			entry.Rules = append(entry.Rules, auditRule{Rule: i, ID: s.Rule.ID, Description: s.Rule.Description, Matched: s.NodesMatched, Changed: s.NodesChanged})
+		}
+	}
+	report.Summary.Files++
+	switch {
+	case f.Error != nil:
+		entry.Error = f.Error.Error()
+		report.Summary.Failed++
+	case len(f.Changes) > 0:
+		report.Summary.ChangedFiles++
+		report.Summary.Changes += len(f.Changes)
+	}
+	report.Files = append(report.Files, entry)
+}
+
+// writeReport 写出审计报告
+func writeReport(path string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	return nil
+}
+
+// newReport 创建空的审计报告
+func newReport() *auditReport {
+	return &auditReport{
+		Version:     version.Get().Version,
+		Config:      ruleFile,
+		GeneratedAt: time.Now().UTC().Truncate(time.Second),
+		DryRun:      dryRun,
+		Files:       []auditFile{},
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/glesirok/yamleditor/pkg/processor"
+	"github.com/glesirok/yamleditor/pkg/rule"
+	"github.com/glesirok/yamleditor/pkg/version"
+)
+
+var (
+	serveListen       string
+	serveMaxBody      int64
+	serveTokenFile    string
+	serveRegexTimeout time.Duration
+	serveRuleTimeout  time.Duration
+	serveWebhook      bool
+	serveTLSCert      string
+	serveTLSKey       string
+	serveReload       time.Duration
+	serveDrain        time.Duration
+)
+
+// newServeCmd serve 子命令：以 HTTP 服务的形式对请求中的 YAML 应用请求中的规则，
+// 或以 --admission-webhook 作为 Kubernetes mutating admission webhook 应用规则文件
+func newServeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run an HTTP service that applies rules from each request to the YAML in it",
+		Example: `  yamleditor serve --listen 127.0.0.1:8080
+  yamleditor serve --listen :8080 --token-file /etc/yamleditor/token
+  curl -s localhost:8080/v1/apply -d '{"rules": "{action: replace, path: spec.replicas, value: 3}", "yaml": "spec: {replicas: 1}"}'
+  yamleditor serve --admission-webhook -c rules.yaml --listen :8443 --tls-cert tls.crt --tls-key tls.key`,
+		Args: cobra.NoArgs,
+		RunE: runServe,
+	}
+	cmd.Flags().StringVar(&serveListen, "listen", "127.0.0.1:8080", "Address to listen on")
+	cmd.Flags().Int64Var(&serveMaxBody, "max-body", 10<<20, "Maximum request body size in bytes")
+	cmd.Flags().StringVar(&serveTokenFile, "token-file", "", "Require 'Authorization: Bearer <token>' with the token read from this file")
+	cmd.Flags().DurationVar(&serveRegexTimeout, "regex-timeout", 5*time.Second, "Timeout for a single regex match (0 = no limit)")
+	cmd.Flags().DurationVar(&serveRuleTimeout, "rule-timeout", 10*time.Second, "Time budget for all regex matching of one rule on one document (0 = no limit)")
+	cmd.Flags().BoolVar(&serveWebhook, "admission-webhook", false, "Serve a Kubernetes mutating admission webhook at /mutate that applies the rules from -c, instead of /v1/apply")
+	cmd.Flags().StringVarP(&ruleFile, "config", "c", "", "Rule configuration file (with --admission-webhook)")
+	cmd.Flags().StringArrayVar(&params, "param", nil, "Rule file parameter as name=value (repeatable, with --admission-webhook)")
+	cmd.Flags().StringArrayVar(&varFiles, "var-file", nil, "YAML file of parameter values (repeatable, later files win)")
+	cmd.Flags().StringArrayVar(&envFiles, "env-file", nil, ".env file of parameter values (repeatable, later files win)")
+	cmd.Flags().DurationVar(&serveReload, "reload-interval", 10*time.Second, "Check the rule file for changes at this interval and reload it (0 = never, with --admission-webhook); SIGHUP reloads immediately")
+	cmd.Flags().DurationVar(&serveDrain, "drain-delay", 5*time.Second, "On SIGTERM/SIGINT, keep serving with /readyz failing for this long before closing the listener, so load balancers stop sending traffic")
+	cmd.Flags().StringVar(&serveTLSCert, "tls-cert", "", "Serve HTTPS with this certificate file (requires --tls-key)")
+	cmd.Flags().StringVar(&serveTLSKey, "tls-key", "", "Private key file for --tls-cert")
+	return cmd
+}
+
+// applyRequest POST /v1/apply 的请求体
+type applyRequest struct {
+	Rules         string            `json:"rules"`                    // 规则：完整规则文件、规则列表或单条规则，同 eval --rule
+	YAML          string            `json:"yaml"`                     // 待修改的内容，可包含多个文档
+	Name          string            `json:"name,omitempty"`           // 文件名，用于规则的 files 与模板中的 .File
+	Params        map[string]string `json:"params,omitempty"`         // 规则参数
+	OutputFormat  string            `json:"output_format,omitempty"`  // preserve（默认）、k8s 或 json
+	Strict        bool              `json:"strict,omitempty"`         // 同 --strict
+	StrictTypes   bool              `json:"strict_types,omitempty"`   // 同 --strict-types
+	DuplicateKeys string            `json:"duplicate_keys,omitempty"` // 同 --duplicate-keys
+}
+
+// applyResponse POST /v1/apply 成功时的响应
+type applyResponse struct {
+	Output   string            `json:"output"`
+	Changed  bool              `json:"changed"`            // 输出与输入不同
+	Rules    []auditRule       `json:"rules"`              // 匹配到节点的规则
+	Changes  []processor.Event `json:"changes"`            // 逐节点修改
+	Warnings []string          `json:"warnings,omitempty"` // 弃用的规则、通配展开时跳过的元素、重复键等警告
+}
+
+// errorResponse 请求失败时的响应
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	if serveWebhook && ruleFile == "" {
+		return fmt.Errorf("--admission-webhook requires -c")
+	}
+	if !serveWebhook && (ruleFile != "" || len(params) > 0 || len(varFiles) > 0 || len(envFiles) > 0) {
+		return fmt.Errorf("-c, --param, --var-file and --env-file require --admission-webhook, /v1/apply takes rules from each request")
+	}
+	if (serveTLSCert == "") != (serveTLSKey == "") {
+		return fmt.Errorf("--tls-cert and --tls-key must be given together")
+	}
+	cmd.SilenceUsage = true
+
+	var token string
+	if serveTokenFile != "" {
+		data, err := os.ReadFile(serveTokenFile)
+		if err != nil {
+			return fmt.Errorf("read token: %w", err)
+		}
+		if token = strings.TrimSpace(string(data)); token == "" {
+			return fmt.Errorf("token file %s is empty", serveTokenFile)
+		}
+	}
+
+	// ready 在规则加载完成、端口监听成功后置为 true，收到退出信号时置回 false，使 /readyz 在这两段时间内返回 503
+	var ready atomic.Bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, version.Get())
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: "not ready"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+	})
+	var webhook *admissionHandler
+	if serveWebhook {
+		var err error
+		if webhook, err = newAdmissionHandler(); err != nil {
+			return err
+		}
+		mux.Handle("POST /mutate", webhook)
+	} else {
+		mux.HandleFunc("POST /v1/apply", handleApply)
+	}
+
+	srv := &http.Server{
+		Addr:              serveListen,
+		Handler:           authorize(token, mux),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+	}
+
+	if serveTLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(serveTLSCert, serveTLSKey)
+		if err != nil {
+			return fmt.Errorf("load TLS certificate: %w", err)
+		}
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	// 先绑定端口再标记就绪，绑定失败直接退出
+	ln, err := net.Listen("tcp", serveListen)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	onReload := func(err error) {
+		if err != nil {
+			log.Printf("%v", err)
+			return
+		}
+		log.Printf("reloaded rules from %s", ruleFile)
+	}
+	if webhook != nil && serveReload > 0 {
+		go webhook.proc.WatchRules(ctx, serveReload, onReload)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			errc <- srv.ServeTLS(ln, "", "")
+			return
+		}
+		errc <- srv.Serve(ln)
+	}()
+	log.Printf("listening on %s", ln.Addr())
+	ready.Store(true)
+
+wait:
+	for {
+		select {
+		case err := <-errc:
+			return err
+		case <-hup:
+			// SIGHUP 立即重新加载规则文件，失败时旧规则继续生效
+			if webhook == nil {
+				log.Printf("SIGHUP ignored: /v1/apply takes rules from each request")
+				continue
+			}
+			onReload(webhook.proc.Reload())
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	// 先让 /readyz 失败，等负载均衡摘除本实例后再停止接受连接，处理中的请求在 Shutdown 中完成
+	ready.Store(false)
+	log.Printf("shutting down, draining for %s", serveDrain)
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(serveDrain):
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdown)
+}
+
+// authorize token 非空时要求请求带 Bearer token，/healthz 与 /readyz 除外
+func authorize(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if r.URL.Path != "/healthz" && r.URL.Path != "/readyz" && (!ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1) {
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "missing or invalid bearer token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleApply 对请求中的 YAML 应用请求中的规则
+// 规则不能读取本机文件（value_from）与服务进程的环境变量；请求或规则有误时返回 400，执行规则失败时返回 422
+func handleApply(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	var req applyRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, serveMaxBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeJSON(w, status, errorResponse{Error: fmt.Sprintf("decode request: %v", err)})
+		return
+	}
+	if req.Name == "" {
+		req.Name = "<request>"
+	}
+
+	resp, status, err := apply(&req)
+	if err != nil {
+		log.Printf("apply %s: %d %v", req.Name, status, err)
+		writeJSON(w, status, errorResponse{Error: err.Error()})
+		return
+	}
+	log.Printf("apply %s: %d, %d change(s) in %s", req.Name, status, len(resp.Changes), time.Since(start).Round(time.Millisecond))
+	writeJSON(w, status, resp)
+}
+
+// apply 执行一个请求，每个请求使用独立的 Processor
+func apply(req *applyRequest) (*applyResponse, int, error) {
+	if strings.TrimSpace(req.Rules) == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("rules is required")
+	}
+	config, err := rule.ParseInlineIsolated([]byte(req.Rules))
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("parse rules: %w", err)
+	}
+	proc := processor.NewProcessorFromConfig(config)
+	proc.SetEnviron(map[string]string{})
+
+	format := req.OutputFormat
+	if format == "" {
+		format = processor.FormatPreserve
+	}
+	enc, err := processor.NewEncoder(format)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	proc.SetEncoder(enc)
+
+	switch policy := processor.DuplicateKeys(req.DuplicateKeys); policy {
+	case "", processor.DuplicateKeysWarn, processor.DuplicateKeysError, processor.DuplicateKeysFirstWins, processor.DuplicateKeysLastWins:
+	default:
+		return nil, http.StatusBadRequest, fmt.Errorf("unknown duplicate_keys %q, expected warn, error, first-wins or last-wins", policy)
+	}
+	proc.SetOptions(processor.Options{
+		Strict:        req.Strict,
+		StrictTypes:   req.StrictTypes,
+		DuplicateKeys: processor.DuplicateKeys(req.DuplicateKeys),
+		RecordChanges: true,
+		RegexTimeout:  serveRegexTimeout,
+		RuleTimeout:   serveRuleTimeout,
+	})
+	if err := proc.SetParams(req.Params); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	output, result, err := proc.Eval(req.Name, []byte(req.YAML))
+	if err != nil {
+		return nil, http.StatusUnprocessableEntity, err
+	}
+
+	resp := &applyResponse{
+		Output:  string(output),
+		Changed: !bytes.Equal(output, []byte(req.YAML)),
+		Rules:   []auditRule{},
+		Changes:  result.Changes,
+		Warnings: result.Warnings,
+	}
+	if resp.Changes == nil {
+		resp.Changes = []processor.Event{}
+	}
+	for i, s := range result.Rules {
+		if s.NodesMatched > 0 {
+			resp.Rules = append(resp.Rules, auditRule{Rule: i, ID: s.Rule.ID, Description: s.Rule.Description, Matched: s.NodesMatched, Changed: s.NodesChanged})
+		}
+	}
+	return resp, http.StatusOK, nil
+}
+
+// writeJSON 写出 JSON 响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("write response: %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/glesirok/yamleditor/pkg/processor"
+)
+
+var (
+	undoJournal string
+	undoForce   bool
+	undoList    bool
+)
+
+// newUndoCmd undo 子命令：按 --journal 记录的变更日志撤销最近一次执行
+func newUndoCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "undo",
+		Short: "Restore the files written by the most recent run recorded with --journal",
+		Example: `  yamleditor -c rules.yaml -i ./yamls/ --in-place -y --journal .yamleditor-journal
+  yamleditor undo --journal .yamleditor-journal`,
+		Args: cobra.NoArgs,
+		RunE: runUndo,
+	}
+	cmd.Flags().StringVar(&undoJournal, "journal", "", "Journal directory passed to --journal (required)")
+	cmd.Flags().BoolVar(&undoForce, "force", false, "Restore even if files were changed after the run")
+	cmd.Flags().BoolVar(&undoList, "list", false, "List the recorded runs, oldest first, without restoring anything")
+	cmd.MarkFlagRequired("journal")
+	return cmd
+}
+
+func runUndo(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	if undoList {
+		runs, err := processor.JournalRuns(undoJournal)
+		if err != nil {
+			return err
+		}
+		for _, run := range runs {
+			fmt.Printf("%s  %s  %d file(s)  %s\n", run.ID, run.Time.Local().Format("2006-01-02 15:04:05"), len(run.Files), run.RuleFile)
+		}
+		if len(runs) == 0 {
+			fmt.Println("没有可撤销的记录")
+		}
+		return nil
+	}
+
+	run, err := processor.Undo(undoJournal, undoForce)
+	if err != nil {
+		return err
+	}
+	for _, e := range run.Files {
+		if e.Backup == "" {
+			fmt.Printf("✓ Removed: %s\n", e.Path)
+		} else {
+			fmt.Printf("✓ Restored: %s\n", e.Path)
+		}
+	}
+	fmt.Printf("已撤销 %s 的执行: %d 个文件\n", run.Time.Local().Format("2006-01-02 15:04:05"), len(run.Files))
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/glesirok/yamleditor/pkg/rule"
+)
+
+// newValidateCmd validate 子命令：检查规则文件并一次列出所有错误及其行号，不处理任何 YAML
+func newValidateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate <rules.yaml>...",
+		Short: "Check rule files and report every error with its line number",
+		Example: `  yamleditor validate rules.yaml
+  yamleditor validate rules/*.yaml`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runValidate,
+	}
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	total := 0
+	for _, file := range args {
+		problems, err := rule.LintFile(file)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", file, err)
+		}
+		if len(problems) == 0 {
+			fmt.Printf("✓ %s\n", file)
+			continue
+		}
+		for _, p := range problems {
+			fmt.Printf("✗ %s:%d: %s\n", file, p.Line, p)
+		}
+		total += len(problems)
+	}
+
+	if total > 0 {
+		return fmt.Errorf("%d problem(s) found", total)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/glesirok/yamleditor/pkg/version"
+)
+
+var versionJSON bool
+
+// newVersionCmd version 子命令：输出工具版本与支持的规则文件特性版本
+func newVersionCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the yamleditor version and the highest supported rule file version",
+		Args:  cobra.NoArgs,
+		RunE:  runVersion,
+	}
+	cmd.Flags().BoolVar(&versionJSON, "json", false, "Print version information as JSON")
+	return cmd
+}
+
+func runVersion(cmd *cobra.Command, args []string) error {
+	info := version.Get()
+	if versionJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+
+	fmt.Printf("yamleditor %s\n", info.Version)
+	fmt.Printf("config version: %d\n", info.ConfigVersion)
+	if info.Commit != "" {
+		fmt.Printf("commit: %s\n", info.Commit)
+	}
+	fmt.Printf("go: %s\n", info.GoVersion)
+	return nil
+}
@@ -0,0 +1,121 @@
+package editor
+
+import (
+	"fmt"
+
+	"github.com/glesirok/yamleditor/pkg/engine"
+	"github.com/glesirok/yamleditor/pkg/processor"
+	"github.com/glesirok/yamleditor/pkg/rule"
+)
+
+// Change 一个节点的修改，字段含义同 processor.Event；File 为空
+type Change = processor.Event
+
+// ChangeSet 一次 ApplyBytes 产生的修改
+type ChangeSet struct {
+	Changes  []Change              // 按执行顺序排列的逐节点修改
+	Rules    []processor.RuleStats // 每条规则的匹配与修改统计，下标同规则序号
+	Warnings []string              // 处理时的警告，如弃用的规则、通配展开时跳过的元素、重复键
+}
+
+// Empty 是否没有任何节点被修改
+func (c ChangeSet) Empty() bool {
+	return len(c.Changes) == 0
+}
+
+// Editor 持有一组规则，可对多份内容重复使用；不可并发调用
+type Editor struct {
+	proc    *processor.Processor
+	name    string   // 内容的名称，用于模板 .File 与错误信息
+	err     error    // 规则校验错误，由 ApplyBytes 返回
+	changes []Change // 当前 ApplyBytes 收集到的修改
+}
+
+// New 由规则创建 Editor，规则在此校验，错误由 ApplyBytes 返回
+// rules 须是展开后的普通规则：路径别名与 kinds: 分组只在解析规则文件时展开，见 Parse/Load
+func New(rules []*engine.Rule) *Editor {
+	var err error
+	for i, r := range rules {
+		if err = rule.Validate(r); err != nil {
+			err = fmt.Errorf("rule %d: %w", i, err)
+			break
+		}
+	}
+	e := FromConfig(&rule.Config{Rules: rules})
+	e.err = err
+	return e
+}
+
+// Parse 解析规则内容创建 Editor，格式同 eval --rule：完整配置、规则列表或单条规则
+func Parse(data []byte) (*Editor, error) {
+	config, err := rule.ParseInline(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse rules: %w", err)
+	}
+	return FromConfig(config), nil
+}
+
+// Load 从规则文件创建 Editor
+func Load(ruleFile string) (*Editor, error) {
+	config, err := rule.LoadConfig(ruleFile)
+	if err != nil {
+		return nil, fmt.Errorf("load rules: %w", err)
+	}
+	return FromConfig(config), nil
+}
+
+// FromConfig 由已加载的配置创建 Editor
+func FromConfig(config *rule.Config) *Editor {
+	e := &Editor{proc: processor.NewProcessorFromConfig(config), name: "<input>"}
+	e.proc.SetEventHandler(func(ev processor.Event) error {
+		ev.File = ""
+		e.changes = append(e.changes, ev)
+		return nil
+	})
+	return e
+}
+
+// SetName 设置内容的名称，用于模板中的 .File 与错误信息，默认 <input>
+func (e *Editor) SetName(name string) {
+	e.name = name
+}
+
+// SetParams 设置规则参数值，见 processor.Processor.SetParams
+func (e *Editor) SetParams(values map[string]string) error {
+	return e.proc.SetParams(values)
+}
+
+// SetCipher 设置 encrypt/decrypt 规则使用的加解密后端
+func (e *Editor) SetCipher(c engine.Cipher) {
+	e.proc.SetCipher(c)
+}
+
+// SetOptions 设置处理选项，只有与文件无关的选项生效（Strict、ContinueOnNotFound、OnlyPathPrefix、Select/Extract、超时）
+func (e *Editor) SetOptions(opts processor.Options) {
+	e.proc.SetOptions(opts)
+}
+
+// SetWarningHandler 设置警告的回调，产生警告时立即调用；警告总是记入 ChangeSet.Warnings
+func (e *Editor) SetWarningHandler(fn func(msg string)) {
+	e.proc.SetWarningHandler(fn)
+}
+
+// SetEncoder 设置输出格式，默认 preserve
+func (e *Editor) SetEncoder(enc processor.Encoder) {
+	e.proc.SetEncoder(enc)
+}
+
+// ApplyBytes 对 data 应用规则，返回处理结果与修改记录
+// data 可包含多个文档，BOM 与开头的注释块按 processor 的规则保留
+func (e *Editor) ApplyBytes(data []byte) ([]byte, ChangeSet, error) {
+	if e.err != nil {
+		return nil, ChangeSet{}, e.err
+	}
+
+	e.changes = nil
+	output, result, err := e.proc.Eval(e.name, data)
+	if err != nil {
+		return nil, ChangeSet{}, err
+	}
+	return output, ChangeSet{Changes: e.changes, Rules: result.Rules, Warnings: result.Warnings}, nil
+}
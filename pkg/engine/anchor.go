@@ -0,0 +1,206 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/glesirok/yamleditor/pkg/path"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultAnchor deduplicate_as_anchor 未配置 anchor 时使用的锚点名
+const defaultAnchor = "shared"
+
+// setAnchor 为匹配节点设置锚点，节点已有锚点时同步更新引用它的别名
+func (e *Engine) setAnchor(root *yaml.Node, rule *Rule, res *Result) error {
+	matches, err := e.find(root, rule)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	res.Matched = len(matches)
+
+	if len(matches) > 1 {
+		return fmt.Errorf("set_anchor matched %d nodes, anchor %q must be unique", len(matches), rule.Anchor)
+	}
+
+	node := matches[0].Node
+	if node.Anchor == rule.Anchor {
+		return nil
+	}
+	if owner := findAnchor(root, rule.Anchor); owner != nil {
+		return fmt.Errorf("anchor %q already defined at line %d", rule.Anchor, owner.Line)
+	}
+
+	old := node.Anchor
+	node.Anchor = rule.Anchor
+	walk(root, func(n *yaml.Node) {
+		if n.Kind == yaml.AliasNode && n.Alias == node {
+			n.Value = rule.Anchor
+		}
+	})
+	res.Changed = 1
+	if e.track {
+		// 锚点修改不影响节点的值，记录锚点名
+		res.Changes = append(res.Changes, Change{Path: matches[0].Path, Old: old, New: rule.Anchor})
+	}
+
+	return nil
+}
+
+// deduplicateAsAnchor 将匹配节点中结构相同的子树合并：
+// 每组第一次出现的节点设置锚点，之后出现的位置替换为别名
+func (e *Engine) deduplicateAsAnchor(root *yaml.Node, rule *Rule, res *Result) error {
+	matches, err := e.find(root, rule)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	res.Matched = len(matches)
+
+	base := rule.Anchor
+	if base == "" {
+		base = defaultAnchor
+	}
+
+	// 按首次出现顺序分组
+	var groups [][]*path.Match
+	for _, m := range matches {
+		placed := false
+		for i, g := range groups {
+			if equalNodes(g[0].Node, m.Node) {
+				groups[i] = append(g, m)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			groups = append(groups, []*path.Match{m})
+		}
+	}
+
+	seq := 0
+	for _, g := range groups {
+		if len(g) < 2 {
+			continue
+		}
+
+		first := g[0].Node
+		if first.Anchor == "" {
+			seq++
+			name := base
+			if seq > 1 {
+				name = base + "-" + strconv.Itoa(seq)
+			}
+			if findAnchor(root, name) != nil {
+				return fmt.Errorf("anchor %q already defined", name)
+			}
+			first.Anchor = name
+		}
+
+		for _, m := range g[1:] {
+			if m.Parent == nil {
+				continue
+			}
+			old := e.valueOf(m.Parent.Content[m.Index])
+			m.Parent.Content[m.Index] = &yaml.Node{Kind: yaml.AliasNode, Value: first.Anchor, Alias: first}
+			if e.track {
+				res.Changes = append(res.Changes, Change{Path: m.Path, Old: old, New: "*" + first.Anchor})
+			}
+			res.Changed++
+		}
+	}
+
+	return nil
+}
+
+// resolveAliases 将匹配节点及其子树中的别名展开为锚点内容的独立副本
+func (e *Engine) resolveAliases(root *yaml.Node, rule *Rule, res *Result) error {
+	matches, err := e.find(root, rule)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	res.Matched = len(matches)
+
+	for _, m := range matches {
+		// 匹配经由别名到达时，父节点中的位置仍是别名本身
+		target := m.Node
+		changed := res.Changed
+		if m.Parent != nil {
+			if m.Parent.Content[m.Index].Kind == yaml.AliasNode {
+				m.Parent.Content[m.Index] = expandAlias(m.Parent.Content[m.Index])
+				res.Changed++
+			}
+			target = m.Parent.Content[m.Index]
+		}
+
+		walk(target, func(n *yaml.Node) {
+			for i, child := range n.Content {
+				if child.Kind == yaml.AliasNode {
+					n.Content[i] = expandAlias(child)
+					res.Changed++
+				}
+			}
+		})
+
+		// 展开前后的值相同，只记录展开后的内容
+		if res.Changed > changed {
+			e.record(res, m.Path, nil, target)
+		}
+	}
+
+	return nil
+}
+
+// expandAlias 返回别名指向内容的副本，副本内嵌套的别名一并展开
+func expandAlias(alias *yaml.Node) *yaml.Node {
+	cp := path.CopyNode(alias.Alias)
+	cp.Anchor = ""
+	walk(cp, func(n *yaml.Node) {
+		for i, child := range n.Content {
+			if child.Kind == yaml.AliasNode {
+				n.Content[i] = expandAlias(child)
+			}
+		}
+	})
+	return cp
+}
+
+// walk 先序遍历节点树，不进入别名
+func walk(node *yaml.Node, fn func(*yaml.Node)) {
+	fn(node)
+	for _, child := range node.Content {
+		walk(child, fn)
+	}
+}
+
+// findAnchor 返回定义了指定锚点的节点
+func findAnchor(root *yaml.Node, name string) *yaml.Node {
+	var owner *yaml.Node
+	walk(root, func(n *yaml.Node) {
+		if owner == nil && n.Kind != yaml.AliasNode && n.Anchor == name {
+			owner = n
+		}
+	})
+	return owner
+}
+
+// equalNodes 结构比较两个节点（忽略样式、注释与位置）
+func equalNodes(a, b *yaml.Node) bool {
+	if a.Kind == yaml.AliasNode {
+		a = a.Alias
+	}
+	if b.Kind == yaml.AliasNode {
+		b = b.Alias
+	}
+	if a == b {
+		return true
+	}
+	if a.Kind != b.Kind || a.ShortTag() != b.ShortTag() || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+	for i := range a.Content {
+		if !equalNodes(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Cipher 字段级加解密后端，可替换为 age、KMS 等实现
+type Cipher interface {
+	// Name 写入密文信封，解密时用于校验后端是否匹配
+	Name() string
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// ErrNoCipher 未配置加解密后端
+var ErrNoCipher = errors.New("no cipher configured")
+
+// 密文信封格式: ENC[<cipher>,data:<base64>,type:<tag>]
+const encPrefix = "ENC["
+
+// SetCipher 设置 encrypt/decrypt 使用的后端
+func (e *Engine) SetCipher(c Cipher) {
+	e.cipher = c
+}
+
+// encrypt 加密匹配的标量值，已加密的值保持不变
+func (e *Engine) encrypt(root *yaml.Node, rule *Rule, res *Result) error {
+	if e.cipher == nil {
+		return ErrNoCipher
+	}
+
+	matches, err := e.find(root, rule)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	res.Matched = len(matches)
+
+	for _, m := range matches {
+		node := m.Node
+		if node.Kind != yaml.ScalarNode || IsEncrypted(node.Value) {
+			continue
+		}
+
+		data, err := e.cipher.Encrypt([]byte(node.Value))
+		if err != nil {
+			return fmt.Errorf("encrypt: %w", err)
+		}
+
+		node.Value = fmt.Sprintf("%s%s,data:%s,type:%s]",
+			encPrefix, e.cipher.Name(), base64.StdEncoding.EncodeToString(data), node.ShortTag())
+		node.Tag = "!!str"
+		node.Style = 0
+		e.record(res, m.Path, nil, node)
+		res.Changed++
+	}
+
+	return nil
+}
+
+// decrypt 解密匹配的标量值，并恢复加密前的类型标签
+func (e *Engine) decrypt(root *yaml.Node, rule *Rule, res *Result) error {
+	if e.cipher == nil {
+		return ErrNoCipher
+	}
+
+	matches, err := e.find(root, rule)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	res.Matched = len(matches)
+
+	for _, m := range matches {
+		node := m.Node
+		if node.Kind != yaml.ScalarNode || !IsEncrypted(node.Value) {
+			continue
+		}
+
+		name, data, tag, err := parseEnvelope(node.Value)
+		if err != nil {
+			return err
+		}
+		if name != e.cipher.Name() {
+			return fmt.Errorf("value encrypted with %q, configured cipher is %q", name, e.cipher.Name())
+		}
+
+		plaintext, err := e.cipher.Decrypt(data)
+		if err != nil {
+			return fmt.Errorf("decrypt: %w", err)
+		}
+
+		old := e.valueOf(node)
+		node.Value = string(plaintext)
+		node.Tag = tag
+		node.Style = 0
+		e.record(res, m.Path, old, nil)
+		res.Changed++
+	}
+
+	return nil
+}
+
+// IsEncrypted 判断值是否为 encrypt 产生的密文
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encPrefix) && strings.HasSuffix(value, "]")
+}
+
+// parseEnvelope 解析密文信封
+func parseEnvelope(value string) (name string, data []byte, tag string, err error) {
+	body := strings.TrimSuffix(strings.TrimPrefix(value, encPrefix), "]")
+	parts := strings.Split(body, ",")
+	if len(parts) != 3 || !strings.HasPrefix(parts[1], "data:") || !strings.HasPrefix(parts[2], "type:") {
+		return "", nil, "", fmt.Errorf("malformed encrypted value")
+	}
+
+	data, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(parts[1], "data:"))
+	if err != nil {
+		return "", nil, "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	return parts[0], data, strings.TrimPrefix(parts[2], "type:"), nil
+}
+
+// AESCipher 内置的 AES-256-GCM 后端，nonce 前置于密文
+type AESCipher struct {
+	aead cipher.AEAD
+}
+
+// NewAESCipher 使用 32 字节密钥创建 AES-256-GCM 后端
+func NewAESCipher(key []byte) (*AESCipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("aes key must be 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESCipher{aead: aead}, nil
+}
+
+func (c *AESCipher) Name() string {
+	return "aes256gcm"
+}
+
+func (c *AESCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (c *AESCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(ciphertext) < n {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	return c.aead.Open(nil, ciphertext[:n], ciphertext[n:], nil)
+}
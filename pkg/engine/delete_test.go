@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/path"
+)
+
+func TestDelete(t *testing.T) {
+	const pod = `metadata:
+  name: app
+  annotations:
+    sidecar.istio.io/inject: "false"
+    team: web
+spec:
+  containers:
+    - name: app
+      args: [--port=80, --debug]
+      env:
+        - name: DEBUG
+          value: "1"
+        - name: MODE
+          value: prod
+      livenessProbe:
+        httpGet:
+          path: /healthz
+`
+	tests := []struct {
+		name    string
+		path    string
+		prune   bool
+		changed int
+		wantAt  string // 删除后检查的子树
+		want    string
+	}{
+		{
+			name:    "sequence element",
+			path:    "spec.containers[0].env[name=DEBUG]",
+			changed: 1,
+			wantAt:  "spec.containers[0].env",
+			want:    "- name: MODE\n  value: prod\n",
+		},
+		{
+			name:    "map value removes the key",
+			path:    "spec.containers[name=app].livenessProbe",
+			changed: 1,
+			wantAt:  "spec.containers[0]",
+			want: `name: app
+args: [--port=80, --debug]
+env:
+    - name: DEBUG
+      value: "1"
+    - name: MODE
+      value: prod
+`,
+		},
+		{
+			name:    "quoted map key",
+			path:    `metadata.annotations."sidecar.istio.io/inject"`,
+			changed: 1,
+			wantAt:  "metadata.annotations",
+			want:    "team: web\n",
+		},
+		{
+			name:    "scalar sequence element",
+			path:    "spec.containers[0].args[1]",
+			changed: 1,
+			wantAt:  "spec.containers[0].args",
+			want:    "[--port=80]\n",
+		},
+		{
+			name:    "scalar map value",
+			path:    "metadata.name",
+			changed: 1,
+			wantAt:  "metadata",
+			want:    "annotations:\n    sidecar.istio.io/inject: \"false\"\n    team: web\n",
+		},
+		{
+			name:    "emptied sequence is kept",
+			path:    "spec.containers[0].env[*]",
+			changed: 2,
+			wantAt:  "spec.containers[0].env",
+			want:    "[]\n",
+		},
+		{
+			name:    "prune emptied parents",
+			path:    "spec.containers[0].env[*]",
+			prune:   true,
+			changed: 3,
+			wantAt:  "spec.containers[0]",
+			want: `name: app
+args: [--port=80, --debug]
+livenessProbe:
+    httpGet:
+        path: /healthz
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(pod), &doc); err != nil {
+				t.Fatal(err)
+			}
+			e := NewEngine()
+			res, err := e.Apply(&doc, &Rule{Action: ActionDelete, Path: tt.path, PruneEmpty: tt.prune})
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if res.Changed != tt.changed {
+				t.Errorf("Changed = %d, want %d", res.Changed, tt.changed)
+			}
+
+			p, err := path.Parse(tt.wantAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			matches, err := e.navigator.Find(&doc, p)
+			if err != nil || len(matches) != 1 {
+				t.Fatalf("find %s: %v (%d matches)", tt.wantAt, err, len(matches))
+			}
+			got, err := yaml.Marshal(matches[0].Node)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("%s after delete =\n%s\nwant\n%s", tt.wantAt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteNotFound(t *testing.T) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte("a: 1\n"), &doc); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewEngine().Apply(&doc, &Rule{Action: ActionDelete, Path: "b"})
+	if err == nil {
+		t.Fatal("Apply() error = nil, want not found")
+	}
+	if out, _ := yaml.Marshal(&doc); !strings.Contains(string(out), "a: 1") {
+		t.Errorf("document changed after failed delete: %s", out)
+	}
+}
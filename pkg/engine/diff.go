@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/path"
+)
+
+// DiffNodes 按结构比较两棵 YAML 节点树，返回值不同的位置
+// Change.Old/New 为节点解码后的值：新增时 Old 为 nil，删除时 New 为 nil
+//
+// 比较规则：
+//   - 文档节点按其内容比较，别名按指向的锚点内容比较
+//   - 只比较值：注释、引号、flow 风格、锚点名的变化不算修改
+//   - mapping 按键名对应，sequence 按内容对齐后逐个比较，插入或删除元素不会使其后所有元素都报告为修改
+//
+// 路径格式同规则路径；删除的位置使用旧树中的下标，其余使用新树中的下标
+func DiffNodes(old, new *yaml.Node) []Change {
+	var changes []Change
+	diffNode(unwrap(old), unwrap(new), "", &changes)
+	return changes
+}
+
+// unwrap 返回文档节点的内容与别名指向的锚点
+func unwrap(node *yaml.Node) *yaml.Node {
+	for node != nil {
+		switch {
+		case node.Kind == yaml.DocumentNode && len(node.Content) > 0:
+			node = node.Content[0]
+		case node.Kind == yaml.AliasNode && node.Alias != nil:
+			node = node.Alias
+		default:
+			return node
+		}
+	}
+	return nil
+}
+
+// decodeValue 返回节点解码后的值，无法解码时返回原始文本
+func decodeValue(node *yaml.Node) interface{} {
+	if node == nil {
+		return nil
+	}
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return node.Value
+	}
+	return v
+}
+
+// diffNode 比较 p 处的两个节点（已 unwrap），把差异追加到 changes
+func diffNode(old, new *yaml.Node, p string, changes *[]Change) {
+	switch {
+	case old == nil && new == nil:
+		return
+	case old == nil || new == nil || old.Kind != new.Kind:
+		*changes = append(*changes, Change{Path: p, Old: decodeValue(old), New: decodeValue(new)})
+	case old.Kind == yaml.MappingNode:
+		diffMapping(old, new, p, changes)
+	case old.Kind == yaml.SequenceNode:
+		diffSequence(old, new, p, changes)
+	case old.Tag != new.Tag || old.Value != new.Value:
+		*changes = append(*changes, Change{Path: p, Old: decodeValue(old), New: decodeValue(new)})
+	}
+}
+
+// diffMapping 先按旧键顺序报告修改与删除，再按新键顺序报告新增
+func diffMapping(old, new *yaml.Node, p string, changes *[]Change) {
+	for i := 0; i+1 < len(old.Content); i += 2 {
+		key := old.Content[i].Value
+		value := mappingValue(new, key)
+		if value == nil {
+			*changes = append(*changes, Change{Path: path.FieldPath(p, key), Old: decodeValue(old.Content[i+1])})
+			continue
+		}
+		diffNode(unwrap(old.Content[i+1]), unwrap(value), path.FieldPath(p, key), changes)
+	}
+	for i := 0; i+1 < len(new.Content); i += 2 {
+		key := new.Content[i].Value
+		if mappingValue(old, key) == nil {
+			*changes = append(*changes, Change{Path: path.FieldPath(p, key), New: decodeValue(new.Content[i+1])})
+		}
+	}
+}
+
+// diffSequence 用最长公共子序列对齐两边的元素，相同的元素跳过；
+// 两个公共元素之间被删除与插入的元素按顺序配对递归比较，多出的报告为删除或新增
+func diffSequence(old, new *yaml.Node, p string, changes *[]Change) {
+	a, b := old.Content, new.Content
+	// 去掉相同的首尾，只对中间部分求最长公共子序列
+	lo := 0
+	for lo < len(a) && lo < len(b) && sameNode(a[lo], b[lo]) {
+		lo++
+	}
+	ha, hb := len(a), len(b)
+	for ha > lo && hb > lo && sameNode(a[ha-1], b[hb-1]) {
+		ha, hb = ha-1, hb-1
+	}
+	ma, mb := a[lo:ha], b[lo:hb]
+
+	// lcs[i][j] 为 ma[i:] 与 mb[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if sameNode(ma[i], mb[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var dels, ins []int // 当前公共元素之前被删除（旧下标）与插入（新下标）的元素
+	flush := func() {
+		for k := 0; k < max(len(dels), len(ins)); k++ {
+			switch {
+			case k >= len(ins):
+				*changes = append(*changes, Change{Path: path.ElemPath(p, dels[k]), Old: decodeValue(a[dels[k]])})
+			case k >= len(dels):
+				*changes = append(*changes, Change{Path: path.ElemPath(p, ins[k]), New: decodeValue(b[ins[k]])})
+			default:
+				diffNode(unwrap(a[dels[k]]), unwrap(b[ins[k]]), path.ElemPath(p, ins[k]), changes)
+			}
+		}
+		dels, ins = dels[:0], ins[:0]
+	}
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && lcs[i][j] == lcs[i+1][j+1]+1 && sameNode(ma[i], mb[j]):
+			flush()
+			i, j = i+1, j+1
+		case j == len(mb) || (i < len(ma) && lcs[i+1][j] >= lcs[i][j+1]):
+			dels = append(dels, lo+i)
+			i++
+		default:
+			ins = append(ins, lo+j)
+			j++
+		}
+	}
+	flush()
+}
+
+// sameNode 两个节点的值是否完全相同，比较方式同 DiffNodes
+func sameNode(a, b *yaml.Node) bool {
+	a, b = unwrap(a), unwrap(b)
+	switch {
+	case a == nil || b == nil:
+		return a == b
+	case a.Kind != b.Kind || len(a.Content) != len(b.Content):
+		return false
+	case a.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(a.Content); i += 2 {
+			if !sameNode(a.Content[i+1], mappingValue(b, a.Content[i].Value)) {
+				return false
+			}
+		}
+		return true
+	case a.Kind == yaml.SequenceNode:
+		for i := range a.Content {
+			if !sameNode(a.Content[i], b.Content[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a.Tag == b.Tag && a.Value == b.Value
+	}
+}
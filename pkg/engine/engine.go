@@ -1,9 +1,16 @@
 package engine
 
 import (
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/dlclark/regexp2"
+	"github.com/dlclark/regexp2/syntax"
 	"gopkg.in/yaml.v3"
 	"github.com/glesirok/yamleditor/pkg/path"
 )
@@ -11,46 +18,287 @@ import (
 // Engine 执行 YAML 修改操作
 type Engine struct {
 	navigator *path.Navigator
+	cipher    Cipher              // encrypt/decrypt 后端
+	vars      map[string]*Capture // 当前文档内 capture 得到的变量
+	params    map[string]string   // 规则文件参数，每个文档开始时作为变量预置
+	file      string              // 当前处理的文件，供模板 .File 使用
+	track     bool                // 是否在 Result.Changes 中记录逐节点修改
+
+	strictTypes bool              // 写入值的类型与原节点不同时报错，见 SetStrictTypes
+	environ     map[string]string // 非 nil 时代替进程环境变量，见 SetEnviron
+
+	matchTimeout time.Duration // 单次正则匹配的超时，0 表示不限
+	ruleTimeout  time.Duration // 单条规则在一个文档上正则匹配的总耗时上限，0 表示不限
+	deadline     time.Time     // 当前规则的截止时刻，由 Apply 按 ruleTimeout 设置
+
+	warnings []string // 当前规则查找节点时产生的警告，Apply 结束时移入 Result
+	missing  error    // 当前规则因 continue_on_not_found 忽略的未找到错误，Apply 结束时移入 Result
+
+	whens map[string]*When // 已解析的 when 表达式，按原文缓存
 }
 
 func NewEngine() *Engine {
 	return &Engine{
 		navigator: &path.Navigator{},
+		vars:      map[string]*Capture{},
+	}
+}
+
+// Reset 清空文档级状态（capture 变量），处理新文档前调用
+// 参数在清空后重新预置为变量
+func (e *Engine) Reset() {
+	e.vars = make(map[string]*Capture, len(e.params))
+	for name, v := range e.params {
+		e.vars[name] = &Capture{Value: v, Values: []string{v}, Count: 1, Groups: map[string]string{}}
+	}
+}
+
+// SaveVars 返回当前 capture 变量的副本，配合 RestoreVars 在撤销规则时回滚变量
+// capture 每次写入新的 Capture，不修改已有的值，复制映射即可
+func (e *Engine) SaveVars() map[string]*Capture {
+	vars := make(map[string]*Capture, len(e.vars))
+	for name, c := range e.vars {
+		vars[name] = c
+	}
+	return vars
+}
+
+// RestoreVars 恢复 SaveVars 保存的变量
+func (e *Engine) RestoreVars(vars map[string]*Capture) {
+	e.vars = vars
+}
+
+// SetParams 设置规则参数，下次 Reset 起生效
+func (e *Engine) SetParams(params map[string]string) {
+	e.params = params
+}
+
+// SetTimeouts 设置正则匹配超时与单条规则的时间预算，0 表示不限
+// 超时后规则返回 ErrTimeout，避免灾难性回溯的正则拖住整个批次
+func (e *Engine) SetTimeouts(match, rule time.Duration) {
+	e.matchTimeout = match
+	e.ruleTimeout = rule
+}
+
+// regexTimeout 返回当前规则下一次正则匹配可用的超时
+func (e *Engine) regexTimeout() (time.Duration, error) {
+	return path.RegexTimeout(e.matchTimeout, e.deadline)
+}
+
+// SetFile 设置当前处理的文件路径
+func (e *Engine) SetFile(file string) {
+	e.file = file
+}
+
+// SetTrackChanges 设置是否记录逐节点的修改（Result.Changes）
+// 记录需要解码修改前后的节点，默认关闭
+func (e *Engine) SetTrackChanges(track bool) {
+	e.track = track
+}
+
+// valueOf 未开启记录时返回 nil，否则返回节点解码后的值，用于在修改前保存旧值
+func (e *Engine) valueOf(node *yaml.Node) interface{} {
+	if !e.track {
+		return nil
 	}
+	return decodeValue(node)
 }
 
-// Apply 应用规则到 YAML 文档
-func (e *Engine) Apply(root *yaml.Node, rule *Rule) error {
+// record 记录一次修改，new 为 nil 表示节点被删除或不记录新值
+func (e *Engine) record(res *Result, path string, old interface{}, new *yaml.Node) {
+	if e.track {
+		res.Changes = append(res.Changes, Change{Path: path, Old: old, New: e.valueOf(new)})
+	}
+}
+
+// Apply 应用规则到 YAML 文档，返回本次执行的匹配与修改统计
+func (e *Engine) Apply(root *yaml.Node, rule *Rule) (*Result, error) {
+	e.deadline = time.Time{}
+	if e.ruleTimeout > 0 {
+		e.deadline = time.Now().Add(e.ruleTimeout)
+	}
+	e.warnings, e.missing = nil, nil
+
+	ok, err := e.selects(root, rule)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return &Result{Inapplicable: true}, nil
+	}
+
+	rule, err = e.expand(root, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	// 在修改前记录匹配内容，后续规则才能读到"旧值"
+	if rule.Capture != "" {
+		if err := e.capture(root, rule); err != nil {
+			return nil, err
+		}
+	}
+
+	res := &Result{}
 	switch rule.Action {
 	case ActionReplace:
-		return e.replace(root, rule)
+		err = e.replace(root, rule, res)
+	case ActionAdd:
+		err = e.add(root, rule, res)
+	case ActionAppend, ActionPrepend:
+		err = e.insert(root, rule, res)
 	case ActionDelete:
-		return e.delete(root, rule)
+		err = e.delete(root, rule, res)
+	case ActionMerge:
+		err = e.merge(root, rule, res)
+	case ActionRenameKey:
+		err = e.renameKey(root, rule, res)
+	case ActionRenameKeys:
+		err = e.renameKeys(root, rule, res)
+	case ActionQuoteStyle:
+		err = e.quoteStyle(root, rule, res)
+	case ActionSortKeys:
+		err = e.sortKeys(root, rule, res)
+	case ActionNormalize:
+		err = e.normalize(root, rule, res)
 	case ActionRegexReplace:
-		return e.regexReplace(root, rule)
+		err = e.regexReplace(root, rule, res)
+	case ActionIncrement:
+		err = e.increment(root, rule, res)
+	case ActionTransform:
+		err = e.transformStrings(root, rule, res)
+	case ActionRedact:
+		err = e.redact(root, rule, res)
+	case ActionEncrypt:
+		err = e.encrypt(root, rule, res)
+	case ActionDecrypt:
+		err = e.decrypt(root, rule, res)
+	case ActionSetImageTag, ActionSetImageRegistry, ActionSetImageDigest:
+		err = e.setImage(root, rule, res)
+	case ActionSetAnchor:
+		err = e.setAnchor(root, rule, res)
+	case ActionDeduplicateAsAnchor:
+		err = e.deduplicateAsAnchor(root, rule, res)
+	case ActionResolveAliases:
+		err = e.resolveAliases(root, rule, res)
 	default:
-		return fmt.Errorf("unknown action: %s", rule.Action)
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAction, rule.Action)
 	}
+	if err != nil {
+		return nil, err
+	}
+	res.Warnings, res.Missing = e.warnings, e.missing
+	return res, nil
 }
 
-// replace 替换节点（支持对象、字段、标量）
-func (e *Engine) replace(root *yaml.Node, rule *Rule) error {
+// find 解析规则路径并查找匹配节点，供修改匹配节点的操作使用
+// 未找到节点时：continue_on_not_found 返回空列表，否则返回 ErrNotFoundNodes
+func (e *Engine) find(root *yaml.Node, rule *Rule) ([]*path.Match, error) {
+	matches, _, err := e.findWithMisses(root, rule)
+	return matches, err
+}
+
+// findOrDocument 同 find，path 为空时匹配整个文档
+func (e *Engine) findOrDocument(root *yaml.Node, rule *Rule) ([]*path.Match, error) {
+	if rule.Path == "" {
+		matches := []*path.Match{{Node: documentBody(root)}}
+		return matches, e.checkShared(root, rule, matches)
+	}
+	return e.find(root, rule)
+}
+
+// findWithMisses 同 find，另外返回因剩余路径无法解析而跳过的元素
+// targets: resolved_copies 时匹配路径上的别名在这里替换为副本，见 path.Match.Materialize
+func (e *Engine) findWithMisses(root *yaml.Node, rule *Rule) ([]*path.Match, []string, error) {
+	matches, misses, err := e.search(root, rule)
+	for _, m := range matches {
+		m.Materialize()
+	}
+	return matches, misses, err
+}
+
+// search 同 findWithMisses，但不修改文档，供只读取匹配内容的 capture 使用
+func (e *Engine) search(root *yaml.Node, rule *Rule) ([]*path.Match, []string, error) {
 	p, err := path.Parse(rule.Path)
 	if err != nil {
-		return fmt.Errorf("parse path: %w", err)
+		return nil, nil, fmt.Errorf("parse path: %w", err)
 	}
 
-	nodes, err := e.navigator.Find(root, p)
+	matches, misses, err := e.navigatorFor(rule).FindWithMisses(root, p)
 	if err != nil {
-		return fmt.Errorf("find nodes: %w", err)
+		err = fmt.Errorf("find nodes: %w", err)
+		// 路径上缺少字段或条件没有命中的元素时按未匹配处理，超时等其他错误照常返回
+		if rule.ContinueOnNotFound && (errors.Is(err, ErrPathNotFound) || errors.Is(err, ErrNoMatch)) {
+			e.missing = err
+			return nil, misses, nil
+		}
+		return nil, nil, err
+	}
+	e.warnFolded(matches)
+	if err := e.checkShared(root, rule, matches); err != nil {
+		return nil, nil, err
 	}
 
-	if len(nodes) == 0 {
-		if rule.ContinueOnNotFound {
-			return nil
+	if len(matches) == 0 {
+		if !rule.ContinueOnNotFound {
+			return nil, misses, ErrNotFoundNodes
 		}
-		return ErrNotFoundNodes
+		e.missing = ErrNotFoundNodes
 	}
+	return matches, misses, nil
+}
+
+// warnFolded 为忽略大小写命中的字段记录警告，同一字段只记录一次
+func (e *Engine) warnFolded(matches []*path.Match) {
+	for _, m := range matches {
+		for _, f := range m.Folded {
+			e.warn("key " + f + " matched case-insensitively")
+		}
+	}
+}
+
+// warn 记录一条警告，相同的警告只记录一次；Strict 时处理器把警告升级为错误
+func (e *Engine) warn(w string) {
+	if !slices.Contains(e.warnings, w) {
+		e.warnings = append(e.warnings, w)
+	}
+}
+
+// warnDropped 替换值丢弃了旧值中的注释时记录警告
+func (e *Engine) warnDropped(p string, dropped int) {
+	if dropped > 0 {
+		e.warn(fmt.Sprintf("%s: %d comment(s) dropped with the replaced value", p, dropped))
+	}
+}
+
+// navigatorFor 按规则选项返回导航器，无特殊选项时复用默认导航器
+func (e *Engine) navigatorFor(rule *Rule) *path.Navigator {
+	nav := path.Navigator{
+		ExpandAliases:   rule.Targets == TargetsResolvedCopies,
+		CaseInsensitive: rule.Options.CaseInsensitive,
+		Trim:            rule.Options.Trim,
+		FoldKeys:        rule.Options.CaseInsensitiveKeys,
+		CreateMissing:   rule.CreateMissing || rule.Action == ActionAdd,
+		Where:           rule.Where,
+		MatchTimeout:    e.matchTimeout,
+		Deadline:        e.deadline,
+	}
+	if nav == *e.navigator {
+		return e.navigator
+	}
+	return &nav
+}
+
+// replace 替换节点（支持对象、字段、标量）
+func (e *Engine) replace(root *yaml.Node, rule *Rule, res *Result) error {
+	matches, misses, err := e.findWithMisses(root, rule)
+	if !rule.SkipMissing {
+		res.Skipped = misses
+	}
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	res.Matched = len(matches)
 
 	// 将 Value 编码为 yaml.Node
 	newNode := &yaml.Node{}
@@ -58,124 +306,196 @@ func (e *Engine) replace(root *yaml.Node, rule *Rule) error {
 		return fmt.Errorf("encode value: %w", err)
 	}
 
-	// 替换所有匹配的节点
-	for _, node := range nodes {
-		*node = *newNode
+	// 先检查所有节点的类型，报错时文档不变
+	values := make([]*yaml.Node, len(matches))
+	for i, m := range matches {
+		if values[i], err = e.checkType(m.Node, newNode, m.Path, rule); err != nil {
+			return err
+		}
+	}
+
+	// 原地更新节点，保留原节点上的注释与格式
+	for i, m := range matches {
+		old := e.valueOf(m.Node)
+		e.warnDropped(m.Path, replaceNode(m.Node, values[i]))
+		applyStyle(m.Node, rule.Style)
+		e.record(res, m.Path, old, m.Node)
 	}
+	res.Changed = len(matches)
 
 	return nil
 }
 
 // delete 删除节点
-func (e *Engine) delete(root *yaml.Node, rule *Rule) error {
-	p, err := path.Parse(rule.Path)
-	if err != nil {
-		return fmt.Errorf("parse path: %w", err)
+// 直接从匹配结果记录的父节点中移除：mapping 中删除整个键值对，sequence 中删除元素
+// prune_empty 时再删除因此变空的父节点，计入 Changed
+func (e *Engine) delete(root *yaml.Node, rule *Rule, res *Result) error {
+	matches, err := e.find(root, rule)
+	if err != nil || len(matches) == 0 {
+		return err
 	}
+	res.Matched = len(matches)
 
-	// 查找节点
-	nodes, err := e.navigator.Find(root, p)
-	if err != nil {
-		return fmt.Errorf("find nodes: %w", err)
+	for _, m := range matches {
+		e.record(res, m.Path, e.valueOf(m.Node), nil)
 	}
-
-	if len(nodes) == 0 {
-		if rule.ContinueOnNotFound {
-			return nil
+	removeMatches(matches)
+	res.Changed = len(matches)
+
+	if rule.PruneEmpty {
+		emptied := map[*yaml.Node]bool{}
+		for _, m := range matches {
+			if m.Parent != nil && len(m.Parent.Content) == 0 {
+				emptied[m.Parent] = true
+			}
 		}
-		return ErrNotFoundNodes
-	}
-
-	// 删除节点需要从父节点操作
-	for _, node := range nodes {
-		if err := e.deleteNode(root, node); err != nil {
-			return err
+		if len(emptied) > 0 {
+			res.Changed += e.pruneEmpty(root, emptied, res)
 		}
 	}
 
 	return nil
 }
 
-// deleteNode 从树中删除节点
-func (e *Engine) deleteNode(root, target *yaml.Node) error {
-	return e.deleteNodeRecursive(root, target)
-}
-
-// deleteNodeRecursive 递归查找并删除节点
-func (e *Engine) deleteNodeRecursive(node, target *yaml.Node) error {
-	if node.Kind == yaml.DocumentNode {
-		for _, child := range node.Content {
-			if err := e.deleteNodeRecursive(child, target); err != nil {
-				return err
-			}
+// removeMatches 按父节点分组，一次性重建每个父节点的 Content，避免删除导致下标错位
+func removeMatches(matches []*path.Match) {
+	removed := map[*yaml.Node]map[int]bool{}
+	var parents []*yaml.Node
+	for _, m := range matches {
+		if m.Parent == nil {
+			continue
 		}
-		return nil
+		if removed[m.Parent] == nil {
+			removed[m.Parent] = map[int]bool{}
+			parents = append(parents, m.Parent)
+		}
+		removed[m.Parent][m.Index] = true
 	}
 
-	if node.Kind == yaml.MappingNode {
-		// 检查值是否是目标
-		newContent := []*yaml.Node{}
-		for i := 0; i < len(node.Content); i += 2 {
-			keyNode := node.Content[i]
-			valueNode := node.Content[i+1]
+	for _, parent := range parents {
+		step := 1
+		if parent.Kind == yaml.MappingNode {
+			step = 2 // [key, value]，Index 指向 value
+		}
 
-			if valueNode == target {
-				// 跳过这个键值对（删除）
+		newContent := make([]*yaml.Node, 0, len(parent.Content))
+		for i := 0; i < len(parent.Content); i += step {
+			if removed[parent][i+step-1] {
 				continue
 			}
+			newContent = append(newContent, parent.Content[i:i+step]...)
+		}
+		parent.Content = newContent
+	}
+}
 
-			newContent = append(newContent, keyNode, valueNode)
+// renameKey 将路径指向的字段改名为 value，值、注释与字段顺序不变
+// 字段已是新名时只计入 Matched；同一 mapping 中已有新键名时报错，避免产生重复键
+func (e *Engine) renameKey(root *yaml.Node, rule *Rule, res *Result) error {
+	matches, err := e.find(root, rule)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	res.Matched = len(matches)
 
-			// 递归查找子节点
-			if err := e.deleteNodeRecursive(valueNode, target); err != nil {
-				return err
-			}
+	name, ok := rule.Value.(string)
+	if !ok {
+		return &ErrTypeMismatch{Expected: "string", Got: fmt.Sprintf("%T", rule.Value), Path: rule.Path}
+	}
+
+	for _, m := range matches {
+		if m.Key == nil {
+			return &ErrTypeMismatch{Expected: "mapping field", Got: path.KindName(m.Parent.Kind) + " element", Path: m.Path}
 		}
-		node.Content = newContent
-		return nil
+		if m.Key.Value == name {
+			continue
+		}
+		if mappingValue(m.Parent, name) != nil {
+			return fmt.Errorf("rename %s: %w: %q", m.Path, ErrKeyExists, name)
+		}
+		old := m.Key.Value
+		m.Key.Value, m.Key.Tag = name, "!!str"
+		if e.track {
+			res.Changes = append(res.Changes, Change{Path: m.Path, Old: old, New: name})
+		}
+		res.Changed++
 	}
+	return nil
+}
 
-	if node.Kind == yaml.SequenceNode {
-		// 检查元素是否是目标
-		newContent := []*yaml.Node{}
-		for _, elem := range node.Content {
-			if elem == target {
-				// 跳过这个元素（删除）
-				continue
-			}
+// add 在路径不存在时创建字段并写入值，缺少的中间层逐层创建为 mapping
+// 路径已存在的节点保持不变，只计入 Matched
+func (e *Engine) add(root *yaml.Node, rule *Rule, res *Result) error {
+	matches, misses, err := e.findWithMisses(root, rule)
+	res.Skipped = misses
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	res.Matched = len(matches)
 
-			newContent = append(newContent, elem)
+	newNode := &yaml.Node{}
+	if err := newNode.Encode(rule.Value); err != nil {
+		return fmt.Errorf("encode value: %w", err)
+	}
+	applyStyle(newNode, rule.Style)
 
-			// 递归查找子节点
-			if err := e.deleteNodeRecursive(elem, target); err != nil {
-				return err
-			}
+	for _, m := range matches {
+		if !m.Created {
+			continue
 		}
-		node.Content = newContent
-		return nil
+		*m.Node = *path.CopyTree(newNode)
+		e.record(res, m.Path, nil, m.Node)
+		res.Changed++
 	}
-
 	return nil
 }
 
-// regexReplace 正则替换字符串值
-func (e *Engine) regexReplace(root *yaml.Node, rule *Rule) error {
-	p, err := path.Parse(rule.Path)
-	if err != nil {
-		return fmt.Errorf("parse path: %w", err)
+// insert 向路径指向的序列插入 value：append 追加到末尾（或 insert_at 指定的位置），
+// prepend 插到开头；null 节点视为空序列
+func (e *Engine) insert(root *yaml.Node, rule *Rule, res *Result) error {
+	matches, err := e.find(root, rule)
+	if err != nil || len(matches) == 0 {
+		return err
 	}
+	res.Matched = len(matches)
 
-	nodes, err := e.navigator.Find(root, p)
-	if err != nil {
-		return fmt.Errorf("find nodes: %w", err)
+	newNode := &yaml.Node{}
+	if err := newNode.Encode(rule.Value); err != nil {
+		return fmt.Errorf("encode value: %w", err)
 	}
+	applyStyle(newNode, rule.Style)
+
+	for _, m := range matches {
+		node := m.Node
+		if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+			node.Kind, node.Tag, node.Value, node.Style = yaml.SequenceNode, "!!seq", "", 0
+		}
+		if node.Kind != yaml.SequenceNode {
+			return &ErrTypeMismatch{Expected: "sequence", Got: path.KindName(node.Kind), Path: m.Path}
+		}
 
-	if len(nodes) == 0 {
-		if rule.ContinueOnNotFound {
-			return nil
+		idx := len(node.Content)
+		switch {
+		case rule.Action == ActionPrepend:
+			idx = 0
+		case rule.InsertAt != nil:
+			idx = min(*rule.InsertAt, idx)
 		}
-		return ErrNotFoundNodes
+		elem := path.CopyTree(newNode)
+		node.Content = slices.Insert(node.Content, idx, elem)
+		e.record(res, fmt.Sprintf("%s[%d]", m.Path, idx), nil, elem)
+		res.Changed++
 	}
+	return nil
+}
+
+// regexReplace 正则替换字符串值
+func (e *Engine) regexReplace(root *yaml.Node, rule *Rule, res *Result) error {
+	matches, err := e.find(root, rule)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	res.Matched = len(matches)
 
 	if rule.Pattern == "" {
 		return fmt.Errorf("pattern is required for regex_replace")
@@ -188,22 +508,331 @@ func (e *Engine) regexReplace(root *yaml.Node, rule *Rule) error {
 
 	replacement, ok := rule.Value.(string)
 	if !ok {
-		return fmt.Errorf("replacement must be string")
+		return &ErrTypeMismatch{Expected: "string", Got: fmt.Sprintf("%T", rule.Value), Path: rule.Path}
+	}
+
+	// 每个标量最多替换的次数，-1 表示替换所有匹配
+	limit := -1
+	if rule.Count > 0 {
+		limit = rule.Count
+	} else if rule.Global != nil && !*rule.Global {
+		limit = 1
+	}
+	r, err := e.newReplacer(root, replacement)
+	if err != nil {
+		return fmt.Errorf("regex replace: %w", err)
 	}
 
 	// 替换所有匹配节点的值
-	for _, node := range nodes {
+	for _, m := range matches {
+		node := m.Node
 		if node.Kind != yaml.ScalarNode {
 			continue
 		}
 
-		// regexp2.Replace: -1, -1 表示替换所有匹配
-		result, err := re.Replace(node.Value, replacement, -1, -1)
+		if re.MatchTimeout, err = e.regexTimeout(); err != nil {
+			return err
+		}
+		result, n, err := replaceMatches(re, node.Value, r, limit)
 		if err != nil {
 			return fmt.Errorf("regex replace: %w", err)
 		}
-		node.Value = result
+		if n == 0 {
+			continue
+		}
+		res.Replacements += n
+		if result != node.Value {
+			// 带引号的字符串替换后仍是字符串；plain 标量按替换后的文本重新解析类型
+			value := &yaml.Node{Kind: yaml.ScalarNode, Value: result}
+			if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+				value.Tag = "!!str"
+			}
+			// 节点保留原标签，coerce 只需确认新文本可按原类型写出
+			if _, err := e.checkType(node, value, m.Path, rule); err != nil {
+				return err
+			}
+			old := e.valueOf(node)
+			node.Value = result
+			e.record(res, m.Path, old, node)
+			res.Changed++
+		}
+	}
+
+	// pattern 一处都没命中通常是写错了，而不是有意的空操作
+	if rule.RequireMatch && res.Replacements == 0 {
+		return fmt.Errorf("%w: %q in %d node(s)", ErrPatternNotMatched, rule.Pattern, res.Matched)
+	}
+
+	return nil
+}
+
+// timeoutError regexp2 匹配只会因超时出错，包装为 ErrTimeout 便于调用方识别
+func timeoutError(err error) error {
+	return fmt.Errorf("%w: %v", ErrTimeout, err)
+}
+
+// replaceMatches 执行正则替换，每个匹配的替换文本由 r 求值，返回替换结果与替换次数；limit > 0 时最多替换 limit 处
+// 不用 regexp2 的 ReplaceFunc：查找下一处匹配超时时它返回空串而不报错
+func replaceMatches(re *regexp2.Regexp, input string, r *replacer, limit int) (string, int, error) {
+	m, err := re.FindStringMatch(input)
+	if err != nil {
+		return "", 0, timeoutError(err)
+	}
+	if m == nil {
+		return input, 0, nil
+	}
+
+	// 匹配位置按 rune 计
+	runes := []rune(input)
+	var buf strings.Builder
+	n, last := 0, 0
+	for m != nil {
+		out, err := r.expand(m, input)
+		if err != nil {
+			return "", 0, fmt.Errorf("render replacement: %w", err)
+		}
+		buf.WriteString(string(runes[last:m.Index]))
+		buf.WriteString(out)
+		last = m.Index + m.Length
+		if n++; n == limit {
+			break
+		}
+		if m, err = re.FindNextMatch(m); err != nil {
+			return "", 0, timeoutError(err)
+		}
+	}
+	buf.WriteString(string(runes[last:]))
+	return buf.String(), n, nil
+}
+
+// groupRefFunc 模板替换串中捕获组引用改写成的函数调用，每个匹配求值前绑定到该匹配
+const groupRefFunc = "yamleditorGroup"
+
+// replacer 解析后的 regex_replace 替换串，每条规则解析一次，逐个匹配求值
+// 替换串含本工具的模板（见 ownTemplate）时，模板动作以外的文字中的捕获组引用在解析前改写为
+// {{ yamleditorGroup "$1" }}，模板渲染一次即得到替换文本；模板输出的内容（变量、文档中的值）不再展开，其中的 $ 原样保留
+type replacer struct {
+	parts []replPart         // 非模板替换串的文字与捕获组引用
+	tmpl  *template.Template // 模板替换串
+	data  *templateData
+}
+
+// replPart 替换串的一段：原样输出的文字，或捕获组引用（ref 为 true，text 为 $1、${name|upper} 等引用本身）
+type replPart struct {
+	text string
+	ref  bool
+}
+
+func (e *Engine) newReplacer(root *yaml.Node, replacement string) (*replacer, error) {
+	if err := ValidateReplacement(replacement); err != nil {
+		return nil, err
+	}
+	isTemplate := isReplacementTemplate(replacement)
+	parts := splitReplacement(replacement, isTemplate)
+	if !isTemplate {
+		return &replacer{parts: parts}, nil
+	}
+
+	var src strings.Builder
+	for _, p := range parts {
+		if p.ref {
+			fmt.Fprintf(&src, "{{%s %s}}", groupRefFunc, strconv.Quote(p.text))
+		} else {
+			src.WriteString(p.text)
+		}
+	}
+	tmpl, err := template.New("").Funcs(funcs).
+		Funcs(template.FuncMap{groupRefFunc: func(string) string { return "" }}).
+		Option("missingkey=error").Parse(src.String())
+	if err != nil {
+		return nil, fmt.Errorf("parse replacement template: %w", err)
+	}
+	return &replacer{tmpl: tmpl, data: e.templateData(root)}, nil
+}
+
+// expand 返回匹配 m 的替换文本，input 为被匹配的整个字符串
+func (r *replacer) expand(m *regexp2.Match, input string) (string, error) {
+	if r.tmpl == nil {
+		var buf strings.Builder
+		for _, p := range r.parts {
+			if p.ref {
+				buf.WriteString(expandRef(m, input, p.text))
+			} else {
+				buf.WriteString(p.text)
+			}
+		}
+		return buf.String(), nil
+	}
+	r.data.Groups = groups(m)
+	r.tmpl.Funcs(template.FuncMap{groupRefFunc: func(ref string) string {
+		return expandRef(m, input, ref)
+	}})
+	return execute(r.tmpl, r.data)
+}
+
+// isReplacementTemplate 判断替换串是否按模板渲染
+func isReplacementTemplate(replacement string) bool {
+	return strings.Contains(replacement, "{{") && ownTemplate(replacement)
+}
+
+// splitReplacement 把替换串拆成文字与捕获组引用；isTemplate 时 {{ }} 动作整体作为文字，其中的 $ 不是组引用
+func splitReplacement(s string, isTemplate bool) []replPart {
+	var parts []replPart
+	text := func(t string) {
+		if n := len(parts); n > 0 && !parts[n-1].ref {
+			parts[n-1].text += t
+		} else {
+			parts = append(parts, replPart{text: t})
+		}
+	}
+	for i := 0; i < len(s); {
+		if isTemplate && strings.HasPrefix(s[i:], "{{") {
+			end := actionEnd(s, i)
+			if end < 0 {
+				end = len(s)
+			}
+			text(s[i:end])
+			i = end
+			continue
+		}
+		if n := groupRef(s, i); n > 0 {
+			parts = append(parts, replPart{text: s[i : i+n], ref: true})
+			i += n
+			continue
+		}
+		text(s[i : i+1])
+		i++
+	}
+	return parts
+}
+
+// actionEnd 返回从 s[i] 开始的模板动作 {{ ... }} 结束后的位置，跳过注释与字符串常量中的 }}；没有结束时返回 -1
+func actionEnd(s string, i int) int {
+	j := i + 2
+	if k := j + len(strings.TrimLeft(s[j:], "- \t\r\n")); strings.HasPrefix(s[k:], "/*") {
+		end := strings.Index(s[k+2:], "*/")
+		if end < 0 {
+			return -1
+		}
+		j = k + 2 + end + 2
+	}
+	for j < len(s) {
+		switch c := s[j]; {
+		case strings.HasPrefix(s[j:], "}}"):
+			return j + 2
+		case c == '"' || c == '\'':
+			for j++; j < len(s) && s[j] != c; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+		case c == '`':
+			end := strings.IndexByte(s[j+1:], '`')
+			if end < 0 {
+				return -1
+			}
+			j += end + 1
+		}
+		j++
 	}
+	return -1
+}
 
+// groupRef 返回从 s[i] 开始的捕获组引用的长度，不是引用时返回 0
+// 与 regexp2 的替换串一致：$N（读入所有数字）、${name}、$$、$&、$`、$'、$+、$_，
+// 另支持 ${name|fn...}；$ 后是其他字符时原样输出
+func groupRef(s string, i int) int {
+	if s[i] != '$' || i+1 >= len(s) {
+		return 0
+	}
+	switch c := s[i+1]; {
+	case strings.IndexByte("$&`'+_", c) >= 0:
+		return 2
+	case c >= '0' && c <= '9':
+		j := i + 1
+		for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			j++
+		}
+		return j - i
+	case c == '{':
+		end := strings.IndexByte(s[i+2:], '}')
+		if end < 0 {
+			return 0
+		}
+		name, _, _ := strings.Cut(s[i+2:i+2+end], "|")
+		if name == "" || strings.IndexFunc(name, func(r rune) bool { return !syntax.IsWordChar(r) }) >= 0 {
+			return 0
+		}
+		return end + 3
+	}
+	return 0
+}
+
+// groupFuncs 替换串中 ${name|fn} 可对捕获组使用的函数，可串联如 ${name|trim|lower}
+var groupFuncs = map[string]func(string) string{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+}
+
+// ValidateReplacement 校验 regex_replace 替换串中捕获组引用使用的函数
+func ValidateReplacement(replacement string) error {
+	for _, p := range splitReplacement(replacement, isReplacementTemplate(replacement)) {
+		if !p.ref || !strings.HasPrefix(p.text, "${") {
+			continue
+		}
+		_, fns, ok := strings.Cut(p.text[2:len(p.text)-1], "|")
+		if !ok {
+			continue
+		}
+		for _, fn := range strings.Split(fns, "|") {
+			if _, ok := groupFuncs[strings.TrimSpace(fn)]; !ok {
+				return fmt.Errorf("unknown function %q in %s, expected upper, lower or trim", strings.TrimSpace(fn), p.text)
+			}
+		}
+	}
 	return nil
 }
+
+// expandRef 展开一个捕获组引用（见 groupRef）；引用的组不存在时与 regexp2 一样原样输出引用
+func expandRef(m *regexp2.Match, input, ref string) string {
+	switch ref {
+	case "$$":
+		return "$"
+	case "$&":
+		return m.String()
+	case "$`":
+		return string([]rune(input)[:m.Index])
+	case "$'":
+		return string([]rune(input)[m.Index+m.Length:])
+	case "$+":
+		gs := m.Groups()
+		return gs[len(gs)-1].String()
+	case "$_":
+		return input
+	}
+
+	name, fns := ref[1:], ""
+	if strings.HasPrefix(ref, "${") {
+		name, fns, _ = strings.Cut(ref[2:len(ref)-1], "|")
+	}
+	g := groupOf(m, name)
+	if g == nil {
+		return ref
+	}
+	value := g.String()
+	if fns != "" {
+		for _, fn := range strings.Split(fns, "|") {
+			value = groupFuncs[strings.TrimSpace(fn)](value)
+		}
+	}
+	return value
+}
+
+// groupOf 按名称或编号返回捕获组
+func groupOf(m *regexp2.Match, name string) *regexp2.Group {
+	if n, err := strconv.Atoi(name); err == nil {
+		return m.GroupByNumber(n)
+	}
+	return m.GroupByName(name)
+}
@@ -1,5 +1,35 @@
 package engine
 
-import "errors"
+import (
+	"errors"
 
-var ErrNotFoundNodes = errors.New("no nodes found")
\ No newline at end of file
+	"github.com/glesirok/yamleditor/pkg/path"
+)
+
+var (
+	// ErrNotFoundNodes 路径解析成功但没有匹配到任何节点
+	ErrNotFoundNodes = errors.New("no nodes found")
+	// ErrUnknownAction 规则的 action 不受支持
+	ErrUnknownAction = errors.New("unknown action")
+	// ErrPatternNotMatched require_match 的 regex_replace 在所有匹配节点上都没有命中 pattern
+	ErrPatternNotMatched = errors.New("pattern matched nothing")
+	// ErrKeyExists rename_key 的新键名在同一 mapping 中已存在
+	ErrKeyExists = errors.New("key already exists")
+	// ErrAliasedTarget targets 为 error 的规则要修改被别名引用的内容
+	ErrAliasedTarget = errors.New("target is shared through an alias")
+	// ErrTypeChange 写入的值会改变节点的类型（开启 strict types 时），或 coerce 无法转换
+	ErrTypeChange = errors.New("type change")
+)
+
+// 路径错误，便于调用方只依赖 engine 包即可用 errors.Is / errors.As 区分错误类别
+var (
+	ErrPathNotFound = path.ErrPathNotFound
+	ErrNoMatch      = path.ErrNoMatch
+	ErrInvalidPath  = path.ErrInvalidPath
+	ErrTimeout      = path.ErrTimeout
+)
+
+type (
+	PathError       = path.PathError
+	ErrTypeMismatch = path.ErrTypeMismatch
+)
@@ -0,0 +1,215 @@
+package engine
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/path"
+)
+
+// replaceNode 用 src 的副本原地替换 dst，保留 dst 的锚点及格式（见 keepFormat）
+// 原地替换使经由别名命中的锚点内容对所有别名保持一致；返回新值中没有对应位置而丢弃的注释数
+func replaceNode(dst, src *yaml.Node) int {
+	cp := path.CopyTree(src)
+	dropped := keepFormat(dst, cp)
+	if cp.Anchor == "" {
+		cp.Anchor = dst.Anchor
+	}
+	*dst = *cp
+	return dropped
+}
+
+// keepFormat 把旧节点上手写的格式带到新节点上：
+//   - 头部、行尾、尾部注释
+//   - 集合的 flow 风格（[a, b]、{k: v}），仅在新旧节点类型相同时
+//   - 字符串标量的单/双引号，仅在新值为单行字符串时
+//   - 字符串标量的块风格：| 在新值为多行字符串时，> 在新值不含换行时（含换行的值写成 > 会多出空行）
+//
+// 新旧节点都是 mapping 时按键名递归处理同名键（键上的注释一并保留），
+// 都是等长 sequence 时按下标递归处理；返回旧节点下找不到对应位置而丢弃的注释数
+func keepFormat(old, new *yaml.Node) int {
+	new.HeadComment, new.LineComment, new.FootComment = old.HeadComment, old.LineComment, old.FootComment
+
+	switch {
+	case old.Kind != new.Kind:
+		return countComments(old.Content...)
+	case new.Kind == yaml.MappingNode || new.Kind == yaml.SequenceNode:
+		new.Style |= old.Style & yaml.FlowStyle
+	case new.Kind == yaml.ScalarNode:
+		quoted := old.Style & (yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle)
+		multiline := strings.Contains(new.Value, "\n")
+		switch {
+		case new.Tag != "!!str":
+		case quoted != 0 && !multiline:
+			new.Style = quoted
+		case old.Style&yaml.LiteralStyle != 0 && multiline:
+			new.Style = yaml.LiteralStyle
+		case old.Style&yaml.FoldedStyle != 0 && !multiline:
+			new.Style = yaml.FoldedStyle
+		}
+	}
+
+	dropped := 0
+	switch new.Kind {
+	case yaml.MappingNode:
+		used := make([]bool, len(old.Content)/2)
+		for i := 0; i+1 < len(new.Content); i += 2 {
+			for j := 0; j+1 < len(old.Content); j += 2 {
+				if old.Content[j].Value == new.Content[i].Value {
+					dropped += keepFormat(old.Content[j], new.Content[i])
+					dropped += keepFormat(old.Content[j+1], new.Content[i+1])
+					used[j/2] = true
+					break
+				}
+			}
+		}
+		for j, ok := range used {
+			if !ok {
+				dropped += countComments(old.Content[2*j], old.Content[2*j+1])
+			}
+		}
+	case yaml.SequenceNode:
+		if len(old.Content) != len(new.Content) {
+			return countComments(old.Content...)
+		}
+		for i := range new.Content {
+			dropped += keepFormat(old.Content[i], new.Content[i])
+		}
+	}
+	return dropped
+}
+
+// countComments 统计节点及其子节点上的注释数，不进入别名
+func countComments(nodes ...*yaml.Node) int {
+	n := 0
+	for _, node := range nodes {
+		for _, c := range []string{node.HeadComment, node.LineComment, node.FootComment} {
+			if c != "" {
+				n++
+			}
+		}
+		if node.Kind != yaml.AliasNode {
+			n += countComments(node.Content...)
+		}
+	}
+	return n
+}
+
+// scalarStyles 规则 style 字段的取值对应的标量风格，plain 为 0
+var scalarStyles = map[string]yaml.Style{
+	StylePlain:   0,
+	StyleSingle:  yaml.SingleQuotedStyle,
+	StyleDouble:  yaml.DoubleQuotedStyle,
+	StyleLiteral: yaml.LiteralStyle,
+	StyleFolded:  yaml.FoldedStyle,
+}
+
+// applyStyle 按规则的 style 设置新值中所有字符串标量（不含键）的风格，style 为空时不变
+// plain 只作用于写成 plain 后仍是同一字符串的单行值；无法用指定风格表示的值由编码器退回可用的风格
+func applyStyle(node *yaml.Node, style string) {
+	if style == "" {
+		return
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			applyStyle(node.Content[i], style)
+		}
+	case yaml.SequenceNode:
+		for _, child := range node.Content {
+			applyStyle(child, style)
+		}
+	case yaml.ScalarNode:
+		if node.ShortTag() != "!!str" || node.Style&yaml.TaggedStyle != 0 {
+			return
+		}
+		if style == StylePlain && (strings.Contains(node.Value, "\n") || !plainSafe(node.Value)) {
+			return
+		}
+		node.Style = scalarStyles[style]
+	}
+}
+
+// quoteStyle 统一匹配子树中字符串标量的引号风格，path 为空时作用于整个文档
+//   - plain 去掉值与键上不必要的引号，去掉后会被解析为其他类型（如 "true"、"1.0"、"yes"）或无法用 plain 表示的保持不变
+//   - single/double 只作用于值，键保持不变
+//
+// 多行字符串、块标量与带显式标签的标量不处理；不进入别名，锚点在定义处处理
+func (e *Engine) quoteStyle(root *yaml.Node, rule *Rule, res *Result) error {
+	matches, err := e.findOrDocument(root, rule)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	res.Matched = len(matches)
+
+	var style yaml.Style
+	switch rule.Value {
+	case QuoteSingle:
+		style = yaml.SingleQuotedStyle
+	case QuoteDouble:
+		style = yaml.DoubleQuotedStyle
+	}
+
+	seen := map[*yaml.Node]bool{}
+	var walk func(node *yaml.Node, key bool)
+	walk = func(node *yaml.Node, key bool) {
+		if seen[node] {
+			return
+		}
+		seen[node] = true
+
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				walk(node.Content[i], true)
+				walk(node.Content[i+1], false)
+			}
+		case yaml.SequenceNode:
+			for _, child := range node.Content {
+				walk(child, false)
+			}
+		case yaml.ScalarNode:
+			if key && style != 0 || !restylable(node) {
+				return
+			}
+			if style == 0 && !plainSafe(node.Value) {
+				return
+			}
+			if node.Style != style {
+				node.Style = style
+				res.Changed++
+			}
+		}
+	}
+	for _, m := range matches {
+		walk(m.Node, false)
+	}
+	return nil
+}
+
+// restylable 标量是否可以在 plain 与单/双引号之间切换
+func restylable(node *yaml.Node) bool {
+	const fixed = yaml.TaggedStyle | yaml.LiteralStyle | yaml.FoldedStyle
+	return node.ShortTag() == "!!str" && node.Style&fixed == 0 && !strings.Contains(node.Value, "\n")
+}
+
+// yaml11Bools YAML 1.1 中的布尔值写法，核心 schema 下是字符串，
+// 但 Kubernetes 等使用 YAML 1.1 解析器的工具会读成布尔值，去掉引号不安全
+var yaml11Bools = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"n": true, "N": true, "no": true, "No": true, "NO": true,
+	"on": true, "On": true, "ON": true, "off": true, "Off": true, "OFF": true,
+}
+
+// plainSafe 字符串写成 plain 标量后是否仍按原值解析为字符串，由编码器判断是否需要引号，
+// 另外排除 YAML 1.1 的布尔值写法
+func plainSafe(value string) bool {
+	if yaml11Bools[value] {
+		return false
+	}
+	out, err := yaml.Marshal(&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+	if err != nil {
+		return false
+	}
+	return len(out) > 0 && out[0] != '"' && out[0] != '\''
+}
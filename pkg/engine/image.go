@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// imageTag tag 的合法写法，同 OCI distribution 规范
+	imageTag = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	// imageDigest digest 的合法写法，如 sha256:<64 位十六进制>
+	imageDigest = regexp.MustCompile(`^[a-z0-9]+(?:[+._-][a-z0-9]+)*:[A-Fa-f0-9]{32,}$`)
+	// imageRegistry registry 主机名（可带端口）及可选的路径前缀，如 registry.example.com:5000/mirror
+	imageRegistry = regexp.MustCompile(`^[A-Za-z0-9.-]+(?::[0-9]+)?(?:/[a-z0-9._-]+)*$`)
+)
+
+// imageRef 容器镜像引用 [registry/]repository[:tag][@digest]
+type imageRef struct {
+	Registry   string // 如 docker.io、registry.example.com:5000，未写时为空
+	Repository string // 如 library/nginx
+	Tag        string
+	Digest     string // 如 sha256:...
+}
+
+// parseImage 解析镜像引用：第一段含 . 或 :、或为 localhost 时视为 registry，与 docker 的规则相同
+func parseImage(s string) (imageRef, error) {
+	var ref imageRef
+	rest := s
+	if name, digest, ok := strings.Cut(rest, "@"); ok {
+		if !imageDigest.MatchString(digest) {
+			return ref, fmt.Errorf("invalid image reference %q: bad digest", s)
+		}
+		rest, ref.Digest = name, digest
+	}
+	if first, remainder, ok := strings.Cut(rest, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.Registry, rest = first, remainder
+	}
+	if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		if !imageTag.MatchString(rest[i+1:]) {
+			return ref, fmt.Errorf("invalid image reference %q: bad tag", s)
+		}
+		rest, ref.Tag = rest[:i], rest[i+1:]
+	}
+	if rest == "" || strings.ContainsAny(rest, " \t:@") {
+		return ref, fmt.Errorf("invalid image reference %q", s)
+	}
+	ref.Repository = rest
+	return ref, nil
+}
+
+func (r imageRef) String() string {
+	s := r.Repository
+	if r.Registry != "" {
+		s = r.Registry + "/" + s
+	}
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// ValidateImageValue 检查 set_image_* 的 value：tag、digest 须为合法写法（digest 可为空串，表示去掉 digest），
+// registry 为主机名加可选的路径前缀（可为空串，表示去掉 registry）；含 ${ 或 {{ 的值执行时再检查
+func ValidateImageValue(action ActionType, value interface{}) error {
+	s, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("value must be a string for %s", action)
+	}
+	if strings.Contains(s, "${") || strings.Contains(s, "{{") {
+		return nil
+	}
+	switch action {
+	case ActionSetImageTag:
+		if !imageTag.MatchString(s) {
+			return fmt.Errorf("invalid image tag %q", s)
+		}
+	case ActionSetImageDigest:
+		if s != "" && !imageDigest.MatchString(s) {
+			return fmt.Errorf("invalid image digest %q, expected algorithm:hex such as sha256:...", s)
+		}
+	case ActionSetImageRegistry:
+		if s != "" && !imageRegistry.MatchString(s) {
+			return fmt.Errorf("invalid image registry %q", s)
+		}
+	}
+	return nil
+}
+
+// setImage 解析匹配到的镜像引用，只改写规则对应的部分：
+// set_image_tag 替换 tag 并去掉 digest（digest 固定了镜像内容，保留时新 tag 不起作用），
+// set_image_registry 替换或去掉 registry，set_image_digest 设置或去掉 digest，tag 保持不变
+func (e *Engine) setImage(root *yaml.Node, rule *Rule, res *Result) error {
+	matches, err := e.find(root, rule)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	res.Matched = len(matches)
+
+	if err := ValidateImageValue(rule.Action, rule.Value); err != nil {
+		return err
+	}
+	value := rule.Value.(string)
+
+	for _, m := range matches {
+		node := m.Node
+		if node.Kind != yaml.ScalarNode {
+			continue
+		}
+		ref, err := parseImage(node.Value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", m.Path, err)
+		}
+
+		switch rule.Action {
+		case ActionSetImageTag:
+			ref.Tag, ref.Digest = value, ""
+		case ActionSetImageRegistry:
+			ref.Registry = value
+		case ActionSetImageDigest:
+			ref.Digest = value
+		}
+
+		if s := ref.String(); s != node.Value {
+			old := e.valueOf(node)
+			node.Value = s
+			e.record(res, m.Path, old, node)
+			res.Changed++
+		}
+	}
+	return nil
+}
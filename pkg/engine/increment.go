@@ -0,0 +1,144 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// increment 把匹配到的数值加上 by（默认 1，负数即递减），递增时不超过 max，递减时不低于 min；
+// 原值已超出行进方向上的边界时不修改。整数按整数计算，值为十进制整数的字符串（如注解）按整数计算后仍为字符串并保持引号；浮点数按浮点计算；
+// 非标量节点跳过，标量不是数值时报错
+func (e *Engine) increment(root *yaml.Node, rule *Rule, res *Result) error {
+	matches, err := e.find(root, rule)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	res.Matched = len(matches)
+
+	for _, m := range matches {
+		node := m.Node
+		if node.Kind != yaml.ScalarNode {
+			continue
+		}
+		value, err := incremented(node, rule)
+		if err != nil {
+			return fmt.Errorf("%s: %w", m.Path, err)
+		}
+		if value == node.Value {
+			continue
+		}
+		old := e.valueOf(node)
+		node.Value = value
+		e.record(res, m.Path, old, node)
+		res.Changed++
+	}
+	return nil
+}
+
+// incremented 计算标量加上 by 并限制范围后的文本
+func incremented(node *yaml.Node, rule *Rule) (string, error) {
+	by := 1.0
+	if rule.By != nil {
+		by = *rule.By
+	}
+
+	switch tag := node.ShortTag(); tag {
+	case "!!float":
+		var f float64
+		if err := node.Decode(&f); err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", fmt.Errorf("cannot increment %q", node.Value)
+		}
+		if beyond(f, by, rule) {
+			return node.Value, nil
+		}
+		sum := f + by
+		if math.IsInf(sum, 0) {
+			return "", fmt.Errorf("cannot increment %q by %v: overflow", node.Value, by)
+		}
+		switch {
+		case by > 0 && rule.Max != nil && sum > *rule.Max:
+			sum = *rule.Max
+		case by < 0 && rule.Min != nil && sum < *rule.Min:
+			sum = *rule.Min
+		}
+		if sum == f {
+			// 超出精度的增量不改变值，保持原写法
+			return node.Value, nil
+		}
+		return formatFloat(sum), nil
+
+	case "!!int", "!!str":
+		var n int64
+		var err error
+		if tag == "!!int" {
+			err = node.Decode(&n)
+		} else {
+			n, err = strconv.ParseInt(strings.TrimSpace(node.Value), 10, 64)
+		}
+		if err != nil {
+			return "", fmt.Errorf("cannot increment %q: not an integer", node.Value)
+		}
+		if by != math.Trunc(by) {
+			return "", fmt.Errorf("cannot increment integer %q by %v", node.Value, by)
+		}
+		if beyond(float64(n), by, rule) {
+			return node.Value, nil
+		}
+
+		// 结果超出边界或 int64 范围时：行进方向上有在 int64 范围内的边界则取边界，否则报错
+		var bound *int64
+		switch {
+		case by > 0 && rule.Max != nil && *rule.Max < maxInt64:
+			b := int64(math.Floor(*rule.Max))
+			bound = &b
+		case by < 0 && rule.Min != nil && *rule.Min >= math.MinInt64:
+			b := int64(math.Ceil(*rule.Min))
+			bound = &b
+		}
+		sum, ok := addInt64(n, by)
+		switch {
+		case bound != nil && (!ok || by > 0 && sum > *bound || by < 0 && sum < *bound):
+			sum = *bound
+		case !ok:
+			return "", fmt.Errorf("cannot increment %q by %v: overflow", node.Value, by)
+		}
+		return strconv.FormatInt(sum, 10), nil
+	}
+	return "", fmt.Errorf("cannot increment %q: not a number", node.Value)
+}
+
+// maxInt64 2^63，float64 能表示的大于 math.MaxInt64 的最小值
+const maxInt64 = float64(1 << 63)
+
+// beyond 判断原值是否已在行进方向上的边界之外（递增时不小于 max，递减时不大于 min），此时不修改
+func beyond(v, by float64, rule *Rule) bool {
+	return by > 0 && rule.Max != nil && v >= *rule.Max || by < 0 && rule.Min != nil && v <= *rule.Min
+}
+
+// addInt64 返回 n + by，by 须为整数，结果超出 int64 范围时返回 false
+func addInt64(n int64, by float64) (int64, bool) {
+	if by >= maxInt64 || by < math.MinInt64 {
+		return 0, false
+	}
+	b := int64(by)
+	if b > 0 && n > math.MaxInt64-b || b < 0 && n < math.MinInt64-b {
+		return 0, false
+	}
+	return n + b, true
+}
+
+// formatFloat 输出浮点数，没有小数点时补上 .0，避免写回后被解析为整数；很大或很小的数使用指数形式
+func formatFloat(f float64) string {
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-4 || abs >= 1e21) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
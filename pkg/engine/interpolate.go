@@ -0,0 +1,250 @@
+package engine
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// funcs 规则模板可用的函数，如 {{ now | date "2006-01-02" }}、{{ env "REGION" | default "cn" }}
+var funcs = template.FuncMap{
+	"now":  time.Now,
+	"date": func(layout string, t time.Time) string { return t.Format(layout) },
+	"env":  os.Getenv, // 未设置时为空串；需要报错时使用 .Env.NAME
+	"default": func(def, s string) string {
+		if s == "" {
+			return def
+		}
+		return s
+	},
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"trim":    strings.TrimSpace,
+	"replace": func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+}
+
+// volatileFuncs 结果随执行时间变化的函数
+var volatileFuncs = map[string]bool{"now": true}
+
+// Env 进程环境变量（设置了 SetEnviron 时为其给出的变量），模板中 {{ .Env.NAME }} 在变量未设置时报错
+func (d *templateData) Env() map[string]string {
+	if d.environ != nil {
+		return maps.Clone(d.environ)
+	}
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+// SetEnviron 设置规则中 ${NAME}、env "NAME" 与 .Env 读到的环境变量，代替进程的环境变量；
+// 空 map 表示没有任何环境变量，用于执行不可信的规则；nil 恢复读取进程环境变量
+func (e *Engine) SetEnviron(env map[string]string) {
+	e.environ = env
+}
+
+// LookupEnv 按 SetEnviron 的设置查找环境变量
+func (e *Engine) LookupEnv(name string) (string, bool) {
+	if e.environ != nil {
+		value, ok := e.environ[name]
+		return value, ok
+	}
+	return os.LookupEnv(name)
+}
+
+// envRef 值中的 ${NAME}、${NAME:-default}，$${ 为转义的 ${
+var envRef = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// interpolateEnv 展开 s 中的环境变量引用：${NAME} 在变量未设置时报错，
+// ${NAME:-default} 在变量未设置或为空时使用 default；lookup 查找变量，同 os.LookupEnv
+func interpolateEnv(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var err error
+	out := envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$${" {
+			return "${"
+		}
+		m := envRef.FindStringSubmatch(ref)
+		value, ok := lookup(m[1])
+		switch {
+		case strings.Contains(ref, ":-"):
+			if value == "" {
+				value = m[2]
+			}
+		case !ok && err == nil:
+			err = fmt.Errorf("environment variable %s is not set", m[1])
+		}
+		return value
+	})
+	return out, err
+}
+
+// interpolateValue 递归展开 value 中字符串的环境变量引用
+func interpolateValue(v interface{}, lookup func(string) (string, bool)) (interface{}, error) {
+	return mapStrings(v, func(s string) (string, error) {
+		return interpolateEnv(s, lookup)
+	})
+}
+
+// mapStrings 对 value 中的每个字符串（含 mapping、列表中的）应用 fn
+func mapStrings(v interface{}, fn func(string) (string, error)) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		return fn(val)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			mapped, err := mapStrings(item, fn)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = mapped
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			mapped, err := mapStrings(item, fn)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = mapped
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+// templateRefs 模板引用的环境变量与随时间变化的函数
+type templateRefs struct {
+	env      []string // .Env.NAME、env "NAME" 与 ${NAME} 引用的变量名
+	volatile bool     // 引用了 now 等结果随时间变化的函数
+	context  bool     // 引用了本工具提供的上下文（见 contextFields）或模板函数
+}
+
+// contextFields 模板数据中由本工具提供的字段，见 templateData
+var contextFields = map[string]bool{"Vars": true, "Groups": true, "File": true, "Doc": true, "Env": true}
+
+// ownTemplate 判断含 {{ 的字符串是否是给本工具渲染的模板：引用了 contextFields 中的字段或 funcs 中的函数。
+// 其余的 {{ }}（如 Helm 的 {{ .Values.image }}、{{ include "x" . }}）是其他工具的模板文本，原样保留；
+// 无法解析的文本同样原样保留
+func ownTemplate(s string) bool {
+	tree := parse.New("")
+	tree.Mode = parse.SkipFuncCheck
+	if _, err := tree.Parse(s, "", "", map[string]*parse.Tree{}); err != nil || tree.Root == nil {
+		return false
+	}
+	refs := &templateRefs{}
+	refs.walk(tree.Root)
+	return refs.context
+}
+
+// collect 收集字符串中的引用，dollar 为 false 时不识别 ${NAME}；无法解析的模板忽略（执行时报错）
+func (r *templateRefs) collect(s string, dollar bool) {
+	for _, m := range envRef.FindAllStringSubmatch(s, -1) {
+		if dollar && m[1] != "" {
+			r.env = append(r.env, m[1])
+		}
+	}
+	if !strings.Contains(s, "{{") {
+		return
+	}
+	tmpl, err := parseTemplate(s)
+	if err != nil || tmpl.Tree == nil {
+		return
+	}
+	r.walk(tmpl.Tree.Root)
+}
+
+// walk 遍历模板语法树
+func (r *templateRefs) walk(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			r.walk(child)
+		}
+	case *parse.ActionNode:
+		r.walk(n.Pipe)
+	case *parse.IfNode:
+		r.walkBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		r.walkBranch(&n.BranchNode)
+	case *parse.WithNode:
+		r.walkBranch(&n.BranchNode)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			r.walk(cmd)
+		}
+	case *parse.CommandNode:
+		// env "NAME"
+		if id, ok := n.Args[0].(*parse.IdentifierNode); ok && id.Ident == "env" && len(n.Args) > 1 {
+			if name, ok := n.Args[1].(*parse.StringNode); ok {
+				r.env = append(r.env, name.Text)
+			}
+		}
+		for _, arg := range n.Args {
+			r.walk(arg)
+		}
+	case *parse.IdentifierNode:
+		_, ok := funcs[n.Ident]
+		r.context = r.context || ok
+		r.volatile = r.volatile || volatileFuncs[n.Ident]
+	case *parse.FieldNode:
+		r.context = r.context || contextFields[n.Ident[0]]
+		if n.Ident[0] == "Env" && len(n.Ident) > 1 {
+			r.env = append(r.env, n.Ident[1])
+		}
+	case *parse.VariableNode:
+		// $.Vars.x
+		r.context = r.context || len(n.Ident) > 1 && n.Ident[0] == "$" && contextFields[n.Ident[1]]
+	case *parse.ChainNode:
+		r.walk(n.Node)
+	}
+}
+
+func (r *templateRefs) walkBranch(n *parse.BranchNode) {
+	r.walk(n.Pipe)
+	r.walk(n.List)
+	r.walk(n.ElseList)
+}
+
+// refsOf 收集规则 value 与 pattern 中的引用，${NAME} 只在展开环境变量的 value 中识别
+func refsOf(rule *Rule) *templateRefs {
+	refs := &templateRefs{}
+	refs.collect(rule.Pattern, false)
+	mapStrings(rule.Value, func(s string) (string, error) {
+		refs.collect(s, rule.InterpolatesEnv())
+		return s, nil
+	})
+	return refs
+}
+
+// TemplateEnv 返回规则引用的环境变量名（去重排序），以及是否使用了 now 等结果随时间变化的函数，
+// 供增量缓存判断环境变化与是否可以复用结果
+func TemplateEnv(rules []*Rule) (env []string, volatile bool) {
+	for _, r := range rules {
+		refs := refsOf(r)
+		env = append(env, refs.env...)
+		volatile = volatile || refs.volatile
+	}
+	slices.Sort(env)
+	return slices.Compact(env), volatile
+}
@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestInterpolateEnv(t *testing.T) {
+	env := map[string]string{"HOME": "/root", "TAG": "v2"}
+	tests := []struct {
+		name        string
+		interpolate string
+		value       string
+		want        string
+		wantErr     bool
+	}{
+		{name: "literal by default", value: "${HOME}/bin", want: "${HOME}/bin"},
+		{name: "unset variable kept by default", value: "${MISSING}", want: "${MISSING}"},
+		{name: "none keeps literal", interpolate: InterpolateNone, value: "${TAG}", want: "${TAG}"},
+		{name: "env expands", interpolate: InterpolateEnv, value: "app:${TAG}", want: "app:v2"},
+		{name: "env default", interpolate: InterpolateEnv, value: "${REGION:-cn}", want: "cn"},
+		{name: "env escape", interpolate: InterpolateEnv, value: "$${TAG}", want: "${TAG}"},
+		{name: "env unset variable", interpolate: InterpolateEnv, value: "${MISSING}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte("a: x\n"), &doc); err != nil {
+				t.Fatal(err)
+			}
+			e := NewEngine()
+			e.SetEnviron(env)
+			_, err := e.Apply(&doc, &Rule{Action: ActionReplace, Path: "a", Value: tt.value, Interpolate: tt.interpolate})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Apply() error = nil, want unset variable error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if got := doc.Content[0].Content[1].Value; got != tt.want {
+				t.Errorf("a = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
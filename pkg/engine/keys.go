@@ -0,0 +1,153 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dlclark/regexp2"
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/path"
+)
+
+// keyRename rename_keys 映射表中的一项
+// 键写作 @pattern@ 时为正则，按 regex_replace 的方式替换键名（支持 $1、${name}），否则精确匹配整个键名
+type keyRename struct {
+	from string
+	re   *regexp2.Regexp // 非 nil 时为正则项
+	to   string
+}
+
+// parseKeyRenames 解析 rename_keys 的 value：精确项优先，正则项按 pattern 排序，键名只按第一个命中的项改名
+func parseKeyRenames(value interface{}) ([]keyRename, error) {
+	table, ok := value.(map[string]interface{})
+	if !ok || len(table) == 0 {
+		return nil, fmt.Errorf("value must be a non-empty mapping of old key to new key")
+	}
+
+	var renames []keyRename
+	for from, v := range table {
+		to, ok := v.(string)
+		if !ok || to == "" {
+			return nil, fmt.Errorf("new name for %q must be a non-empty string", from)
+		}
+		r := keyRename{from: from, to: to}
+		if len(from) > 2 && strings.HasPrefix(from, "@") && strings.HasSuffix(from, "@") {
+			re, err := regexp2.Compile(from[1:len(from)-1], 0)
+			if err != nil {
+				return nil, fmt.Errorf("compile %q: %w", from, err)
+			}
+			r.re = re
+		}
+		renames = append(renames, r)
+	}
+	sort.Slice(renames, func(i, j int) bool {
+		if (renames[i].re == nil) != (renames[j].re == nil) {
+			return renames[i].re == nil
+		}
+		return renames[i].from < renames[j].from
+	})
+	return renames, nil
+}
+
+// ValidateKeyRenames 校验 rename_keys 的映射表
+func ValidateKeyRenames(value interface{}) error {
+	_, err := parseKeyRenames(value)
+	return err
+}
+
+// renameKeys 按映射表改名匹配节点子树中所有 mapping 的键，path 为空时作用于整个文档
+// 不进入别名，锚点在定义处处理；改名后与同一 mapping 中的其他键重名时报错
+func (e *Engine) renameKeys(root *yaml.Node, rule *Rule, res *Result) error {
+	renames, err := parseKeyRenames(rule.Value)
+	if err != nil {
+		return err
+	}
+
+	matches, err := e.findOrDocument(root, rule)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	res.Matched = len(matches)
+
+	// 匹配的子树可能互相嵌套，每个 mapping 只处理一次，避免正则项重复改名
+	seen := map[*yaml.Node]bool{}
+	for _, m := range matches {
+		if err := e.renameIn(m.Node, m.Path, renames, seen, res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renameIn 递归改名 node 子树中的键，p 为 node 的具体路径
+func (e *Engine) renameIn(node *yaml.Node, p string, renames []keyRename, seen map[*yaml.Node]bool, res *Result) error {
+	if node == nil || seen[node] {
+		return nil
+	}
+	seen[node] = true
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Kind != yaml.ScalarNode || key.ShortTag() == "!!merge" {
+				continue
+			}
+			name, err := e.renamed(key.Value, renames)
+			if err != nil {
+				return err
+			}
+			if name != key.Value {
+				if mappingValue(node, name) != nil {
+					return fmt.Errorf("rename %s: %w: %q", path.FieldPath(p, key.Value), ErrKeyExists, name)
+				}
+				if e.track {
+					res.Changes = append(res.Changes, Change{Path: path.FieldPath(p, key.Value), Old: key.Value, New: name})
+				}
+				key.Value, key.Tag = name, "!!str"
+				res.Changed++
+			}
+			if err := e.renameIn(node.Content[i+1], path.FieldPath(p, key.Value), renames, seen, res); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			if err := e.renameIn(child, path.ElemPath(p, i), renames, seen, res); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// renamed 返回键名按映射表改名后的结果，没有命中的项时原样返回
+func (e *Engine) renamed(key string, renames []keyRename) (string, error) {
+	for _, r := range renames {
+		if r.re == nil {
+			if r.from == key {
+				return r.to, nil
+			}
+			continue
+		}
+
+		var err error
+		if r.re.MatchTimeout, err = e.regexTimeout(); err != nil {
+			return "", err
+		}
+		matched, err := r.re.MatchString(key)
+		if err != nil {
+			return "", fmt.Errorf("regex match: %w", timeoutError(err))
+		}
+		if !matched {
+			continue
+		}
+		name, err := r.re.Replace(key, r.to, -1, -1)
+		if err != nil {
+			return "", fmt.Errorf("regex replace: %w", timeoutError(err))
+		}
+		return name, nil
+	}
+	return key, nil
+}
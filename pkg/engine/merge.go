@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/path"
+)
+
+// merge 的冲突策略：同一键在两侧都有值且不都是 mapping、值又不相同时的处理
+const (
+	MergeTheirs = "theirs" // 使用规则 value 中的值（默认）
+	MergeOurs   = "ours"   // 保留文档中的原值
+	MergeError  = "error"  // 报错，规则不生效
+)
+
+// ErrMergeConflict strategy 为 error 的 merge 遇到冲突的键
+var ErrMergeConflict = errors.New("merge conflict")
+
+// merge 将 value 中的 mapping 深度合并到匹配节点：缺少的键追加到末尾，两侧都是 mapping 的键递归合并，
+// 其余冲突按 strategy 处理；null 节点视为空 mapping，冲突检查在修改之前完成，报错时文档不变
+func (e *Engine) merge(root *yaml.Node, rule *Rule, res *Result) error {
+	matches, err := e.find(root, rule)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	res.Matched = len(matches)
+
+	src := &yaml.Node{}
+	if err := src.Encode(rule.Value); err != nil {
+		return fmt.Errorf("encode value: %w", err)
+	}
+	if src.Kind != yaml.MappingNode {
+		return &ErrTypeMismatch{Expected: "mapping", Got: fmt.Sprintf("%T", rule.Value), Path: rule.Path}
+	}
+	applyStyle(src, rule.Style)
+
+	for _, m := range matches {
+		node := m.Node
+		if node.Kind != yaml.MappingNode && !(node.Kind == yaml.ScalarNode && node.Tag == "!!null") {
+			return &ErrTypeMismatch{Expected: "mapping", Got: path.KindName(node.Kind), Path: m.Path}
+		}
+		if rule.Strategy == MergeError {
+			if err := mergeConflict(node, src, m.Path); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, m := range matches {
+		node := m.Node
+		if node.Kind == yaml.ScalarNode {
+			node.Kind, node.Tag, node.Value, node.Style = yaml.MappingNode, "!!map", "", 0
+		}
+		changed, err := e.mergeMapping(node, src, m.Path, rule, res)
+		if err != nil {
+			return err
+		}
+		if changed {
+			res.Changed++
+		}
+	}
+	return nil
+}
+
+// mergeMapping 把 src 的键合并到 dst，strategy 为 ours 时冲突的键保留原值；返回 dst 是否被修改
+// 覆盖的值按 checkType 检查类型
+func (e *Engine) mergeMapping(dst, src *yaml.Node, p string, rule *Rule, res *Result) (bool, error) {
+	changed := false
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		fieldPath := path.FieldPath(p, key.Value)
+
+		existing := mappingValue(dst, key.Value)
+		switch {
+		case existing == nil:
+			dst.Content = append(dst.Content, path.CopyTree(key), path.CopyTree(value))
+			e.record(res, fieldPath, nil, dst.Content[len(dst.Content)-1])
+			changed = true
+		case unwrap(existing).Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			// 经过别名时合并到锚点本身，与其他规则默认的 anchors_only 一致
+			sub, err := e.mergeMapping(unwrap(existing), value, fieldPath, rule, res)
+			if err != nil {
+				return false, err
+			}
+			changed = changed || sub
+		case rule.Strategy == MergeOurs || sameNode(existing, value):
+		default:
+			checked, err := e.checkType(existing, value, fieldPath, rule)
+			if err != nil {
+				return false, err
+			}
+			old := e.valueOf(existing)
+			e.warnDropped(fieldPath, replaceNode(existing, checked))
+			applyStyle(existing, rule.Style)
+			e.record(res, fieldPath, old, existing)
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+// mergeConflict 返回 src 合并到 dst 时第一个冲突的键
+func mergeConflict(dst, src *yaml.Node, p string) error {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		fieldPath := path.FieldPath(p, key.Value)
+
+		existing := mappingValue(dst, key.Value)
+		switch {
+		case existing == nil:
+		case unwrap(existing).Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			if err := mergeConflict(unwrap(existing), value, fieldPath); err != nil {
+				return err
+			}
+		case !sameNode(existing, value):
+			return fmt.Errorf("%w: %s", ErrMergeConflict, fieldPath)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sortKeys 将匹配子树中的 mapping 按键名排序（递归），path 为空时作用于整个文档
+// 合并键 << 排在最前；键上的注释随键移动；不进入别名，锚点在定义处处理
+func (e *Engine) sortKeys(root *yaml.Node, rule *Rule, res *Result) error {
+	matches, err := e.findOrDocument(root, rule)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	res.Matched = len(matches)
+
+	seen := map[*yaml.Node]bool{}
+	for _, m := range matches {
+		res.Changed += sortTree(m.Node, seen)
+	}
+	return nil
+}
+
+// normalize 在 sort_keys 的基础上把布尔与 null 字面量统一为 true、false、null，
+// 用于生成便于比较的确定性输出
+func (e *Engine) normalize(root *yaml.Node, rule *Rule, res *Result) error {
+	matches, err := e.findOrDocument(root, rule)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	res.Matched = len(matches)
+
+	for _, m := range matches {
+		res.Changed += Normalize(m.Node)
+	}
+	return nil
+}
+
+// Normalize 排序节点下所有 mapping 的键并统一布尔与 null 的写法，返回被修改的节点数
+// 供 processor 在规则之后整理整个文档
+func Normalize(node *yaml.Node) int {
+	return sortTree(node, map[*yaml.Node]bool{}) + canonicalLiterals(node, map[*yaml.Node]bool{})
+}
+
+// sortTree 递归排序 node 下的 mapping，返回键顺序发生变化的 mapping 数
+func sortTree(node *yaml.Node, seen map[*yaml.Node]bool) int {
+	if seen[node] {
+		return 0
+	}
+	seen[node] = true
+
+	changed := 0
+	if node.Kind == yaml.MappingNode && sortMapping(node) {
+		changed++
+	}
+	if node.Kind != yaml.AliasNode {
+		for _, child := range node.Content {
+			changed += sortTree(child, seen)
+		}
+	}
+	return changed
+}
+
+// sortMapping 按键名排序 mapping 的键值对（稳定排序，重复键保持相对顺序），合并键排在最前
+func sortMapping(node *yaml.Node) bool {
+	n := len(node.Content) / 2
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	less := func(a, b *yaml.Node) bool {
+		am, bm := a.ShortTag() == "!!merge", b.ShortTag() == "!!merge"
+		if am != bm {
+			return am
+		}
+		return a.Value < b.Value
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return less(node.Content[order[i]*2], node.Content[order[j]*2])
+	})
+
+	moved := false
+	sorted := make([]*yaml.Node, 0, len(node.Content))
+	for i, idx := range order {
+		if idx != i {
+			moved = true
+		}
+		sorted = append(sorted, node.Content[idx*2], node.Content[idx*2+1])
+	}
+	if moved {
+		node.Content = sorted
+	}
+	return moved
+}
+
+// canonicalLiterals 把 plain 写法的布尔（True、FALSE 等）改为 true/false、
+// null 的其他写法（~、Null、NULL）改为 null，返回被修改的标量数
+// 带引号或显式标签的标量、空值不处理；yes/no 等 YAML 1.1 写法在核心 schema 下是字符串，同样不处理
+func canonicalLiterals(node *yaml.Node, seen map[*yaml.Node]bool) int {
+	if seen[node] {
+		return 0
+	}
+	seen[node] = true
+
+	switch node.Kind {
+	case yaml.AliasNode:
+		return 0
+	case yaml.ScalarNode:
+		if node.Style != 0 {
+			return 0
+		}
+		value := node.Value
+		switch node.ShortTag() {
+		case "!!bool":
+			if node.Value == "true" || node.Value == "True" || node.Value == "TRUE" {
+				value = "true"
+			} else {
+				value = "false"
+			}
+		case "!!null":
+			if node.Value != "" {
+				value = "null"
+			}
+		}
+		if value == node.Value {
+			return 0
+		}
+		node.Value = value
+		return 1
+	}
+
+	changed := 0
+	for _, child := range node.Content {
+		changed += canonicalLiterals(child, seen)
+	}
+	return changed
+}
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/path"
+)
+
+// pruneEmpty 删除因本次删除而变空的 mapping/sequence，并逐层向上删除随之变空的父节点，返回删除的节点数
+// emptied 为删除后变空的父节点；原本就为空的节点不处理。文档顶层节点、带锚点的节点保留，
+// 不进入别名，避免删除仍被引用的内容
+func (e *Engine) pruneEmpty(root *yaml.Node, emptied map[*yaml.Node]bool, res *Result) int {
+	if root.Kind == yaml.DocumentNode {
+		if len(root.Content) == 0 {
+			return 0
+		}
+		root = root.Content[0]
+	}
+
+	pruned := 0
+	// prune 处理 node 的子节点，返回 node 是否应从其父节点中删除
+	var prune func(node *yaml.Node, p string) bool
+	prune = func(node *yaml.Node, p string) bool {
+		removedAny := false
+		switch node.Kind {
+		case yaml.MappingNode:
+			content := node.Content[:0:0]
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i], node.Content[i+1]
+				valuePath := path.FieldPath(p, key.Value)
+				if prune(value, valuePath) {
+					e.record(res, valuePath, e.valueOf(value), nil)
+					pruned++
+					removedAny = true
+					continue
+				}
+				content = append(content, key, value)
+			}
+			if removedAny {
+				node.Content = content
+			}
+		case yaml.SequenceNode:
+			content := node.Content[:0:0]
+			for i, item := range node.Content {
+				itemPath := path.ElemPath(p, i)
+				if prune(item, itemPath) {
+					e.record(res, itemPath, e.valueOf(item), nil)
+					pruned++
+					removedAny = true
+					continue
+				}
+				content = append(content, item)
+			}
+			if removedAny {
+				node.Content = content
+			}
+		default:
+			return false
+		}
+		return len(node.Content) == 0 && node.Anchor == "" && (removedAny || emptied[node])
+	}
+	prune(root, "")
+	return pruned
+}
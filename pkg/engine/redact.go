@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultPlaceholder redact 未配置 placeholder 时使用的占位符
+const defaultPlaceholder = "******"
+
+// redact 将匹配的标量值替换为占位符，用于生成可外发的脱敏副本
+func (e *Engine) redact(root *yaml.Node, rule *Rule, res *Result) error {
+	matches, err := e.find(root, rule)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	res.Matched = len(matches)
+
+	for _, m := range matches {
+		node := m.Node
+		if node.Kind != yaml.ScalarNode {
+			continue
+		}
+
+		node.Value = mask(node.Value, rule)
+		// 脱敏后一律按字符串输出，避免 !!int 等标签与占位符冲突
+		node.Tag = "!!str"
+		node.Style = 0
+		e.record(res, m.Path, nil, node)
+		res.Changed++
+	}
+
+	return nil
+}
+
+// mask 计算脱敏后的值
+//   - keep_length: 用占位符首字符按原值长度重复
+//   - hash_prefix: 追加 ":" + 原值 sha256 的前 N 位十六进制
+func mask(value string, rule *Rule) string {
+	placeholder := rule.Placeholder
+	if placeholder == "" {
+		placeholder = defaultPlaceholder
+	}
+
+	masked := placeholder
+	if rule.KeepLength {
+		r, _ := utf8.DecodeRuneInString(placeholder)
+		masked = strings.Repeat(string(r), utf8.RuneCountInString(value))
+	}
+
+	if rule.HashPrefix > 0 {
+		sum := sha256.Sum256([]byte(value))
+		digest := hex.EncodeToString(sum[:])
+		n := rule.HashPrefix
+		if n > len(digest) {
+			n = len(digest)
+		}
+		masked += ":" + digest[:n]
+	}
+
+	return masked
+}
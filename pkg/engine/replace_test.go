@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dlclark/regexp2"
+	"gopkg.in/yaml.v3"
+)
+
+// 替换串中的捕获组引用与 regexp2 一致，模板替换串中模板以外的文字同样展开，模板输出的内容原样保留
+func TestRegexReplace(t *testing.T) {
+	// 未命名的组先编号：$1 是端口，$2 是 name
+	const pattern = `(?<name>[a-z]+):(\d+)`
+	tests := []struct {
+		name        string
+		replacement string
+		count       int
+		want        string
+	}{
+		{name: "number", replacement: "$2-$1", want: "nginx-80 proxy-443"},
+		{name: "name", replacement: "${1}=${name}", want: "80=nginx 443=proxy"},
+		{name: "digits are read greedily", replacement: "$10", want: "$10 $10"},
+		{name: "braces end the reference", replacement: "${1}0", want: "800 4430"},
+		{name: "name without braces is literal", replacement: "$name10", want: "$name10 $name10"},
+		{name: "unknown group is literal", replacement: "${port}-$9", want: "${port}-$9 ${port}-$9"},
+		{name: "escaped dollar", replacement: "$$1", want: "$1 $1"},
+		{name: "whole match", replacement: "[$&]", want: "[nginx:80] [proxy:443]"},
+		{name: "group function", replacement: "${name|upper}:${1}", want: "NGINX:80 PROXY:443"},
+		{name: "count", replacement: "$1", count: 1, want: "80 proxy:443"},
+		{name: "template", replacement: "{{ .Groups.name | upper }}:$1", want: "NGINX:80 PROXY:443"},
+		{name: "template output is not expanded", replacement: "{{ .Vars.price }}-${name}", want: "$1-nginx $1-proxy"},
+		{name: "braces in template strings", replacement: `{{ upper "}}$1" }}$1`, want: "}}$180 }}$1443"},
+		{name: "template comment", replacement: "{{/* }} */}}{{ .File }}/$2", want: "app.yaml/nginx app.yaml/proxy"},
+		{name: "helm template is text", replacement: "{{ .Values.x }}$2", want: "{{ .Values.x }}nginx {{ .Values.x }}proxy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte("a: nginx:80 proxy:443\n"), &doc); err != nil {
+				t.Fatal(err)
+			}
+			e := NewEngine()
+			e.SetFile("app.yaml")
+			e.SetParams(map[string]string{"price": "$1"})
+			e.Reset()
+			rule := &Rule{Action: ActionRegexReplace, Path: "a", Pattern: pattern, Value: tt.replacement, Count: tt.count}
+			if _, err := e.Apply(&doc, rule); err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if got := doc.Content[0].Content[1].Value; got != tt.want {
+				t.Errorf("a = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// 不含模板与组函数的替换串展开结果与 regexp2 自身的替换相同
+func TestExpandRefMatchesRegexp2(t *testing.T) {
+	re := regexp2.MustCompile(`(?<name>[a-z]+):(\d+)`, 0)
+	const input = "x nginx:80 y"
+	for _, replacement := range []string{
+		"$1$2", "$12", "${1}2", "${name}", "${ name }", "${}", "$name", "$$", "$$$1",
+		"$&", "$`", "$'", "$+", "$_", "$", "$x", "${name", "a$",
+	} {
+		want, err := re.Replace(input, replacement, -1, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &replacer{parts: splitReplacement(replacement, false)}
+		got, _, err := replaceMatches(re, input, r, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%q: got %q, want %q", replacement, got, want)
+		}
+	}
+}
+
+// 一次查找同时得到替换结果与次数，结果与 regexp2 自身的替换相同（含空匹配与多字节字符）
+func TestReplaceMatchesCount(t *testing.T) {
+	tests := []struct {
+		pattern string
+		input   string
+		limit   int
+		want    int
+	}{
+		{`\d`, "a1b2c3", -1, 3},
+		{`\d`, "a1b2c3", 2, 2},
+		{`\d`, "abc", -1, 0},
+		{`x*`, "ab", -1, 3},
+		{`镜像`, "旧镜像/新镜像", -1, 2},
+		{`(?<=镜)像`, "镜像像", 1, 1},
+	}
+	for _, tt := range tests {
+		re := regexp2.MustCompile(tt.pattern, 0)
+		want, err := re.Replace(tt.input, "[$&]", -1, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &replacer{parts: splitReplacement("[$&]", false)}
+		got, n, err := replaceMatches(re, tt.input, r, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want || n != tt.want {
+			t.Errorf("%q on %q: got %q, %d replacements, want %q, %d", tt.pattern, tt.input, got, n, want, tt.want)
+		}
+	}
+}
+
+// 第一处之后的查找超时同样报错，而不是写入空串
+func TestRegexReplaceTimeout(t *testing.T) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte("a: a "+strings.Repeat("a", 40)+"b\n"), &doc); err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine()
+	e.SetTimeouts(10*time.Millisecond, 0)
+	rule := &Rule{Action: ActionRegexReplace, Path: "a", Pattern: `^a|(a+)+c`, Value: "x"}
+	if _, err := e.Apply(&doc, rule); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Apply() error = %v, want ErrTimeout", err)
+	}
+	if got := doc.Content[0].Content[1].Value; got == "" {
+		t.Error("value was cleared")
+	}
+}
@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dlclark/regexp2"
+	"gopkg.in/yaml.v3"
+)
+
+// selects 判断规则的 kind、match 与 when 是否选中该文档
+func (e *Engine) selects(root *yaml.Node, rule *Rule) (bool, error) {
+	if rule.Kind != "" && topField(root, "kind") != rule.Kind {
+		return false, nil
+	}
+	if rule.Match != nil {
+		ok, err := e.Selects(root, rule.Match)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	if rule.When == "" {
+		return true, nil
+	}
+	return e.evalWhen(root, rule)
+}
+
+// Selects 判断文档是否满足选择器
+func (e *Engine) Selects(root *yaml.Node, m *Selector) (bool, error) {
+	if m.Kind != "" && topField(root, "kind") != m.Kind {
+		return false, nil
+	}
+	if m.APIVersion != "" && topField(root, "apiVersion") != m.APIVersion {
+		return false, nil
+	}
+
+	metadata := mappingValue(documentBody(root), "metadata")
+	for _, c := range []struct{ key, pattern string }{
+		{"name", m.Name},
+		{"namespace", m.Namespace},
+	} {
+		if c.pattern == "" {
+			continue
+		}
+		ok, err := e.matchWhole(c.pattern, scalarValue(mappingValue(metadata, c.key)))
+		if err != nil {
+			return false, fmt.Errorf("match.%s: %w", c.key, err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// matchWhole 判断正则是否匹配整个字符串
+func (e *Engine) matchWhole(pattern, s string) (bool, error) {
+	re, err := CompileSelectorPattern(pattern)
+	if err != nil {
+		return false, err
+	}
+	if re.MatchTimeout, err = e.regexTimeout(); err != nil {
+		return false, err
+	}
+	ok, err := re.MatchString(s)
+	if err != nil {
+		return false, fmt.Errorf("regex match: %w", timeoutError(err))
+	}
+	return ok, nil
+}
+
+// ParseSelector 解析 key=value 以逗号分隔的选择器，如 kind=Deployment,name=web
+// key 为 kind、apiVersion、name、namespace，含义同规则的 match
+func ParseSelector(s string) (*Selector, error) {
+	var sel Selector
+	for _, part := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid selector %q, expected key=value", part)
+		}
+
+		var field *string
+		switch key {
+		case "kind":
+			field = &sel.Kind
+		case "apiVersion":
+			field = &sel.APIVersion
+		case "name":
+			field = &sel.Name
+		case "namespace":
+			field = &sel.Namespace
+		default:
+			return nil, fmt.Errorf("unknown selector key %q, expected kind, apiVersion, name or namespace", key)
+		}
+		if *field != "" {
+			return nil, fmt.Errorf("duplicate selector key %q", key)
+		}
+		if key == "name" || key == "namespace" {
+			if _, err := CompileSelectorPattern(value); err != nil {
+				return nil, fmt.Errorf("selector %s: %w", key, err)
+			}
+		}
+		*field = value
+	}
+	return &sel, nil
+}
+
+// CompileSelectorPattern 编译 match.name/match.namespace 的正则，要求匹配整个值
+func CompileSelectorPattern(pattern string) (*regexp2.Regexp, error) {
+	re, err := regexp2.Compile(`^(?:`+pattern+`)$`, 0)
+	if err != nil {
+		return nil, fmt.Errorf("compile regex: %w", err)
+	}
+	return re, nil
+}
+
+// documentBody 返回文档的根节点
+func documentBody(root *yaml.Node) *yaml.Node {
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		return root.Content[0]
+	}
+	return root
+}
+
+// topField 返回文档顶层字段的标量值，不存在时返回空串
+func topField(root *yaml.Node, key string) string {
+	return scalarValue(mappingValue(documentBody(root), key))
+}
+
+// mappingValue 返回 mapping 中 key 对应的值节点，node 不是 mapping 或没有该字段时返回 nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// scalarValue 返回标量节点的值，其他情况返回空串
+func scalarValue(node *yaml.Node) string {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
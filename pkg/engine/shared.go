@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"cmp"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/path"
+)
+
+// sharedNodes 返回被别名（含合并键）引用的锚点及其所有后代节点，修改这些节点会影响所有引用方
+func sharedNodes(root *yaml.Node) map[*yaml.Node]bool {
+	var anchors []*yaml.Node
+	seen := map[*yaml.Node]bool{}
+	var walk func(node *yaml.Node)
+	walk = func(node *yaml.Node) {
+		if node.Kind == yaml.AliasNode {
+			if node.Alias != nil && !seen[node.Alias] {
+				seen[node.Alias] = true
+				anchors = append(anchors, node.Alias)
+			}
+			return
+		}
+		for _, child := range node.Content {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	shared := map[*yaml.Node]bool{}
+	var mark func(node *yaml.Node)
+	mark = func(node *yaml.Node) {
+		if shared[node] {
+			return
+		}
+		shared[node] = true
+		for _, child := range node.Content {
+			if child.Kind != yaml.AliasNode {
+				mark(child)
+			}
+		}
+	}
+	for _, a := range anchors {
+		mark(a)
+	}
+	return shared
+}
+
+// checkShared targets 为 error 时，任一匹配节点位于被别名引用的内容中（修改会波及别名）、
+// 或其子树包含被引用的锚点（替换、删除会使别名失去目标）时返回 ErrAliasedTarget
+func (e *Engine) checkShared(root *yaml.Node, rule *Rule, matches []*path.Match) error {
+	if rule.Targets != TargetsError || len(matches) == 0 {
+		return nil
+	}
+	shared := sharedNodes(root)
+	if len(shared) == 0 {
+		return nil
+	}
+	for _, m := range matches {
+		if (m.Parent != nil && shared[m.Parent]) || containsShared(m.Node, shared) {
+			return fmt.Errorf("%w: %s", ErrAliasedTarget, cmp.Or(m.Path, "<root>"))
+		}
+	}
+	return nil
+}
+
+// containsShared 判断 node 或其后代（不进入别名）是否属于 shared
+func containsShared(node *yaml.Node, shared map[*yaml.Node]bool) bool {
+	if shared[node] {
+		return true
+	}
+	for _, child := range node.Content {
+		if child.Kind != yaml.AliasNode && containsShared(child, shared) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// transform 的操作
+const (
+	TransformAddPrefix        = "add_prefix"        // 加上前缀，已有该前缀时不变
+	TransformAddSuffix        = "add_suffix"        // 加上后缀，已有该后缀时不变
+	TransformToUpper          = "to_upper"          // 转为大写
+	TransformToLower          = "to_lower"          // 转为小写
+	TransformTrim             = "trim"              // 去掉首尾空白；参数为字符集时去掉首尾的这些字符
+	TransformReplaceSubstring = "replace_substring" // 把所有 old 替换为 new，参数为 {old, new}
+)
+
+// stringOp transform 的一步操作
+type stringOp struct {
+	name string
+	arg  string // add_prefix、add_suffix 的文本，trim 的字符集，replace_substring 的 old
+	new  string // replace_substring 的 new
+}
+
+// parseTransforms 解析 transform 的 value：一个操作或按顺序执行的操作列表，
+// 操作写作操作名（to_upper、to_lower、trim）或只有一个键的 mapping（add_prefix: v-）
+func parseTransforms(value interface{}) ([]stringOp, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		list = []interface{}{value}
+	}
+	if len(list) == 0 || value == nil {
+		return nil, fmt.Errorf("value must be an operation or a non-empty list of operations for transform")
+	}
+
+	ops := make([]stringOp, 0, len(list))
+	for i, item := range list {
+		op, err := parseTransform(item)
+		if err != nil {
+			if len(list) > 1 {
+				return nil, fmt.Errorf("value[%d]: %w", i, err)
+			}
+			return nil, fmt.Errorf("value: %w", err)
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// parseTransform 解析单个操作
+func parseTransform(item interface{}) (stringOp, error) {
+	var op stringOp
+	var arg interface{}
+	hasArg := false
+	switch v := item.(type) {
+	case string:
+		op.name = v
+	case map[string]interface{}:
+		if len(v) != 1 {
+			return op, fmt.Errorf("operation must be a mapping with exactly one key")
+		}
+		for name, a := range v {
+			op.name, arg, hasArg = name, a, true
+		}
+	default:
+		return op, fmt.Errorf("operation must be a name or a mapping of name to argument")
+	}
+
+	switch op.name {
+	case TransformAddPrefix, TransformAddSuffix:
+		s, ok := arg.(string)
+		if !ok || s == "" {
+			return op, fmt.Errorf("%s requires a non-empty string", op.name)
+		}
+		op.arg = s
+	case TransformToUpper, TransformToLower:
+		if hasArg {
+			return op, fmt.Errorf("%s takes no argument", op.name)
+		}
+	case TransformTrim:
+		if hasArg {
+			s, ok := arg.(string)
+			if !ok || s == "" {
+				return op, fmt.Errorf("trim argument must be a non-empty string of characters")
+			}
+			op.arg = s
+		}
+	case TransformReplaceSubstring:
+		m, ok := arg.(map[string]interface{})
+		if !ok {
+			return op, fmt.Errorf("replace_substring requires a mapping with old and new")
+		}
+		for k, v := range m {
+			s, ok := v.(string)
+			switch {
+			case k != "old" && k != "new":
+				return op, fmt.Errorf("replace_substring: unknown field %q, expected old and new", k)
+			case !ok:
+				return op, fmt.Errorf("replace_substring: %s must be a string", k)
+			case k == "old":
+				op.arg = s
+			default:
+				op.new = s
+			}
+		}
+		if op.arg == "" {
+			return op, fmt.Errorf("replace_substring: old must be a non-empty string")
+		}
+	default:
+		return op, fmt.Errorf("unknown operation %q, expected %s, %s, %s, %s, %s or %s", op.name,
+			TransformAddPrefix, TransformAddSuffix, TransformToUpper, TransformToLower, TransformTrim, TransformReplaceSubstring)
+	}
+	return op, nil
+}
+
+// ValidateTransforms 校验 transform 的操作
+func ValidateTransforms(value interface{}) error {
+	_, err := parseTransforms(value)
+	return err
+}
+
+// apply 对字符串执行操作
+func (op stringOp) apply(s string) string {
+	switch op.name {
+	case TransformAddPrefix:
+		if !strings.HasPrefix(s, op.arg) {
+			s = op.arg + s
+		}
+	case TransformAddSuffix:
+		if !strings.HasSuffix(s, op.arg) {
+			s += op.arg
+		}
+	case TransformToUpper:
+		s = strings.ToUpper(s)
+	case TransformToLower:
+		s = strings.ToLower(s)
+	case TransformTrim:
+		if op.arg == "" {
+			s = strings.TrimSpace(s)
+		} else {
+			s = strings.Trim(s, op.arg)
+		}
+	case TransformReplaceSubstring:
+		s = strings.ReplaceAll(s, op.arg, op.new)
+	}
+	return s
+}
+
+// transformStrings 对匹配到的字符串依次执行 value 中的操作
+// 只处理字符串标量，数字、布尔、null 与非标量节点跳过；结果需要引号才能保持字符串类型时（如 "v1" 去掉 v 后为 "1"）输出时自动加引号
+func (e *Engine) transformStrings(root *yaml.Node, rule *Rule, res *Result) error {
+	ops, err := parseTransforms(rule.Value)
+	if err != nil {
+		return err
+	}
+
+	matches, err := e.find(root, rule)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	res.Matched = len(matches)
+
+	for _, m := range matches {
+		node := m.Node
+		if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!str" {
+			continue
+		}
+		value := node.Value
+		for _, op := range ops {
+			value = op.apply(value)
+		}
+		if value == node.Value {
+			continue
+		}
+		old := e.valueOf(node)
+		node.Value = value
+		e.record(res, m.Path, old, node)
+		res.Changed++
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/path"
+)
+
+// SetStrictTypes 设置是否检查写入值的类型：开启后 replace、regex_replace、merge 写入的值
+// 与原节点类型不同时（如把字符串写到 !!int 的 replicas 上）规则报错，设置了 coerce 的规则除外
+func (e *Engine) SetStrictTypes(strict bool) {
+	e.strictTypes = strict
+}
+
+// typeOf 节点在类型检查中的类型：标量为解析后的标签，集合为 mapping、sequence
+func typeOf(node *yaml.Node) string {
+	node = unwrap(node)
+	if node.Kind == yaml.ScalarNode {
+		return node.ShortTag()
+	}
+	return path.KindName(node.Kind)
+}
+
+// checkType 检查写入 old 位置的新值 value，返回实际写入的值
+// coerce 时标量新值转换为原节点的类型并记录一条警告，无法转换时返回 ErrTypeChange；
+// 否则开启 strictTypes 时类型不同返回 ErrTypeChange。任一侧为 null 时不检查
+func (e *Engine) checkType(old, value *yaml.Node, p string, rule *Rule) (*yaml.Node, error) {
+	oldType, newType := typeOf(old), typeOf(value)
+	if oldType == newType || oldType == "!!null" || newType == "!!null" {
+		return value, nil
+	}
+
+	if rule.Coerce && old.Kind == yaml.ScalarNode && value.Kind == yaml.ScalarNode {
+		if !coercible(value.Value, oldType) {
+			return nil, fmt.Errorf("%s: %w: cannot convert %q to %s", p, ErrTypeChange, value.Value, typeLabel(oldType))
+		}
+		e.warn(fmt.Sprintf("%s: coerced %s %q to %s", p, typeLabel(newType), value.Value, typeLabel(oldType)))
+		cp := *value
+		cp.Tag = oldType
+		if oldType != "!!str" {
+			// 编码字符串 "3" 时带的引号会使其仍按字符串写出
+			cp.Style &^= yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle
+		}
+		return &cp, nil
+	}
+	if !e.strictTypes {
+		return value, nil
+	}
+	got := typeLabel(newType)
+	if value.Kind == yaml.ScalarNode {
+		got += fmt.Sprintf(" %q", value.Value)
+	}
+	return nil, fmt.Errorf("%s: %w: %s → %s (strict types)", p, ErrTypeChange, typeLabel(oldType), got)
+}
+
+// coercible 判断标量文本能否按 tag 类型写出：!!str 总是可以，
+// 其他类型要求文本按核心 schema 解析为该类型，!!float 也接受整数
+func coercible(value, tag string) bool {
+	if tag == "!!str" {
+		return true
+	}
+	probe := yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	got := probe.ShortTag()
+	return got == tag || (tag == "!!float" && got == "!!int")
+}
+
+// typeLabel 去掉标签的 !! 前缀用于报错信息
+func typeLabel(t string) string {
+	return strings.TrimPrefix(t, "!!")
+}
@@ -1,19 +1,174 @@
 package engine
 
+import "github.com/glesirok/yamleditor/pkg/path"
+
 // ActionType 定义操作类型
 type ActionType string
 
 const (
 	ActionReplace      ActionType = "replace"
+	ActionAdd          ActionType = "add"
+	ActionAppend       ActionType = "append"
+	ActionPrepend      ActionType = "prepend"
 	ActionDelete       ActionType = "delete"
+	ActionMerge        ActionType = "merge"
+	ActionRenameKey    ActionType = "rename_key"
+	ActionRenameKeys   ActionType = "rename_keys"
+	ActionQuoteStyle   ActionType = "quote_style"
 	ActionRegexReplace ActionType = "regex_replace"
+	ActionRedact       ActionType = "redact"
+	ActionEncrypt      ActionType = "encrypt"
+	ActionDecrypt      ActionType = "decrypt"
+	ActionSortKeys     ActionType = "sort_keys"
+	ActionNormalize    ActionType = "normalize"
+	ActionIncrement    ActionType = "increment"
+	ActionTransform    ActionType = "transform"
+
+	// 按镜像引用的组成部分修改，见 setImage
+	ActionSetImageTag      ActionType = "set_image_tag"
+	ActionSetImageRegistry ActionType = "set_image_registry"
+	ActionSetImageDigest   ActionType = "set_image_digest"
+
+	ActionSetAnchor           ActionType = "set_anchor"
+	ActionDeduplicateAsAnchor ActionType = "deduplicate_as_anchor"
+	ActionResolveAliases      ActionType = "resolve_aliases"
+
+	// ActionSetHeader 写入或刷新文件开头的注释块，作用于整个文件，由 processor 执行
+	ActionSetHeader ActionType = "set_header"
+)
+
+// Interpolate value 中 ${NAME} 的处理方式
+const (
+	InterpolateNone = "none" // 原样保留（默认）
+	InterpolateEnv  = "env"  // 按环境变量展开
+)
+
+// InterpolatesEnv value 中的 ${NAME} 是否按环境变量展开：须设置 interpolate: env；
+// regex_replace 的 ${name} 是捕获组引用，从不展开
+func (r *Rule) InterpolatesEnv() bool {
+	return r.Interpolate == InterpolateEnv && r.Action != ActionRegexReplace
+}
+
+// PathOptional 省略 path 时是否作用于整个文档
+func (a ActionType) PathOptional() bool {
+	return a == ActionRenameKeys || a == ActionQuoteStyle || a == ActionSortKeys || a == ActionNormalize
+}
+
+// quote_style 的取值
+const (
+	QuotePlain  = "plain"  // 去掉不必要的引号，只在按核心 schema 解析不会改变类型时生效
+	QuoteSingle = "single" // 统一为单引号
+	QuoteDouble = "double" // 统一为双引号
+)
+
+// style 的取值：新值中字符串标量的写法
+const (
+	StylePlain   = QuotePlain
+	StyleSingle  = QuoteSingle
+	StyleDouble  = QuoteDouble
+	StyleLiteral = "literal" // 块风格 |，保留换行
+	StyleFolded  = "folded"  // 块风格 >，单个换行折叠为空格
+)
+
+// ValidStyle 判断 style 取值是否合法
+func ValidStyle(style string) bool {
+	_, ok := scalarStyles[style]
+	return ok
+}
+
+// Targets 路径经过别名时的修改目标
+const (
+	TargetsAnchorsOnly    = "anchors_only"    // 修改锚点本身，所有别名随之变化（默认）
+	TargetsResolvedCopies = "resolved_copies" // 将命中的别名展开为副本后只修改副本
+	TargetsError          = "error"           // 修改会影响别名引用的内容时报错，文档不变
 )
 
 // Rule 表示一条修改规则
 type Rule struct {
-	Action             ActionType  `yaml:"action"`
-	Path               string      `yaml:"path"`
-	Value              interface{} `yaml:"value,omitempty"`
-	Pattern            string      `yaml:"pattern,omitempty"`            // 用于 regex_replace
-	ContinueOnNotFound bool        `yaml:"continue_on_not_found,omitempty"` // 找不到节点时是否继续
+	ID                 string       `yaml:"id,omitempty"` // 规则标识，在统计报告、修改事件与审计报告中随序号输出，规则文件内唯一
+	Action             ActionType   `yaml:"action"`
+	Path               string       `yaml:"path"`
+	Value              interface{}  `yaml:"value,omitempty"`
+	ValueFrom          *ValueFrom   `yaml:"value_from,omitempty"`            // 从外部文件读取 value，规则文件加载时展开
+	Pattern            string       `yaml:"pattern,omitempty"`               // 用于 regex_replace
+	ContinueOnNotFound bool         `yaml:"continue_on_not_found,omitempty"` // 找不到节点时是否继续
+	Capture            string       `yaml:"capture,omitempty"`               // 将匹配内容保存为变量，供同文档后续规则使用
+	Targets            string       `yaml:"targets,omitempty"`               // anchors_only | resolved_copies | error
+	Interpolate        string       `yaml:"interpolate,omitempty"`           // env: value 中的 ${NAME} 按环境变量展开；默认 none，原样保留
+	Options            MatchOptions `yaml:"options,omitempty"`               // 路径条件的匹配选项
+	Count              int          `yaml:"count,omitempty"`                 // regex_replace: 每个标量最多替换前 N 处
+	Global             *bool        `yaml:"global,omitempty"`                // regex_replace: false 时只替换第一处
+	RequireMatch       bool         `yaml:"require_match,omitempty"`         // regex_replace: pattern 在所有匹配节点上都未命中时报错
+	CreateMissing      bool         `yaml:"create_missing,omitempty"`        // replace: 元素缺少路径中的字段时自动创建
+	SkipMissing        bool         `yaml:"skip_missing,omitempty"`          // replace: 静默跳过缺少字段的元素，不报告
+	Kind               string       `yaml:"kind,omitempty"`                  // match.kind 的简写，加载规则文件时改写为 match.kind
+	Match              *Selector    `yaml:"match,omitempty"`                 // 文档级选择器，不满足的文档跳过该规则
+	When               string       `yaml:"when,omitempty"`                  // 条件表达式，如 spec.replicas > 3，不满足的文档跳过该规则
+	Where              *path.Where  `yaml:"where,omitempty"`                 // 路径中 [?] 选中元素的条件
+	Files              []string     `yaml:"files,omitempty"`                 // 只作用于路径匹配其中任一 glob 的文件，由 processor 判断
+	InsertAt           *int         `yaml:"insert_at,omitempty"`             // append: 插入到第 N 个元素之前，超出长度时追加到末尾
+	Strategy           string       `yaml:"strategy,omitempty"`              // merge: 键冲突时的处理 theirs | ours | error
+	Style              string       `yaml:"style,omitempty"`                 // 新值中字符串的写法 plain | single | double | literal | folded
+	PruneEmpty         bool         `yaml:"prune_empty,omitempty"`           // delete: 删除后变空的父 mapping/sequence 逐层向上一并删除
+	Coerce             bool         `yaml:"coerce,omitempty"`                // replace/regex_replace/merge: 新值转换为原节点的类型，无法转换时报错
+	By                 *float64     `yaml:"by,omitempty"`                    // increment: 增量，默认 1，负数递减
+	Min                *float64     `yaml:"min,omitempty"`                   // increment: 递减的下限，原值不大于它时不递减
+	Max                *float64     `yaml:"max,omitempty"`                   // increment: 递增的上限，原值不小于它时不递增
+	Description        string       `yaml:"description,omitempty"`           // 规则用途说明，随统计报告与修改事件输出
+
+	// 弃用信息：执行时输出警告，过了 sunset 日期（YYYY-MM-DD）后规则文件校验失败
+	Deprecated string `yaml:"deprecated,omitempty"`
+	Sunset     string `yaml:"sunset,omitempty"`
+
+	// set_anchor / deduplicate_as_anchor 使用的锚点名
+	Anchor string `yaml:"anchor,omitempty"`
+
+	// redact 专用
+	Placeholder string `yaml:"placeholder,omitempty"` // 替换占位符，默认 ******
+	KeepLength  bool   `yaml:"keep_length,omitempty"` // 按原值长度重复占位符首字符
+	HashPrefix  int    `yaml:"hash_prefix,omitempty"` // 追加原值 sha256 前 N 位，便于比对
+}
+
+// ValueFrom value 的外部来源
+type ValueFrom struct {
+	File string `yaml:"file"` // YAML 文件，相对路径相对规则文件所在目录
+}
+
+// MatchOptions 路径条件（[field=value]）的匹配选项
+type MatchOptions struct {
+	CaseInsensitive bool `yaml:"case_insensitive,omitempty"` // 忽略大小写
+	Trim            bool `yaml:"trim,omitempty"`             // 比较前去除首尾空白
+
+	// CaseInsensitiveKeys 路径中的字段精确匹配失败时忽略大小写再找一次（如 Metadata、apiversion），命中时产生警告
+	CaseInsensitiveKeys bool `yaml:"case_insensitive_keys,omitempty"`
+}
+
+// Selector 按文档顶层字段选择规则作用的文档，各字段同时满足才匹配，空字段不限制
+// Name/Namespace 为正则，需匹配整个值；文档缺少对应字段时视为空串
+type Selector struct {
+	Kind       string `yaml:"kind,omitempty"`
+	APIVersion string `yaml:"apiVersion,omitempty"`
+	Name       string `yaml:"name,omitempty"`      // metadata.name
+	Namespace  string `yaml:"namespace,omitempty"` // metadata.namespace
+}
+
+// Result 单条规则在一个文档上的执行结果
+type Result struct {
+	Matched      int      // 路径匹配到的节点数
+	Changed      int      // 实际被修改的节点数
+	Replacements int      // regex_replace 发生的替换次数
+	Skipped      []string // 通配展开后因缺少字段被跳过的元素（"具体路径: 原因"）
+	Warnings     []string // 需要提示用户的情况，如字段仅按忽略大小写命中
+	Missing      error    // continue_on_not_found 时被忽略的未找到错误，路径找到节点时为 nil
+	Changes      []Change // 逐节点的修改记录，仅在 SetTrackChanges(true) 后填充
+	Inapplicable bool     // 规则的 kind 或 match 与文档不符，未执行
+}
+
+// Change 单个节点的修改
+// Old/New 为节点解码后的值；删除时 New 为 nil，
+// redact/encrypt 不记录 Old、decrypt 不记录 New，避免明文外泄
+type Change struct {
+	Path string
+	Old  interface{}
+	New  interface{}
 }
@@ -0,0 +1,165 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/dlclark/regexp2"
+	"gopkg.in/yaml.v3"
+)
+
+// Capture 规则通过 capture 记录的匹配信息
+// 模板中 {{ .Vars.name }} 输出第一个匹配值，
+// {{ .Vars.name.Count }}、{{ index .Vars.name.Groups "1" }} 访问其余信息
+type Capture struct {
+	Value  string            // 第一个匹配标量的值
+	Values []string          // 所有匹配标量的值
+	Count  int               // 匹配节点数
+	Groups map[string]string // pattern 在第一个值上的捕获组（按序号和名称）
+}
+
+func (c *Capture) String() string {
+	return c.Value
+}
+
+// capture 记录规则匹配到的内容
+func (e *Engine) capture(root *yaml.Node, rule *Rule) error {
+	matches, _, err := e.search(root, rule)
+	if err != nil {
+		return err
+	}
+
+	c := &Capture{Count: len(matches), Groups: map[string]string{}}
+	for _, m := range matches {
+		if m.Node.Kind == yaml.ScalarNode {
+			c.Values = append(c.Values, m.Node.Value)
+		}
+	}
+	if len(c.Values) > 0 {
+		c.Value = c.Values[0]
+	}
+
+	if rule.Pattern != "" && c.Value != "" {
+		re, err := regexp2.Compile(rule.Pattern, 0)
+		if err != nil {
+			return fmt.Errorf("compile regex: %w", err)
+		}
+		if re.MatchTimeout, err = e.regexTimeout(); err != nil {
+			return err
+		}
+		m, err := re.FindStringMatch(c.Value)
+		if err != nil {
+			return fmt.Errorf("regex match: %w", timeoutError(err))
+		}
+		if m != nil {
+			c.Groups = groups(m)
+		}
+	}
+
+	e.vars[rule.Capture] = c
+	return nil
+}
+
+// templateData 模板可访问的上下文
+//   - .Vars   当前文档 capture 的变量
+//   - .Groups regex_replace 当前匹配的捕获组（按序号和名称）
+//   - .File   当前处理的文件路径
+//   - .Doc    当前文档解码后的内容，如 {{ .Doc.metadata.name }}
+type templateData struct {
+	Vars   map[string]*Capture
+	Groups map[string]string
+	File   string
+
+	root    *yaml.Node
+	doc     interface{}
+	environ map[string]string // 非 nil 时代替进程环境变量，见 Engine.SetEnviron
+}
+
+// Doc 按需解码文档，同一次规则执行内只解码一次
+func (d *templateData) Doc() (interface{}, error) {
+	if d.doc == nil && d.root != nil {
+		if err := d.root.Decode(&d.doc); err != nil {
+			return nil, err
+		}
+	}
+	return d.doc, nil
+}
+
+func (e *Engine) templateData(root *yaml.Node) *templateData {
+	return &templateData{Vars: e.vars, File: e.file, root: root, environ: e.environ}
+}
+
+// expand 展开规则 value 中的环境变量引用（${NAME}，仅 interpolate: env），再以模板展开 value 与 pattern
+// 只渲染引用了 .Vars、.Env 等上下文或模板函数的字符串（见 ownTemplate），是否渲染只取决于规则本身；
+// Helm 等其他工具的模板文本原样保留，与之混写时用 {{ "{{" }} 或 {{`{{ .Values.x }}`}} 转义。
+// regex_replace 的 value 需要逐个匹配渲染，留给 regexReplace 处理
+func (e *Engine) expand(root *yaml.Node, rule *Rule) (*Rule, error) {
+	expanded := *rule
+	var err error
+	if rule.InterpolatesEnv() {
+		if expanded.Value, err = interpolateValue(rule.Value, e.LookupEnv); err != nil {
+			return nil, fmt.Errorf("expand value: %w", err)
+		}
+	}
+
+	data := e.templateData(root)
+	rule = &expanded
+	if expanded.Pattern, err = render(rule.Pattern, data); err != nil {
+		return nil, fmt.Errorf("expand pattern: %w", err)
+	}
+	if rule.Action != ActionRegexReplace {
+		if expanded.Value, err = expandValue(rule.Value, data); err != nil {
+			return nil, fmt.Errorf("expand value: %w", err)
+		}
+	}
+	return &expanded, nil
+}
+
+// expandValue 递归展开 value 中的字符串
+func expandValue(v interface{}, data interface{}) (interface{}, error) {
+	return mapStrings(v, func(s string) (string, error) {
+		return render(s, data)
+	})
+}
+
+// render 渲染单个模板字符串，不含 {{ 或不是给本工具渲染的模板（见 ownTemplate）时直接返回
+func render(s string, data interface{}) (string, error) {
+	if !strings.Contains(s, "{{") || !ownTemplate(s) {
+		return s, nil
+	}
+
+	tmpl, err := parseTemplate(s)
+	if err != nil {
+		return "", err
+	}
+	return execute(tmpl, data)
+}
+
+func parseTemplate(s string) (*template.Template, error) {
+	return template.New("").Funcs(funcs).Option("missingkey=error").Parse(s)
+}
+
+func execute(tmpl *template.Template, data interface{}) (string, error) {
+	if d, ok := data.(*templateData); ok && d.environ != nil {
+		tmpl.Funcs(template.FuncMap{"env": func(name string) string { return d.environ[name] }})
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// groups 以序号和名称为键收集匹配的捕获组
+func groups(m *regexp2.Match) map[string]string {
+	result := map[string]string{}
+	for _, g := range m.Groups() {
+		result[strconv.Itoa(g.Index)] = g.String()
+		if g.Name != strconv.Itoa(g.Index) {
+			result[g.Name] = g.String()
+		}
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 只有引用本工具上下文或模板函数的 {{ }} 才渲染，Helm 等其他工具的模板文本原样写入
+func TestExpandTemplates(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "helm value", value: "{{ .Values.image }}", want: "{{ .Values.image }}"},
+		{name: "helm function", value: `{{ include "app.labels" . | nindent 4 }}`, want: `{{ include "app.labels" . | nindent 4 }}`},
+		{name: "helm control", value: "{{- if .Values.debug }}--debug{{ end }}", want: "{{- if .Values.debug }}--debug{{ end }}"},
+		{name: "unparsable text", value: "{{ not closed", want: "{{ not closed"},
+		{name: "file", value: "{{ .File }}", want: "app.yaml"},
+		{name: "doc", value: "{{ .Doc.kind }}-x", want: "Pod-x"},
+		{name: "function", value: `{{ upper "a" }}`, want: "A"},
+		{name: "root variable", value: "{{ $.File }}", want: "app.yaml"},
+		{name: "mixed with escape", value: `{{ "{{" }} .Values.tag }}-{{ .Doc.kind }}`, want: "{{ .Values.tag }}-Pod"},
+		{name: "missing variable", value: "{{ .Vars.missing }}", wantErr: true},
+		{name: "unknown function with context", value: "{{ .File | uper }}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte("kind: Pod\na: x\n"), &doc); err != nil {
+				t.Fatal(err)
+			}
+			e := NewEngine()
+			e.SetFile("app.yaml")
+			_, err := e.Apply(&doc, &Rule{Action: ActionReplace, Path: "a", Value: tt.value})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Apply() error = nil, want template error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if got := doc.Content[0].Content[3].Value; got != tt.want {
+				t.Errorf("a = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
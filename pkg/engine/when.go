@@ -0,0 +1,474 @@
+package engine
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/path"
+)
+
+// When 规则的 when 条件表达式，按文档求值
+//
+// 语法：
+//   - 操作数为路径（相对文档根，语法同规则路径）或字面量："str"、'str'、数字、true、false、null
+//   - 比较：==、!=、>、>=、<、<=；逻辑：&&、||、!，可用括号分组
+//   - 单独的操作数按真值判断：路径存在且值不是 null、false、空串、0、空 mapping/列表
+//
+// 路径不存在时按 null 比较；路径匹配多个节点时任一节点满足即为真，!= 要求所有节点都不相等。
+// 两侧都是数字时按数值比较，否则按字符串比较；有一侧为 null 时大小比较为假
+type When struct {
+	source string
+	root   whenExpr
+}
+
+// ParseWhen 解析 when 表达式
+func ParseWhen(s string) (*When, error) {
+	tokens, err := tokenizeWhen(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &whenParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.tokens[p.pos].text, p.tokens[p.pos].offset)
+	}
+	return &When{source: s, root: expr}, nil
+}
+
+// String 返回表达式原文
+func (w *When) String() string {
+	return w.source
+}
+
+// whenExpr 表达式节点
+type whenExpr interface {
+	eval(c *whenContext) (bool, error)
+}
+
+// whenContext 求值上下文：文档与查找路径使用的导航器
+type whenContext struct {
+	root *yaml.Node
+	nav  *path.Navigator
+}
+
+// evalWhen 在文档上对规则的 when 求值，表达式按原文缓存
+func (e *Engine) evalWhen(root *yaml.Node, rule *Rule) (bool, error) {
+	w, ok := e.whens[rule.When]
+	if !ok {
+		var err error
+		if w, err = ParseWhen(rule.When); err != nil {
+			return false, fmt.Errorf("when: %w", err)
+		}
+		if e.whens == nil {
+			e.whens = map[string]*When{}
+		}
+		e.whens[rule.When] = w
+	}
+
+	// 求值只读文档，不能按 create_missing 或 add 的语义创建字段
+	nav := *e.navigatorFor(rule)
+	nav.CreateMissing = false
+	ok, err := w.root.eval(&whenContext{root: root, nav: &nav})
+	if err != nil {
+		return false, fmt.Errorf("when: %w", err)
+	}
+	return ok, nil
+}
+
+type whenAnd struct{ left, right whenExpr }
+
+func (x whenAnd) eval(c *whenContext) (bool, error) {
+	ok, err := x.left.eval(c)
+	if err != nil || !ok {
+		return false, err
+	}
+	return x.right.eval(c)
+}
+
+type whenOr struct{ left, right whenExpr }
+
+func (x whenOr) eval(c *whenContext) (bool, error) {
+	ok, err := x.left.eval(c)
+	if err != nil || ok {
+		return ok, err
+	}
+	return x.right.eval(c)
+}
+
+type whenNot struct{ expr whenExpr }
+
+func (x whenNot) eval(c *whenContext) (bool, error) {
+	ok, err := x.expr.eval(c)
+	return !ok, err
+}
+
+// whenTruthy 单独的操作数
+type whenTruthy struct{ operand whenOperand }
+
+func (x whenTruthy) eval(c *whenContext) (bool, error) {
+	nodes, err := x.operand.values(c)
+	if err != nil {
+		return false, err
+	}
+	for _, n := range nodes {
+		if truthy(n) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type whenCompare struct {
+	op          string
+	left, right whenOperand
+}
+
+func (x whenCompare) eval(c *whenContext) (bool, error) {
+	left, err := x.left.values(c)
+	if err != nil {
+		return false, err
+	}
+	right, err := x.right.values(c)
+	if err != nil {
+		return false, err
+	}
+
+	if x.op == "!=" {
+		for _, a := range left {
+			for _, b := range right {
+				if equalValues(a, b) {
+					return false, nil
+				}
+			}
+		}
+		return true, nil
+	}
+	for _, a := range left {
+		for _, b := range right {
+			if compareValues(a, b, x.op) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// whenOperand 路径或字面量
+type whenOperand struct {
+	path    *path.Path
+	literal *yaml.Node
+}
+
+// nullNode 不存在的路径按 null 参与比较
+var nullNode = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+
+// values 返回操作数的值节点，别名解析为锚点内容
+func (o whenOperand) values(c *whenContext) ([]*yaml.Node, error) {
+	if o.literal != nil {
+		return []*yaml.Node{o.literal}, nil
+	}
+	matches, err := c.nav.Find(c.root, o.path)
+	if errors.Is(err, path.ErrPathNotFound) || errors.Is(err, path.ErrNoMatch) {
+		return []*yaml.Node{nullNode}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return []*yaml.Node{nullNode}, nil
+	}
+	nodes := make([]*yaml.Node, len(matches))
+	for i, m := range matches {
+		nodes[i] = unwrap(m.Node)
+	}
+	return nodes, nil
+}
+
+// truthy 值不是 null、false、空串、0、空 mapping/列表
+func truthy(n *yaml.Node) bool {
+	switch n.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		return len(n.Content) > 0
+	case yaml.ScalarNode:
+		switch n.ShortTag() {
+		case "!!null":
+			return false
+		case "!!bool":
+			var b bool
+			return n.Decode(&b) == nil && b
+		case "!!int", "!!float":
+			f, ok := numberValue(n)
+			return !ok || f != 0
+		}
+		return n.Value != ""
+	}
+	return true
+}
+
+// numberValue 返回 int/float 标量的数值
+func numberValue(n *yaml.Node) (float64, bool) {
+	if n.Kind != yaml.ScalarNode {
+		return 0, false
+	}
+	switch n.ShortTag() {
+	case "!!int", "!!float":
+		var f float64
+		if err := n.Decode(&f); err != nil {
+			return 0, false
+		}
+		return f, true
+	}
+	return 0, false
+}
+
+// equalValues 两侧都是数字时按数值比较，都是 null 时相等，集合按结构比较，其余比较标量文本
+func equalValues(a, b *yaml.Node) bool {
+	if x, ok := numberValue(a); ok {
+		if y, ok := numberValue(b); ok {
+			return x == y
+		}
+	}
+	aNull, bNull := a.ShortTag() == "!!null", b.ShortTag() == "!!null"
+	if aNull || bNull {
+		return aNull && bNull
+	}
+	if a.Kind != yaml.ScalarNode || b.Kind != yaml.ScalarNode {
+		return sameNode(a, b)
+	}
+	return a.Value == b.Value
+}
+
+// compareValues 大小比较，有一侧为 null 或集合时为假
+func compareValues(a, b *yaml.Node, op string) bool {
+	if op == "==" {
+		return equalValues(a, b)
+	}
+	if a.Kind != yaml.ScalarNode || b.Kind != yaml.ScalarNode || a.ShortTag() == "!!null" || b.ShortTag() == "!!null" {
+		return false
+	}
+
+	var c int
+	x, xok := numberValue(a)
+	y, yok := numberValue(b)
+	if xok && yok {
+		c = cmp.Compare(x, y)
+	} else {
+		c = strings.Compare(a.Value, b.Value)
+	}
+	switch op {
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	default:
+		return c <= 0
+	}
+}
+
+// whenToken 词法单元，kind 为 op、string 或 word（路径、数字、关键字）
+type whenToken struct {
+	kind   string
+	text   string
+	offset int
+}
+
+// whenOps 运算符，长的在前
+var whenOps = []string{"==", "!=", ">=", "<=", "&&", "||", ">", "<", "!", "(", ")"}
+
+// tokenizeWhen 切分表达式；路径中方括号与双引号内的内容（如 [name=app]、"app.kubernetes.io/name"）不切分
+func tokenizeWhen(s string) ([]whenToken, error) {
+	var tokens []whenToken
+	for i := 0; i < len(s); {
+		ch := s[i]
+		switch {
+		case unicode.IsSpace(rune(ch)):
+			i++
+			continue
+		case ch == '"' || ch == '\'':
+			end := strings.IndexByte(s[i+1:], ch)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, whenToken{kind: "string", text: s[i+1 : i+1+end], offset: i})
+			i += end + 2
+			continue
+		}
+
+		if op := matchOp(s[i:]); op != "" {
+			tokens = append(tokens, whenToken{kind: "op", text: op, offset: i})
+			i += len(op)
+			continue
+		}
+
+		start, depth, quoted := i, 0, false
+		for ; i < len(s); i++ {
+			ch := s[i]
+			if quoted {
+				quoted = ch != '"'
+				continue
+			}
+			if ch == '"' {
+				quoted = true
+				continue
+			}
+			if ch == '[' {
+				depth++
+			}
+			if ch == ']' && depth > 0 {
+				depth--
+			}
+			if depth == 0 && (unicode.IsSpace(rune(ch)) || matchOp(s[i:]) != "") {
+				break
+			}
+		}
+		if quoted || depth > 0 {
+			return nil, fmt.Errorf("unterminated path at offset %d", start)
+		}
+		tokens = append(tokens, whenToken{kind: "word", text: s[start:i], offset: start})
+	}
+	return tokens, nil
+}
+
+// matchOp 返回 s 开头的运算符
+func matchOp(s string) string {
+	for _, op := range whenOps {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// whenParser 递归下降解析：or := and ('||' and)*；and := unary ('&&' unary)*；
+// unary := '!' unary | '(' or ')' | operand (cmp operand)?
+type whenParser struct {
+	tokens []whenToken
+	pos    int
+}
+
+func (p *whenParser) peek(text string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == "op" && p.tokens[p.pos].text == text
+}
+
+func (p *whenParser) parseOr() (whenExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("||") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = whenOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *whenParser) parseAnd() (whenExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("&&") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = whenAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *whenParser) parseUnary() (whenExpr, error) {
+	switch {
+	case p.peek("!"):
+		p.pos++
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return whenNot{expr}, nil
+	case p.peek("("):
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(")") {
+			return nil, fmt.Errorf("missing ) at %s", p.where())
+		}
+		p.pos++
+		return expr, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", ">=", "<=", ">", "<"} {
+		if p.peek(op) {
+			p.pos++
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return whenCompare{op: op, left: left, right: right}, nil
+		}
+	}
+	return whenTruthy{left}, nil
+}
+
+func (p *whenParser) parseOperand() (whenOperand, error) {
+	if p.pos >= len(p.tokens) {
+		return whenOperand{}, fmt.Errorf("expected operand at end of expression")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	switch {
+	case t.kind == "string":
+		return whenOperand{literal: &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: t.text}}, nil
+	case t.kind == "op":
+		return whenOperand{}, fmt.Errorf("unexpected %q at offset %d", t.text, t.offset)
+	}
+
+	switch t.text {
+	case "true", "false":
+		return whenOperand{literal: &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: t.text}}, nil
+	case "null":
+		return whenOperand{literal: nullNode}, nil
+	}
+	if _, err := strconv.ParseFloat(t.text, 64); err == nil {
+		tag := "!!float"
+		if _, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			tag = "!!int"
+		}
+		return whenOperand{literal: &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: t.text}}, nil
+	}
+
+	p2, err := path.Parse(t.text)
+	if err != nil {
+		return whenOperand{}, fmt.Errorf("path %q: %w", t.text, err)
+	}
+	return whenOperand{path: p2}, nil
+}
+
+// where 返回当前位置的描述
+func (p *whenParser) where() string {
+	if p.pos >= len(p.tokens) {
+		return "end of expression"
+	}
+	return fmt.Sprintf("offset %d", p.tokens[p.pos].offset)
+}
@@ -0,0 +1,79 @@
+package path
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// ErrPathNotFound 路径中的字段、下标或位置不存在
+	ErrPathNotFound = errors.New("path not found")
+	// ErrNoMatch 条件选择器没有匹配任何元素
+	ErrNoMatch = errors.New("no match")
+	// ErrInvalidPath 路径语法错误
+	ErrInvalidPath = errors.New("invalid path")
+	// ErrTimeout 正则匹配超时或规则的时间预算已用完
+	ErrTimeout = errors.New("regex timeout")
+)
+
+// PathError 带位置的解析/查找错误，Err 为 ErrPathNotFound、ErrNoMatch、ErrInvalidPath 或 ErrTimeout
+type PathError struct {
+	Path    string // 出错位置的具体路径，如 spec.containers[0]
+	Segment int    // 出错的路径片段下标
+	Msg     string
+	Err     error
+}
+
+func (e *PathError) Error() string {
+	if e.Path == "" {
+		return e.Msg
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Msg)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// ErrTypeMismatch 节点类型与路径要求不符，如对标量做字段访问
+type ErrTypeMismatch struct {
+	Expected string
+	Got      string
+	Path     string
+}
+
+func (e *ErrTypeMismatch) Error() string {
+	msg := fmt.Sprintf("expected %s node, got %s", e.Expected, e.Got)
+	if e.Path == "" {
+		return msg
+	}
+	return fmt.Sprintf("%s: %s", e.Path, msg)
+}
+
+// KindName 返回节点类型的可读名称
+func KindName(kind yaml.Kind) string {
+	switch kind {
+	case yaml.DocumentNode:
+		return "document"
+	case yaml.SequenceNode:
+		return "sequence"
+	case yaml.MappingNode:
+		return "mapping"
+	case yaml.ScalarNode:
+		return "scalar"
+	case yaml.AliasNode:
+		return "alias"
+	default:
+		return fmt.Sprintf("kind(%d)", kind)
+	}
+}
+
+func notFound(at Match, segmentIdx int, format string, args ...interface{}) error {
+	return &PathError{Path: at.Path, Segment: segmentIdx, Msg: fmt.Sprintf(format, args...), Err: ErrPathNotFound}
+}
+
+func typeMismatch(at Match, expected string, got *yaml.Node) error {
+	return &ErrTypeMismatch{Expected: expected, Got: KindName(got.Kind), Path: at.Path}
+}
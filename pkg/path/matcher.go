@@ -0,0 +1,211 @@
+package path
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dlclark/regexp2"
+	"gopkg.in/yaml.v3"
+)
+
+// matchCondition 检查节点是否匹配条件，只有正则匹配超时时返回错误
+// 元素没有条件中的字段时只满足 != 条件
+func (n *Navigator) matchCondition(node *yaml.Node, cond *Condition) (bool, error) {
+	node = resolveAlias(node)
+	if node.Kind != yaml.MappingNode {
+		return false, nil
+	}
+
+	// 查找字段（包括经由合并键合并进来的字段）
+	owner, i, _ := lookupField(node, cond.Field)
+	if owner == nil {
+		return cond.Op == OpNotEqual || cond.Op == OpNotRegex, nil
+	}
+
+	valueNode := resolveAlias(owner.Content[i+1])
+	value := valueNode.Value
+	if n.Trim {
+		value = strings.TrimSpace(value)
+	}
+	scalar := valueNode.Kind == yaml.ScalarNode
+
+	switch cond.Op {
+	case OpEqual:
+		return scalar && n.equal(value, valueNode.ShortTag(), cond), nil
+	case OpNotEqual:
+		return !scalar || !n.equal(value, valueNode.ShortTag(), cond), nil
+	case OpRegex, OpNotRegex:
+		matched, err := n.matchRegex(value, cond.Value.(string))
+		return matched != (cond.Op == OpNotRegex), err
+	case OpGreater, OpGreaterEqual, OpLess, OpLessEqual:
+		if !scalar {
+			return false, nil
+		}
+		c, ok := n.compare(value, valueNode.ShortTag(), cond)
+		if !ok {
+			return false, nil
+		}
+		switch cond.Op {
+		case OpGreater:
+			return c > 0, nil
+		case OpGreaterEqual:
+			return c >= 0, nil
+		case OpLess:
+			return c < 0, nil
+		default:
+			return c <= 0, nil
+		}
+	case OpContains:
+		return n.contains(valueNode, cond), nil
+	}
+
+	return false, nil
+}
+
+// matchRegex 正则匹配，pattern 已在解析时校验
+func (n *Navigator) matchRegex(value, pattern string) (bool, error) {
+	var opts regexp2.RegexOptions
+	if n.CaseInsensitive {
+		opts = regexp2.IgnoreCase
+	}
+	re, err := regexp2.Compile(pattern, opts)
+	if err != nil {
+		return false, nil
+	}
+	if re.MatchTimeout, err = RegexTimeout(n.MatchTimeout, n.Deadline); err != nil {
+		return false, err
+	}
+	matched, err := re.MatchString(value)
+	if err != nil {
+		return false, fmt.Errorf("pattern %q: %v", pattern, err)
+	}
+	return matched, nil
+}
+
+// compare 比较字段值与条件值，返回 -1/0/1
+// 字段为 int/float 时按数值比较，条件值不是数字则不可比较；其他字段按字符串字典序比较。
+// 条件值带引号时总是按字符串比较
+func (n *Navigator) compare(value, tag string, cond *Condition) (int, bool) {
+	want := cond.Value.(string)
+	if n.Trim {
+		want = strings.TrimSpace(want)
+	}
+
+	if !cond.Quoted && (tag == "!!int" || tag == "!!float") {
+		a, ok1 := parseNumber(value)
+		b, ok2 := parseNumber(want)
+		if !ok1 || !ok2 || math.IsNaN(a) || math.IsNaN(b) {
+			return 0, false
+		}
+		return cmp.Compare(a, b), true
+	}
+
+	if n.CaseInsensitive {
+		value, want = strings.ToLower(value), strings.ToLower(want)
+	}
+	return strings.Compare(value, want), true
+}
+
+// contains 字段为标量时判断是否包含子串，为序列时判断是否有元素等于条件值（同 = 的比较方式）
+func (n *Navigator) contains(node *yaml.Node, cond *Condition) bool {
+	want := cond.Value.(string)
+	switch node.Kind {
+	case yaml.ScalarNode:
+		value := node.Value
+		if n.CaseInsensitive {
+			value, want = strings.ToLower(value), strings.ToLower(want)
+		}
+		return strings.Contains(value, want)
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			item = resolveAlias(item)
+			value := item.Value
+			if n.Trim {
+				value = strings.TrimSpace(value)
+			}
+			if item.Kind == yaml.ScalarNode && n.equal(value, item.ShortTag(), cond) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RegexTimeout 返回本次正则匹配的超时：matchTimeout 与距 deadline 剩余时间中的较小者，都未设置时不限
+// 已过 deadline 时返回 ErrTimeout
+func RegexTimeout(matchTimeout time.Duration, deadline time.Time) (time.Duration, error) {
+	timeout := regexp2.DefaultMatchTimeout
+	if matchTimeout > 0 {
+		timeout = matchTimeout
+	}
+	if !deadline.IsZero() {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return 0, fmt.Errorf("%w: rule time budget exhausted", ErrTimeout)
+		}
+		timeout = min(timeout, remaining)
+	}
+	return timeout, nil
+}
+
+// equal 精确匹配
+// 先按字符串比较；不相等时按节点的 YAML 标签做类型比较，
+// 使 replicas=3 匹配 0x3、enabled=true 匹配 True、weight=1 匹配 1.0。
+// 条件值带引号（[tag="1.0"]）时只做字符串比较
+func (n *Navigator) equal(value, tag string, cond *Condition) bool {
+	want := cond.Value.(string)
+	if n.Trim {
+		want = strings.TrimSpace(want)
+	}
+
+	if value == want || (n.CaseInsensitive && strings.EqualFold(value, want)) {
+		return true
+	}
+	if cond.Quoted {
+		return false
+	}
+
+	switch tag {
+	case "!!int", "!!float":
+		a, ok1 := parseNumber(value)
+		b, ok2 := parseNumber(want)
+		return ok1 && ok2 && a == b
+	case "!!bool":
+		a, ok1 := parseBool(value)
+		b, ok2 := parseBool(want)
+		return ok1 && ok2 && a == b
+	}
+	return false
+}
+
+// parseNumber 按 YAML 1.2 core schema 解析整数/浮点数
+func parseNumber(s string) (float64, bool) {
+	if i, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return float64(i), true
+	}
+
+	switch strings.ToLower(s) {
+	case ".inf", "+.inf":
+		return math.Inf(1), true
+	case "-.inf":
+		return math.Inf(-1), true
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil
+}
+
+// parseBool 按 YAML 1.2 core schema 解析布尔值
+func parseBool(s string) (bool, bool) {
+	switch s {
+	case "true", "True", "TRUE":
+		return true, true
+	case "false", "False", "FALSE":
+		return false, true
+	}
+	return false, false
+}
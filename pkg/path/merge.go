@@ -0,0 +1,96 @@
+package path
+
+import (
+	"fmt"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lookupField 在 mapping 中查找字段，本地没有时按 YAML 合并键（<<）在合并来源中查找
+// 合并来源的优先级：本地键 > 第一个来源 > 后续来源，来源自身的合并键递归处理。
+// 返回字段所在的 mapping（可能是锚点）与键在其 Content 中的下标，merged 表示来自合并来源
+func lookupField(mapping *yaml.Node, field string) (owner *yaml.Node, idx int, merged bool) {
+	return lookupKey(mapping, func(key string) bool { return key == field })
+}
+
+// lookupKey 同 lookupField，键名由 match 判断
+func lookupKey(mapping *yaml.Node, match func(key string) bool) (owner *yaml.Node, idx int, merged bool) {
+	for i := 0; i < len(mapping.Content); i += 2 {
+		if match(mapping.Content[i].Value) {
+			return mapping, i, false
+		}
+	}
+
+	for _, src := range mergeSources(mapping) {
+		if owner, idx, _ := lookupKey(src, match); owner != nil {
+			return owner, idx, true
+		}
+	}
+	return nil, -1, false
+}
+
+// mergeSources 返回 mapping 中合并键引用的 mapping，按优先级排列
+// 支持 <<: *a、<<: [*a, *b] 以及内联的 <<: {k: v}
+func mergeSources(mapping *yaml.Node) []*yaml.Node {
+	var sources []*yaml.Node
+	for i := 0; i < len(mapping.Content); i += 2 {
+		if mapping.Content[i].ShortTag() != "!!merge" {
+			continue
+		}
+
+		value := resolveAlias(mapping.Content[i+1])
+		switch value.Kind {
+		case yaml.MappingNode:
+			sources = append(sources, value)
+		case yaml.SequenceNode:
+			for _, item := range value.Content {
+				if item = resolveAlias(item); item.Kind == yaml.MappingNode {
+					sources = append(sources, item)
+				}
+			}
+		}
+	}
+	return sources
+}
+
+// resolveAlias 返回别名指向的节点，非别名原样返回
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// materialize 返回合并来源中第 idx 个键值对在 mapping 本地的副本，Materialize 时追加到 mapping 末尾
+// 副本去掉锚点定义，避免与来源中的锚点重名
+func (n *finder) materialize(mapping, owner *yaml.Node, idx int) *expansion {
+	return n.expansion(mapping, owner.Content[idx+1], func() *expansion {
+		key := CopyNode(owner.Content[idx])
+		value := CopyNode(owner.Content[idx+1])
+		stripAnchors(value)
+		x := &expansion{node: value, key: key}
+		x.apply = func() { mapping.Content = append(mapping.Content, key, value) }
+		return x
+	})
+}
+
+// mergedAt 返回合并字段的本地副本 x 在 mapping 中的位置，下标在 Materialize 时确定
+func mergedAt(mapping *yaml.Node, x *expansion, at Match, field string, folded bool) Match {
+	m := Match{Parent: mapping, Key: x.key, Index: len(mapping.Content) + 1, Path: FieldPath(at.Path, x.key.Value),
+		Folded: at.Folded, pending: append(slices.Clip(at.pending), x)}
+	if folded {
+		m.Folded = append(slices.Clip(m.Folded), fmt.Sprintf("'%s' for '%s'", x.key.Value, field))
+	}
+	return m
+}
+
+// stripAnchors 清除子树中所有锚点定义
+func stripAnchors(node *yaml.Node) {
+	if node.Kind != yaml.AliasNode {
+		node.Anchor = ""
+	}
+	for _, child := range node.Content {
+		stripAnchors(child)
+	}
+}
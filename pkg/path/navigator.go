@@ -1,24 +1,138 @@
 package path
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/dlclark/regexp2"
 	"gopkg.in/yaml.v3"
 )
 
 // Navigator 负责在 YAML 树中导航和查找节点
-type Navigator struct{}
+type Navigator struct {
+	// ExpandAliases 为 true 时，匹配路径上经过的别名（及经由合并键继承的字段）在锚点的独立副本上继续查找，
+	// 返回的节点属于副本；查找本身不修改文档，调用 Match.Materialize 后副本才替换文档中的别名，
+	// 此后修改不会影响锚点和其他别名
+	ExpandAliases bool
+
+	// CaseInsensitive 条件匹配忽略大小写（精确匹配与正则匹配）
+	CaseInsensitive bool
+	// Trim 条件匹配前去除字段值首尾空白
+	Trim bool
+
+	// FoldKeys 为 true 时，路径中的字段在精确匹配失败后忽略大小写再查找一次，
+	// 用于 apiversion:、Metadata: 这类键名大小写不规范的文档；命中的键记录在 Match.Folded
+	FoldKeys bool
+
+	// CreateMissing 为 true 时，剩余路径全为字段访问且字段不存在时自动创建
+	// （中间层为空 mapping，末端为 null），用于对部分缺失的元素设置值
+	CreateMissing bool
+
+	// Where 路径中 [?] 选中元素的条件，nil 时 [?] 同 [*]
+	Where *Where
+
+	// MatchTimeout 单次正则条件匹配的超时，0 表示不限
+	MatchTimeout time.Duration
+	// Deadline 非零时，超过该时刻后正则条件匹配立即失败，用于限制单条规则的总耗时
+	Deadline time.Time
+}
+
+// finder 单次查找的状态
+type finder struct {
+	*Navigator
+	misses []string                      // 通配/条件展开后未能解析剩余路径的元素
+	copies map[[2]*yaml.Node]*expansion // 本次查找中建立的副本，同一别名或合并字段多次经过时复用
+}
+
+// Match 表示一个匹配结果及其在父节点中的位置
+type Match struct {
+	Node   *yaml.Node // 匹配的节点（经由别名时为锚点节点）
+	Parent *yaml.Node // 父节点，MappingNode 或 SequenceNode
+	Key    *yaml.Node // 父节点为 MappingNode 时的键节点
+	Index  int        // Node 在 Parent.Content 中的下标
+	Path   string     // 具体路径，如 spec.containers[0].image
+
+	// Created 节点由 CreateMissing 新建（值为 null），而不是文档中原有的
+	Created bool
+	// Folded 路径上由 FoldKeys 忽略大小写命中的字段，每项为 "'文档中的键名' for '路径中的字段名'"
+	Folded []string
+
+	pending []*expansion // ExpandAliases 下路径上尚未写回文档的副本，见 Materialize
+}
+
+// expansion ExpandAliases 下为别名或合并字段建立的副本，apply 把副本接入文档
+type expansion struct {
+	node  *yaml.Node // 副本：别名指向内容的副本，或合并字段的值的副本
+	key   *yaml.Node // 合并字段的键的副本，别名时为 nil
+	apply func()
+	done  bool
+}
+
+// Materialize 把匹配路径上经过的别名替换为查找时建立的副本、把经由合并键继承的字段复制到本地（ExpandAliases），
+// 之后修改 Node 不影响锚点、合并来源和其他别名；多个匹配共用的副本只接入一次。
+// Find 不修改文档，只有要修改匹配节点的调用方需要调用；没有经过别名或合并键时什么也不做
+func (m *Match) Materialize() {
+	if len(m.pending) == 0 {
+		return
+	}
+	for _, x := range m.pending {
+		if !x.done {
+			x.apply()
+			x.done = true
+		}
+	}
+	m.pending = nil
+	// 合并字段追加到 mapping 末尾后才有确定的下标
+	if m.Parent != nil {
+		if i := slices.Index(m.Parent.Content, m.Node); i >= 0 {
+			m.Index = i
+		}
+	}
+}
 
 // Find 根据路径查找所有匹配的节点
-// 返回匹配的节点列表（因为可能有通配符）
-func (n *Navigator) Find(root *yaml.Node, path *Path) ([]*yaml.Node, error) {
-	return n.findRecursive(root, path.Segments, 0)
+// 返回匹配列表（因为可能有通配符），同一节点经由别名多次命中时只返回一次
+func (n *Navigator) Find(root *yaml.Node, path *Path) ([]*Match, error) {
+	matches, _, err := n.FindWithMisses(root, path)
+	return matches, err
 }
-func (n *Navigator) findRecursive(node *yaml.Node, segments []*Segment, segmentIdx int) ([]*yaml.Node, error) {
+
+// FindWithMisses 同 Find，另外返回被跳过的元素
+// 通配符、位置或条件选中了某个元素，但其剩余路径无法解析时，该元素被跳过，
+// 以 "具体路径: 原因" 的形式记录
+func (n *Navigator) FindWithMisses(root *yaml.Node, path *Path) ([]*Match, []string, error) {
+	f := &finder{Navigator: n}
+	matches, err := f.findRecursive(root, Match{}, path.Segments, 0)
+	if err != nil {
+		return nil, f.misses, err
+	}
+	return dedup(matches), f.misses, nil
+}
+
+// dedup 按节点身份去重，保持原有顺序
+func dedup(matches []*Match) []*Match {
+	seen := make(map[*yaml.Node]bool, len(matches))
+	result := matches[:0]
+	for _, m := range matches {
+		if seen[m.Node] {
+			continue
+		}
+		seen[m.Node] = true
+		result = append(result, m)
+	}
+	return result
+}
+
+// findRecursive 从 node 继续匹配 segments[segmentIdx:]，at 记录 node 所在位置
+func (n *finder) findRecursive(node *yaml.Node, at Match, segments []*Segment, segmentIdx int) ([]*Match, error) {
 	// 到达路径末尾
 	if segmentIdx >= len(segments) {
-		return []*yaml.Node{node}, nil
+		at.Node = node
+		return []*Match{&at}, nil
 	}
 
 	segment := segments[segmentIdx]
@@ -26,106 +140,384 @@ func (n *Navigator) findRecursive(node *yaml.Node, segments []*Segment, segmentI
 	// 处理文档节点和别名节点
 	if node.Kind == yaml.DocumentNode {
 		if len(node.Content) == 0 {
-			return nil, fmt.Errorf("empty document")
+			return nil, notFound(at, segmentIdx, "empty document")
 		}
-		return n.findRecursive(node.Content[0], segments, segmentIdx)
+		return n.findRecursive(node.Content[0], at, segments, segmentIdx)
 	}
 
 	if node.Kind == yaml.AliasNode {
-		return n.findRecursive(node.Alias, segments, segmentIdx)
+		if n.ExpandAliases {
+			return n.findExpanded(node, at, segments, segmentIdx)
+		}
+		return n.findRecursive(node.Alias, at, segments, segmentIdx)
+	}
+
+	if segment.Recursive {
+		return n.findDeep(node, at, segments, segmentIdx)
 	}
 
 	switch segment.Type {
 	case SegmentTypeField:
-		return n.findField(node, segment, segments, segmentIdx)
+		return n.findField(node, at, segment, segments, segmentIdx)
 	case SegmentTypeArray:
-		return n.findArray(node, segment, segments, segmentIdx)
+		return n.findArray(node, at, segment, segments, segmentIdx)
+	case SegmentTypeWildcard:
+		return n.findEachKey(node, at, segments, segmentIdx)
 	default:
 		return nil, fmt.Errorf("unknown segment type")
 	}
 }
 
+// findDeep 处理递归片段 ..field：在 node 及其所有后代 mapping 中查找该字段，
+// 每处命中都按普通片段继续匹配剩余路径；剩余路径无法解析的命中记为 miss 并跳过，
+// 任何深度都没有该字段时报错
+func (n *finder) findDeep(node *yaml.Node, at Match, segments []*Segment, segmentIdx int) ([]*Match, error) {
+	plain := *segments[segmentIdx]
+	plain.Recursive = false
+	local := slices.Clone(segments)
+	local[segmentIdx] = &plain
+
+	var results []*Match
+	found := false
+	// 不进入别名：锚点在定义处已被遍历，经由合并键继承的字段由 lookupField 处理
+	var walk func(node *yaml.Node, at Match) error
+	walk = func(node *yaml.Node, at Match) error {
+		switch node.Kind {
+		case yaml.MappingNode:
+			if owner, _, _, _ := n.lookup(node, plain.Field); owner != nil {
+				found = true
+				matched, err := n.findRecursive(node, at, local, segmentIdx)
+				if errors.Is(err, ErrTimeout) {
+					return err
+				}
+				if err != nil {
+					n.misses = append(n.misses, fmt.Sprintf("%s: %v", cmp.Or(at.Path, "<root>"), err))
+				}
+				results = append(results, matched...)
+			}
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if err := walk(node.Content[i+1], fieldAt(node, i, at)); err != nil {
+					return err
+				}
+			}
+		case yaml.SequenceNode:
+			for i, child := range node.Content {
+				if err := walk(child, elemAt(node, i, at)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(node, at); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, notFound(at, segmentIdx, "field '%s' not found at any depth", plain.Field)
+	}
+	return results, nil
+}
+
+// findExpanded 在锚点副本上继续查找，不修改文档：副本记在匹配的 pending 中，
+// Materialize 时才在父节点中替换别名，未命中或只读的查找保持别名不变
+func (n *finder) findExpanded(alias *yaml.Node, at Match, segments []*Segment, segmentIdx int) ([]*Match, error) {
+	parent := at.Parent
+	x := n.expansion(alias, nil, func() *expansion {
+		// 副本去掉锚点定义，避免与锚点内嵌套的锚点重名，使后面的别名改指副本
+		cp := CopyNode(alias.Alias)
+		stripAnchors(cp)
+		x := &expansion{node: cp}
+		// 父节点可能是尚未接入的副本，按节点查找位置，接入顺序不影响结果
+		x.apply = func() {
+			if parent == nil {
+				return
+			}
+			if i := slices.Index(parent.Content, alias); i >= 0 {
+				parent.Content[i] = cp
+			}
+		}
+		return x
+	})
+	at.pending = append(slices.Clip(at.pending), x)
+	return n.findRecursive(x.node, at, segments, segmentIdx)
+}
+
+// expansion 返回本次查找中 (a, b) 对应的副本，没有时由 create 建立
+func (n *finder) expansion(a, b *yaml.Node, create func() *expansion) *expansion {
+	key := [2]*yaml.Node{a, b}
+	x, ok := n.copies[key]
+	if !ok {
+		if n.copies == nil {
+			n.copies = map[[2]*yaml.Node]*expansion{}
+		}
+		x = create()
+		n.copies[key] = x
+	}
+	return x
+}
+
+// CopyNode 深拷贝节点，别名引用保持指向原锚点
+func CopyNode(node *yaml.Node) *yaml.Node {
+	cp := *node
+	if node.Content != nil {
+		cp.Content = make([]*yaml.Node, len(node.Content))
+		for i, child := range node.Content {
+			cp.Content[i] = CopyNode(child)
+		}
+	}
+	return &cp
+}
+
+// FieldPath 返回 parent 下字段 field 的具体路径，键名含 . [ ] 或为 * 时加引号
+func FieldPath(parent, field string) string {
+	if strings.ContainsAny(field, ".[]") || field == "*" {
+		field = `"` + field + `"`
+	}
+	if parent == "" {
+		return field
+	}
+	return parent + "." + field
+}
+
+// ElemPath 返回 parent 下第 i 个元素的具体路径
+func ElemPath(parent string, i int) string {
+	return parent + "[" + strconv.Itoa(i) + "]"
+}
+
+// fieldAt 返回 mapping 中第 i 对键值的位置
+func fieldAt(mapping *yaml.Node, i int, at Match) Match {
+	key := mapping.Content[i]
+	return Match{Parent: mapping, Key: key, Index: i + 1, Path: FieldPath(at.Path, key.Value), Folded: at.Folded, pending: at.pending}
+}
+
+// elemAt 返回 sequence 中第 i 个元素的位置
+func elemAt(seq *yaml.Node, i int, at Match) Match {
+	return Match{Parent: seq, Index: i, Path: ElemPath(at.Path, i), Folded: at.Folded, pending: at.pending}
+}
+
+// lookup 在 mapping 中查找字段，FoldKeys 下精确查找失败时再忽略大小写查找，folded 表示由后者命中
+func (n *finder) lookup(mapping *yaml.Node, field string) (owner *yaml.Node, idx int, merged, folded bool) {
+	owner, idx, merged = lookupField(mapping, field)
+	if owner != nil || !n.FoldKeys {
+		return owner, idx, merged, false
+	}
+	owner, idx, merged = lookupKey(mapping, func(key string) bool { return strings.EqualFold(key, field) })
+	return owner, idx, merged, owner != nil
+}
+
+// foldedAt 在 fieldAt 的基础上记录忽略大小写命中的字段
+func foldedAt(mapping *yaml.Node, i int, at Match, field string, folded bool) Match {
+	m := fieldAt(mapping, i, at)
+	if folded {
+		m.Folded = append(slices.Clip(m.Folded), fmt.Sprintf("'%s' for '%s'", mapping.Content[i].Value, field))
+	}
+	return m
+}
+
 // findField 查找字段
-func (n *Navigator) findField(node *yaml.Node, segment *Segment, segments []*Segment, segmentIdx int) ([]*yaml.Node, error) {
+func (n *finder) findField(node *yaml.Node, at Match, segment *Segment, segments []*Segment, segmentIdx int) ([]*Match, error) {
+	// 值为 null 的字段（如只写了 annotations:）在需要创建子字段时改为空 mapping
+	if n.CreateMissing && isNull(node) && onlyFields(segments[segmentIdx:]) {
+		*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Anchor: node.Anchor, Line: node.Line, Column: node.Column,
+			HeadComment: node.HeadComment, LineComment: node.LineComment, FootComment: node.FootComment}
+	}
 	if node.Kind != yaml.MappingNode {
-		return nil, fmt.Errorf("expected mapping node, got %v", node.Kind)
+		return nil, typeMismatch(at, "mapping", node)
 	}
 
 	// YAML MappingNode 的 Content 是 [key1, value1, key2, value2, ...]
-	for i := 0; i < len(node.Content); i += 2 {
-		keyNode := node.Content[i]
-		valueNode := node.Content[i+1]
+	if owner, i, merged, folded := n.lookup(node, segment.Field); owner != nil {
+		if merged && n.ExpandAliases {
+			x := n.materialize(node, owner, i)
+			return n.findRecursive(x.node, mergedAt(node, x, at, segment.Field, folded), segments, segmentIdx+1)
+		}
+		return n.findRecursive(owner.Content[i+1], foldedAt(owner, i, at, segment.Field, folded), segments, segmentIdx+1)
+	}
 
-		if keyNode.Value == segment.Field {
-			return n.findRecursive(valueNode, segments, segmentIdx+1)
+	if n.CreateMissing && onlyFields(segments[segmentIdx:]) {
+		results, err := n.findRecursive(createField(node, segment.Field, segmentIdx+1 < len(segments)),
+			fieldAt(node, len(node.Content)-2, at), segments, segmentIdx+1)
+		for _, m := range results {
+			m.Created = true
 		}
+		return results, err
 	}
 
-	return nil, fmt.Errorf("field '%s' not found", segment.Field)
+	return nil, notFound(at, segmentIdx, "field '%s' not found", segment.Field)
 }
 
-// findArray 查找数组元素
-func (n *Navigator) findArray(node *yaml.Node, segment *Segment, segments []*Segment, segmentIdx int) ([]*yaml.Node, error) {
-	// 先找到数组字段
+// findEachKey 处理 * 片段：在 mapping 的每个键的值上继续匹配剩余路径，同 [*]，
+// 剩余路径无法解析的键记为 miss 并跳过，空 mapping 不算错误；合并键（<<）本身及其继承的字段不在其中
+func (n *finder) findEachKey(node *yaml.Node, at Match, segments []*Segment, segmentIdx int) ([]*Match, error) {
 	if node.Kind != yaml.MappingNode {
-		return nil, fmt.Errorf("expected mapping node for array field")
+		return nil, typeMismatch(at, "mapping", node)
 	}
 
-	var arrayNode *yaml.Node
-	for i := 0; i < len(node.Content); i += 2 {
-		keyNode := node.Content[i]
-		valueNode := node.Content[i+1]
+	var results []*Match
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].ShortTag() == "!!merge" {
+			continue
+		}
+		keyAt := fieldAt(node, i, at)
+		matched, err := n.findRecursive(node.Content[i+1], keyAt, segments, segmentIdx+1)
+		if errors.Is(err, ErrTimeout) {
+			return nil, err
+		}
+		if err != nil {
+			n.misses = append(n.misses, fmt.Sprintf("%s: %v", keyAt.Path, err))
+			continue
+		}
+		results = append(results, matched...)
+	}
+	return results, nil
+}
 
-		if keyNode.Value == segment.Field {
-			arrayNode = valueNode
-			break
+// onlyFields 判断剩余路径是否全为字段访问
+func onlyFields(segments []*Segment) bool {
+	for _, seg := range segments {
+		if seg.Type != SegmentTypeField {
+			return false
 		}
 	}
+	return true
+}
+
+// isNull 判断是否为 null 标量
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null"
+}
 
-	if arrayNode == nil {
-		return nil, fmt.Errorf("array field '%s' not found", segment.Field)
+// createField 在 mapping 末尾追加字段，返回新建的值节点
+// 后面还有路径时值为空 mapping，否则为 null
+func createField(mapping *yaml.Node, field string, intermediate bool) *yaml.Node {
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	if intermediate {
+		value = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: field}
+	mapping.Content = append(mapping.Content, key, value)
+	return value
+}
+
+// findArray 查找数组元素
+func (n *finder) findArray(node *yaml.Node, at Match, segment *Segment, segments []*Segment, segmentIdx int) (results []*Match, err error) {
+	// 先找到数组字段
+	if node.Kind != yaml.MappingNode {
+		return nil, typeMismatch(at, "mapping", node)
+	}
+
+	owner, i, merged, folded := n.lookup(node, segment.Field)
+	if owner == nil {
+		return nil, notFound(at, segmentIdx, "array field '%s' not found", segment.Field)
+	}
+	arrayNode := owner.Content[i+1]
+	arrayAt := foldedAt(owner, i, at, segment.Field, folded)
+	if merged && n.ExpandAliases {
+		// 同 findField，在本地副本上继续查找
+		x := n.materialize(node, owner, i)
+		arrayNode, arrayAt = x.node, mergedAt(node, x, at, segment.Field, folded)
 	}
 
 	if arrayNode.Kind != yaml.SequenceNode {
-		return nil, fmt.Errorf("field '%s' is not an array", segment.Field)
+		return nil, typeMismatch(arrayAt, "sequence", arrayNode)
 	}
 
-	// 根据选择器类型匹配元素
-	switch segment.Selector.Type {
-	case SelectorTypeWildcard:
-		// 通配符：匹配所有元素
-		var results []*yaml.Node
-		for _, elem := range arrayNode.Content {
-			matched, err := n.findRecursive(elem, segments, segmentIdx+1)
+	// elem 在第 i 个元素上继续匹配剩余路径
+	elem := func(i int) ([]*Match, error) {
+		return n.findRecursive(arrayNode.Content[i], elemAt(arrayNode, i, arrayAt), segments, segmentIdx+1)
+	}
+
+	// each 在多个元素上继续匹配，无法解析的元素记为 miss 并跳过；正则超时时中止
+	each := func(indices []int) ([]*Match, error) {
+		var results []*Match
+		for _, i := range indices {
+			matched, err := elem(i)
+			if errors.Is(err, ErrTimeout) {
+				return nil, err
+			}
 			if err != nil {
-				continue // 某个元素不匹配，继续下一个
+				n.misses = append(n.misses, fmt.Sprintf("%s: %v", elemAt(arrayNode, i, arrayAt).Path, err))
+				continue
 			}
 			results = append(results, matched...)
 		}
 		return results, nil
+	}
+
+	// 根据选择器类型匹配元素
+	switch segment.Selector.Type {
+	case SelectorTypeWildcard:
+		// 通配符：匹配所有元素，某个元素不匹配时继续下一个
+		indices := make([]int, len(arrayNode.Content))
+		for i := range indices {
+			indices[i] = i
+		}
+		return each(indices)
 
 	case SelectorTypeIndex:
-		// 索引：匹配指定位置
-		idx := segment.Selector.Condition.Value.(int)
+		// 索引：匹配指定位置，负数从末尾倒数
+		idx := segment.Selector.Index
+		if idx < 0 {
+			idx += len(arrayNode.Content)
+		}
 		if idx < 0 || idx >= len(arrayNode.Content) {
-			return nil, fmt.Errorf("index %d out of range", idx)
+			return nil, notFound(arrayAt, segmentIdx, "index %d out of range", segment.Selector.Index)
 		}
-		return n.findRecursive(arrayNode.Content[idx], segments, segmentIdx+1)
+		return elem(idx)
 
-	case SelectorTypeCondition:
-		// 条件：匹配字段值
-		var results []*yaml.Node
-		for _, elem := range arrayNode.Content {
-			if n.matchCondition(elem, segment.Selector.Condition) {
-				matched, err := n.findRecursive(elem, segments, segmentIdx+1)
-				if err != nil {
-					continue
-				}
-				results = append(results, matched...)
+	case SelectorTypeSlice:
+		// 切片：同通配符，选中的元素不匹配时继续下一个，没有选中任何元素不算错误
+		return each(segment.Selector.Slice.Indices(len(arrayNode.Content)))
+
+	case SelectorTypePosition:
+		// 位置：first/last 取单个元素，even/odd 隔一个取一个
+		if len(arrayNode.Content) == 0 {
+			return nil, notFound(arrayAt, segmentIdx, "array field '%s' is empty", segment.Field)
+		}
+
+		switch segment.Selector.Position {
+		case PositionFirst:
+			return elem(0)
+		case PositionLast:
+			return elem(len(arrayNode.Content) - 1)
+		}
+
+		var indices []int
+		start := 0
+		if segment.Selector.Position == PositionOdd {
+			start = 1
+		}
+		for i := start; i < len(arrayNode.Content); i += 2 {
+			indices = append(indices, i)
+		}
+		return each(indices)
+
+	case SelectorTypeCondition, SelectorTypeWhere:
+		// 条件：匹配字段值；[?] 未设置 where 条件时同通配符
+		if segment.Selector.Type == SelectorTypeWhere && n.Where == nil {
+			indices := make([]int, len(arrayNode.Content))
+			for i := range indices {
+				indices[i] = i
 			}
+			return each(indices)
+		}
+		var indices []int
+		for i, e := range arrayNode.Content {
+			ok, err := n.matchElement(e, segment.Selector)
+			if err != nil {
+				return nil, &PathError{Path: elemAt(arrayNode, i, arrayAt).Path, Segment: segmentIdx, Msg: err.Error(), Err: ErrTimeout}
+			}
+			if ok {
+				indices = append(indices, i)
+			}
+		}
+		results, err := each(indices)
+		if err != nil {
+			return nil, err
 		}
 		if len(results) == 0 {
-			return nil, fmt.Errorf("no elements match condition")
+			return nil, &PathError{Path: arrayAt.Path, Segment: segmentIdx, Msg: "no elements match condition", Err: ErrNoMatch}
 		}
 		return results, nil
 
@@ -134,32 +526,37 @@ func (n *Navigator) findArray(node *yaml.Node, segment *Segment, segments []*Seg
 	}
 }
 
-// matchCondition 检查节点是否匹配条件
-func (n *Navigator) matchCondition(node *yaml.Node, cond *Condition) bool {
-	if node.Kind != yaml.MappingNode {
-		return false
+// matchElement 检查元素是否满足条件选择器或 where 条件
+func (n *Navigator) matchElement(node *yaml.Node, sel *Selector) (bool, error) {
+	if sel.Type == SelectorTypeWhere {
+		return n.matchWhere(node, n.Where)
 	}
+	return n.matchCondition(node, sel.Condition)
+}
 
-	// 查找字段
-	for i := 0; i < len(node.Content); i += 2 {
-		keyNode := node.Content[i]
-		valueNode := node.Content[i+1]
-
-		if keyNode.Value == cond.Field {
-			switch cond.Op {
-			case OpEqual:
-				return valueNode.Value == fmt.Sprint(cond.Value)
-			case OpRegex:
-				pattern := cond.Value.(string)
-				re, err := regexp2.Compile(pattern, 0)
-				if err != nil {
-					return false
-				}
-				matched, err := re.MatchString(valueNode.Value)
-				return err == nil && matched
+// CopyTree 深拷贝整棵树，树内的别名改为指向副本中对应的锚点
+func CopyTree(root *yaml.Node) *yaml.Node {
+	copies := map[*yaml.Node]*yaml.Node{}
+	var copyRec func(*yaml.Node) *yaml.Node
+	copyRec = func(node *yaml.Node) *yaml.Node {
+		cp := *node
+		copies[node] = &cp
+		if node.Content != nil {
+			cp.Content = make([]*yaml.Node, len(node.Content))
+			for i, child := range node.Content {
+				cp.Content[i] = copyRec(child)
 			}
 		}
+		return &cp
 	}
 
-	return false
+	cp := copyRec(root)
+	for _, c := range copies {
+		if c.Kind == yaml.AliasNode {
+			if target, ok := copies[c.Alias]; ok {
+				c.Alias = target
+			}
+		}
+	}
+	return cp
 }
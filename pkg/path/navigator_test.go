@@ -0,0 +1,157 @@
+package path
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ExpandAliases 下 Find 只在副本上查找，文档不变；Materialize 后副本才接入文档，锚点与其他别名不受影响
+func TestFindExpandedMaterialize(t *testing.T) {
+	const doc = `base: &b
+  image: nginx
+  ports: [80]
+  nested: &n {k: v}
+x:
+  <<: *b
+  name: x
+y: *b
+z: *b
+list: [*n, *n]
+`
+	tests := []struct {
+		path  string
+		value string // 匹配节点改成的值
+		want  string // Materialize 并修改后的文档
+	}{
+		{"y.image", "redis", `base: &b
+    image: nginx
+    ports: [80]
+    nested: &n {k: v}
+x:
+    !!merge <<: *b
+    name: x
+y:
+    image: redis
+    ports: [80]
+    nested: {k: v}
+z: *b
+list: [*n, *n]
+`},
+		{"x.image", "redis", `base: &b
+    image: nginx
+    ports: [80]
+    nested: &n {k: v}
+x:
+    !!merge <<: *b
+    name: x
+    image: redis
+y: *b
+z: *b
+list: [*n, *n]
+`},
+		{"x.ports[0]", "443", `base: &b
+    image: nginx
+    ports: [80]
+    nested: &n {k: v}
+x:
+    !!merge <<: *b
+    name: x
+    ports: [443]
+y: *b
+z: *b
+list: [*n, *n]
+`},
+		{"list[1].k", "w", `base: &b
+    image: nginx
+    ports: [80]
+    nested: &n {k: v}
+x:
+    !!merge <<: *b
+    name: x
+y: *b
+z: *b
+list: [*n, {k: w}]
+`},
+		{"y.nested.k", "w", `base: &b
+    image: nginx
+    ports: [80]
+    nested: &n {k: v}
+x:
+    !!merge <<: *b
+    name: x
+y:
+    image: nginx
+    ports: [80]
+    nested: {k: w}
+z: *b
+list: [*n, *n]
+`},
+	}
+	for _, tt := range tests {
+		var root yaml.Node
+		if err := yaml.Unmarshal([]byte(doc), &root); err != nil {
+			t.Fatal(err)
+		}
+		p, err := Parse(tt.path)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.path, err)
+		}
+		matches, err := (&Navigator{ExpandAliases: true}).Find(&root, p)
+		if err != nil || len(matches) != 1 {
+			t.Fatalf("Find(%q) = %d matches, %v", tt.path, len(matches), err)
+		}
+		if got := encode(t, &root); got != encode(t, mustParse(t, doc)) {
+			t.Errorf("Find(%q) changed the document:\n%s", tt.path, got)
+		}
+
+		m := matches[0]
+		m.Materialize()
+		if m.Parent.Content[m.Index] != m.Node {
+			t.Errorf("%s: Parent.Content[%d] is not the matched node", tt.path, m.Index)
+		}
+		m.Node.Value = tt.value
+		if got := encode(t, &root); got != tt.want {
+			t.Errorf("%s:\n--- got\n%s--- want\n%s", tt.path, got, tt.want)
+		}
+	}
+}
+
+// 同一次查找中多个匹配经过同一个别名时共用一个副本
+func TestFindExpandedShared(t *testing.T) {
+	root := mustParse(t, "base: &b {a: 1, c: 2}\ny: *b\n")
+	p, err := Parse("y.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, err := (&Navigator{ExpandAliases: true}).Find(root, p)
+	if err != nil || len(matches) != 2 {
+		t.Fatalf("Find = %d matches, %v", len(matches), err)
+	}
+	for i, m := range matches {
+		m.Materialize()
+		m.Node.Value = []string{"10", "20"}[i]
+	}
+	const want = "base: &b {a: 1, c: 2}\ny: {a: 10, c: 20}\n"
+	if got := encode(t, root); got != want {
+		t.Errorf("got\n%swant\n%s", got, want)
+	}
+}
+
+func mustParse(t *testing.T, doc string) *yaml.Node {
+	t.Helper()
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(doc), &root); err != nil {
+		t.Fatal(err)
+	}
+	return &root
+}
+
+func encode(t *testing.T, root *yaml.Node) string {
+	t.Helper()
+	data, err := yaml.Marshal(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -13,20 +13,30 @@ import (
 //   - spec.template.spec
 //   - containers[*]
 //   - containers[0]
+//   - containers[last]
+//   - containers[-1]、volumes[0:3]、args[::2] (负数下标从末尾倒数，切片语义同 Python)
 //   - containers[name=foo]
+//   - annotations."sidecar.istio.io/inject" (键中含 . 时用双引号包裹)
 //   - env[?] (占位符，实际匹配由 where 条件决定)
+//   - ..image、spec..resources.limits (递归下降，在任意深度查找字段)
+//   - metadata.labels.*、spec.*.image (* 匹配 mapping 的每个键，键名为 * 时写作 "*")
 func Parse(pathStr string) (*Path, error) {
 	if pathStr == "" {
-		return nil, fmt.Errorf("empty path")
+		return nil, &PathError{Msg: "empty path", Err: ErrInvalidPath}
 	}
 
 	segments := []*Segment{}
 	parts := splitPath(pathStr)
 
-	for _, part := range parts {
+	for i, part := range parts {
 		seg, err := parseSegment(part)
 		if err != nil {
-			return nil, fmt.Errorf("invalid segment '%s': %w", part, err)
+			return nil, &PathError{
+				Path:    pathStr,
+				Segment: i,
+				Msg:     fmt.Sprintf("invalid segment '%s': %v", part, err),
+				Err:     ErrInvalidPath,
+			}
 		}
 		segments = append(segments, seg)
 	}
@@ -34,29 +44,44 @@ func Parse(pathStr string) (*Path, error) {
 	return &Path{Segments: segments}, nil
 }
 
-// splitPath 分割路径，处理 . 和 []
+// splitPath 分割路径，处理 . 、[] 和双引号包裹的键
 // 例如: "spec.containers[name=foo].env" -> ["spec", "containers[name=foo]", "env"]
+// 连续的点原样保留在下一个片段前，如 "spec..image" -> ["spec", "..image"]
 func splitPath(pathStr string) []string {
 	var parts []string
 	var current strings.Builder
 	inBracket := false
+	inQuote := false
+	dots := 0 // 片段之间连续的点数
 
 	for _, ch := range pathStr {
+		if ch != '.' || inBracket || inQuote {
+			if current.Len() == 0 && dots > 1 {
+				current.WriteString(strings.Repeat(".", dots))
+			}
+			dots = 0
+		}
 		switch ch {
+		case '"':
+			if !inBracket {
+				inQuote = !inQuote
+			}
+			current.WriteRune(ch)
 		case '[':
-			inBracket = true
+			inBracket = inBracket || !inQuote
 			current.WriteRune(ch)
 		case ']':
-			inBracket = false
+			inBracket = inBracket && inQuote
 			current.WriteRune(ch)
 		case '.':
-			if inBracket {
+			if inBracket || inQuote {
 				current.WriteRune(ch)
 			} else {
 				if current.Len() > 0 {
 					parts = append(parts, current.String())
 					current.Reset()
 				}
+				dots++
 			}
 		default:
 			current.WriteRune(ch)
@@ -65,6 +90,8 @@ func splitPath(pathStr string) []string {
 
 	if current.Len() > 0 {
 		parts = append(parts, current.String())
+	} else if dots > 1 {
+		parts = append(parts, strings.Repeat(".", dots))
 	}
 
 	return parts
@@ -72,6 +99,26 @@ func splitPath(pathStr string) []string {
 
 // parseSegment 解析单个路径片段
 func parseSegment(part string) (*Segment, error) {
+	if rest, ok := strings.CutPrefix(part, ".."); ok {
+		if rest == "" || strings.HasPrefix(rest, ".") || rest == "*" {
+			return nil, fmt.Errorf("recursive descent '..' must be followed by a field")
+		}
+		seg, err := parseSegment(rest)
+		if err != nil {
+			return nil, err
+		}
+		seg.Recursive = true
+		return seg, nil
+	}
+
+	if strings.HasPrefix(part, `"`) {
+		return parseQuotedSegment(part)
+	}
+
+	if part == "*" {
+		return &Segment{Type: SegmentTypeWildcard, Field: part}, nil
+	}
+
 	// 检查是否有选择器
 	if strings.Contains(part, "[") {
 		return parseArraySegment(part)
@@ -84,6 +131,32 @@ func parseSegment(part string) (*Segment, error) {
 	}, nil
 }
 
+// parseQuotedSegment 解析以双引号包裹键名的片段，如 "a.b/c" 或 "a.b"[0]
+func parseQuotedSegment(part string) (*Segment, error) {
+	end := strings.Index(part[1:], `"`)
+	if end == -1 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+
+	field, rest := part[1:end+1], part[end+2:]
+	if field == "" {
+		return nil, fmt.Errorf("field name cannot be empty")
+	}
+	if rest == "" {
+		return &Segment{Type: SegmentTypeField, Field: field}, nil
+	}
+	if !strings.HasPrefix(rest, "[") {
+		return nil, fmt.Errorf("unexpected %q after quoted field", rest)
+	}
+
+	seg, err := parseArraySegment(rest)
+	if err != nil {
+		return nil, err
+	}
+	seg.Field = field
+	return seg, nil
+}
+
 // parseArraySegment 解析数组片段，如 "containers[name=foo]" 或 "env[name=@^SW_.*$@]"
 func parseArraySegment(part string) (*Segment, error) {
 	bracketStart := strings.Index(part, "[")
@@ -92,6 +165,9 @@ func parseArraySegment(part string) (*Segment, error) {
 	}
 
 	field := part[:bracketStart]
+	if field == "*" {
+		return nil, fmt.Errorf("wildcard '*' cannot have a selector, use * and [...] as separate segments")
+	}
 
 	// 查找配对的 ]，跳过 @...@ 内部的 ]
 	bracketEnd := findClosingBracket(part, bracketStart+1)
@@ -134,10 +210,17 @@ func findClosingBracket(s string, start int) int {
 
 // parseSelector 解析选择器
 // 支持语法：
-//   - * 或 ? : 通配符
-//   - 数字 : 索引
-//   - field=value : 精确匹配
+//   - * : 通配符
+//   - ? : 由导航器的 Where 条件选择，未设置时同 *
+//   - 数字 : 索引，负数从末尾倒数
+//   - start:end 或 start:end:step : 切片，各部分可省略
+//   - first / last / even / odd : 位置
+//   - field=value : 精确匹配（按 YAML 类型比较）
+//   - field="value" : 精确匹配（仅字符串比较）
 //   - field=@pattern@ : 正则匹配
+//   - field!=value、field!=@pattern@ : 不等于、正则不匹配
+//   - field>value、>=、<、<= : 比较，字段为数字且值可解析为数字时按数值，否则按字符串
+//   - field contains value : 字符串包含子串，或序列包含该元素
 func parseSelector(selectorStr string) (*Selector, error) {
 	// 通配符
 	if selectorStr == "*" {
@@ -146,68 +229,155 @@ func parseSelector(selectorStr string) (*Selector, error) {
 
 	// 占位符（where 条件）
 	if selectorStr == "?" {
-		return &Selector{Type: SelectorTypeWildcard}, nil
+		return &Selector{Type: SelectorTypeWhere}, nil
+	}
+
+	// 位置
+	switch selectorStr {
+	case PositionFirst, PositionLast, PositionEven, PositionOdd:
+		return &Selector{Type: SelectorTypePosition, Position: selectorStr}, nil
 	}
 
 	// 索引
 	if idx, err := strconv.Atoi(selectorStr); err == nil {
-		return &Selector{
-			Type: SelectorTypeIndex,
-			Condition: &Condition{
-				Field: "_index",
-				Op:    OpEqual,
-				Value: idx,
-			},
-		}, nil
+		return &Selector{Type: SelectorTypeIndex, Index: idx}, nil
+	}
+
+	// 条件：field<op>value，op 为 = != > >= < <= 或 contains
+	if field, op, value, ok := splitCondition(selectorStr); ok {
+		return parseCondition(field, op, value)
 	}
 
-	// 条件：field=value 或 field=@pattern@
-	if strings.Contains(selectorStr, "=") {
-		parts := strings.SplitN(selectorStr, "=", 2)
-		if len(parts) != 2 {
-			return nil, fmt.Errorf("invalid condition syntax")
+	// 切片：含 : 且不是条件
+	if strings.Contains(selectorStr, ":") {
+		slice, err := parseSlice(selectorStr)
+		if err != nil {
+			return nil, err
 		}
+		return &Selector{Type: SelectorTypeSlice, Slice: slice}, nil
+	}
 
-		field := parts[0]
-		value := parts[1]
+	return nil, fmt.Errorf("unknown selector syntax: %s", selectorStr)
+}
 
-		if field == "" {
-			return nil, fmt.Errorf("field name cannot be empty")
+// containsOp contains 运算符，两侧需有空白
+const containsOp = " contains "
+
+// splitCondition 在第一个运算符处拆分条件，值中可以再出现运算符字符
+func splitCondition(s string) (field, op, value string, ok bool) {
+	for i := 0; i < len(s); i++ {
+		if strings.HasPrefix(s[i:], containsOp) {
+			return s[:i], "contains", strings.TrimLeft(s[i+len(containsOp):], " "), true
 		}
+		switch s[i] {
+		case '=':
+			return s[:i], "=", s[i+1:], true
+		case '!', '<', '>':
+			if i+1 < len(s) && s[i+1] == '=' {
+				return s[:i], s[i : i+2], s[i+2:], true
+			}
+			if s[i] != '!' {
+				return s[:i], s[i : i+1], s[i+1:], true
+			}
+		}
+	}
+	return "", "", "", false
+}
 
-		// 检测是否是正则（以 @ 包裹）
-		if strings.HasPrefix(value, "@") && strings.HasSuffix(value, "@") {
-			pattern := strings.Trim(value, "@")
+// parseCondition 解析条件选择器
+// = 与 != 的值可写作 @pattern@ 表示正则；值带引号时只做字符串比较
+func parseCondition(field, op, value string) (*Selector, error) {
+	field = strings.TrimSpace(field)
+	if field == "" {
+		return nil, fmt.Errorf("field name cannot be empty")
+	}
 
-			if pattern == "" {
-				return nil, fmt.Errorf("regex pattern cannot be empty")
-			}
+	// 检测是否是正则（以 @ 包裹）
+	if (op == "=" || op == "!=") && strings.HasPrefix(value, "@") && strings.HasSuffix(value, "@") {
+		pattern := strings.Trim(value, "@")
 
-			// 校验正则合法性
-			if _, err := regexp2.Compile(pattern, 0); err != nil {
-				return nil, fmt.Errorf("invalid regex pattern: %w", err)
-			}
+		if pattern == "" {
+			return nil, fmt.Errorf("regex pattern cannot be empty")
+		}
 
-			return &Selector{
-				Type: SelectorTypeCondition,
-				Condition: &Condition{
-					Field: field,
-					Op:    OpRegex,
-					Value: pattern,
-				},
-			}, nil
+		// 校验正则合法性
+		if _, err := regexp2.Compile(pattern, 0); err != nil {
+			return nil, fmt.Errorf("invalid regex pattern: %w", err)
 		}
 
-		// 精确匹配
-		return &Selector{
-			Type: SelectorTypeCondition,
-			Condition: &Condition{
-				Field: field,
-				Op:    OpEqual,
-				Value: value,
-			},
-		}, nil
+		cond := &Condition{Field: field, Op: OpRegex, Value: pattern}
+		if op == "!=" {
+			cond.Op = OpNotRegex
+		}
+		return &Selector{Type: SelectorTypeCondition, Condition: cond}, nil
 	}
 
-	return nil, fmt.Errorf("unknown selector syntax: %s", selectorStr)
+	quoted := len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0]
+	if quoted {
+		value = value[1 : len(value)-1]
+	}
+	if value == "" && op != "=" && op != "!=" {
+		return nil, fmt.Errorf("value cannot be empty for %s", op)
+	}
+
+	ops := map[string]Operator{
+		"=": OpEqual, "!=": OpNotEqual, ">": OpGreater, ">=": OpGreaterEqual,
+		"<": OpLess, "<=": OpLessEqual, "contains": OpContains,
+	}
+	return &Selector{
+		Type: SelectorTypeCondition,
+		Condition: &Condition{
+			Field:  field,
+			Op:     ops[op],
+			Value:  value,
+			Quoted: quoted,
+		},
+	}, nil
+}
+
+// parseSlice 解析 start:end[:step]，省略的部分取默认值
+func parseSlice(s string) (*Slice, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("invalid slice %q, expected start:end:step", s)
+	}
+
+	bounds := make([]*int, 3)
+	for i, part := range parts {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid slice %q: %q is not an integer", s, part)
+		}
+		bounds[i] = &n
+	}
+
+	slice := &Slice{Start: bounds[0], End: bounds[1], Step: 1}
+	if bounds[2] != nil {
+		if *bounds[2] == 0 {
+			return nil, fmt.Errorf("invalid slice %q: step cannot be zero", s)
+		}
+		slice.Step = *bounds[2]
+	}
+	return slice, nil
+}
+
+// HasPrefix 判断路径 p 是否位于 prefix 之下（按片段比较）
+// prefix 的最后一个片段为字段时，也匹配 p 中同名的数组片段，
+// 如 spec.containers 匹配 spec.containers[name=app].image
+func HasPrefix(p, prefix string) bool {
+	parts, prefixParts := splitPath(p), splitPath(prefix)
+	if len(prefixParts) == 0 || len(prefixParts) > len(parts) {
+		return false
+	}
+
+	last := len(prefixParts) - 1
+	for i, part := range prefixParts[:last] {
+		if parts[i] != part {
+			return false
+		}
+	}
+	return parts[last] == prefixParts[last] || strings.HasPrefix(parts[last], prefixParts[last]+"[")
 }
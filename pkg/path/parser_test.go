@@ -0,0 +1,114 @@
+package path
+
+import (
+	"slices"
+	"testing"
+)
+
+// 条件在第一个运算符处拆分，值中（含引号内）再出现的运算符字符与 contains 属于值
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		selector string
+		field    string
+		op       Operator
+		value    string
+		quoted   bool
+	}{
+		{"name=foo", "name", OpEqual, "foo", false},
+		{"a!=b", "a", OpNotEqual, "b", false},
+		{"x>=1", "x", OpGreaterEqual, "1", false},
+		{"x<=1", "x", OpLessEqual, "1", false},
+		{"x>1", "x", OpGreater, "1", false},
+		{"x<-1", "x", OpLess, "-1", false},
+		{"a=b=c", "a", OpEqual, "b=c", false},
+		{"a=x>=1", "a", OpEqual, "x>=1", false},
+		{"a!=@^v[0-9]+$@", "a", OpNotRegex, "^v[0-9]+$", false},
+		{"name=@^app-.*@", "name", OpRegex, "^app-.*", false},
+		{`name="a contains b"`, "name", OpEqual, "a contains b", true},
+		{`name='x!=y'`, "name", OpEqual, "x!=y", true},
+		{`image contains ":latest"`, "image", OpContains, ":latest", true},
+		{`note contains "x contains y"`, "note", OpContains, "x contains y", true},
+		{"args contains --debug", "args", OpContains, "--debug", false},
+		{"tag=", "tag", OpEqual, "", false},
+		{`tag=""`, "tag", OpEqual, "", true},
+	}
+	for _, tt := range tests {
+		p, err := Parse("items[" + tt.selector + "]")
+		if err != nil {
+			t.Errorf("Parse([%s]): %v", tt.selector, err)
+			continue
+		}
+		sel := p.Segments[0].Selector
+		if sel == nil || sel.Type != SelectorTypeCondition {
+			t.Errorf("Parse([%s]): selector = %+v, want a condition", tt.selector, sel)
+			continue
+		}
+		c := sel.Condition
+		if c.Field != tt.field || c.Op != tt.op || c.Value != tt.value || c.Quoted != tt.quoted {
+			t.Errorf("Parse([%s]) = {%q %v %q %v}, want {%q %v %q %v}",
+				tt.selector, c.Field, c.Op, c.Value, c.Quoted, tt.field, tt.op, tt.value, tt.quoted)
+		}
+	}
+}
+
+func TestParseConditionInvalid(t *testing.T) {
+	for _, selector := range []string{
+		"=foo",       // 缺少字段名
+		"a!b",        // ! 后没有 =
+		"x>",         // 比较需要值
+		"a contains", // contains 两侧需有空白与值
+		"a=@@",       // 空正则
+		"a=@(@",      // 非法正则
+	} {
+		if _, err := Parse("items[" + selector + "]"); err == nil {
+			t.Errorf("Parse([%s]): expected error", selector)
+		}
+	}
+}
+
+// 切片语义同 Python：负数从末尾倒数，越界时截断，step 为负时倒序
+func TestParseSlice(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     []int // 长度为 5 的序列中选中的下标
+	}{
+		{"0:3", []int{0, 1, 2}},
+		{"::2", []int{0, 2, 4}},
+		{"::-1", []int{4, 3, 2, 1, 0}},
+		{"-2:", []int{3, 4}},
+		{":-1", []int{0, 1, 2, 3}},
+		{"1:-1:2", []int{1, 3}},
+		{"3:0:-1", []int{3, 2, 1}},
+		{":-10:-1", []int{4, 3, 2, 1, 0}},
+		{"-10:2", []int{0, 1}},
+		{"10:", nil},
+		{" 1 : 3 ", []int{1, 2}},
+	}
+	for _, tt := range tests {
+		p, err := Parse("items[" + tt.selector + "]")
+		if err != nil {
+			t.Errorf("Parse([%s]): %v", tt.selector, err)
+			continue
+		}
+		sel := p.Segments[0].Selector
+		if sel == nil || sel.Type != SelectorTypeSlice {
+			t.Errorf("Parse([%s]): selector = %+v, want a slice", tt.selector, sel)
+			continue
+		}
+		if got := sel.Slice.Indices(5); !slices.Equal(got, tt.want) {
+			t.Errorf("[%s].Indices(5) = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestParseSliceInvalid(t *testing.T) {
+	for _, selector := range []string{
+		"1:2:3:4", // 切片最多三段
+		"::0",     // step 不能为 0
+		"a:b",     // 切片边界不是整数
+	} {
+		if _, err := Parse("items[" + selector + "]"); err == nil {
+			t.Errorf("Parse([%s]): expected error", selector)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package path
+
+import (
+	"slices"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 位置与下标选择器不借用条件，数组元素中名为 _position、_index 的字段按普通条件匹配
+func TestPositionSelectors(t *testing.T) {
+	const doc = `items:
+  - {_position: last, _index: 1, v: a}
+  - {_position: first, _index: 0, v: b}
+  - {v: c}
+`
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"items[first].v", []string{"a"}},
+		{"items[last].v", []string{"c"}},
+		{"items[even].v", []string{"a", "c"}},
+		{"items[odd].v", []string{"b"}},
+		{"items[-1].v", []string{"c"}},
+		{"items[_position=first].v", []string{"b"}},
+		{"items[_index=0].v", []string{"b"}},
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(doc), &root); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		p, err := Parse(tt.path)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.path, err)
+		}
+		matches, err := (&Navigator{}).Find(&root, p)
+		if err != nil {
+			t.Fatalf("Find(%q): %v", tt.path, err)
+		}
+		var got []string
+		for _, m := range matches {
+			got = append(got, m.Node.Value)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Find(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -3,43 +3,101 @@ package path
 // Segment 表示路径的一个片段
 type Segment struct {
 	Type      SegmentType
-	Field     string            // 字段名，如 "spec"
-	Selector  *Selector         // 选择器，如 [name=foo] 或 [*]
+	Field     string    // 字段名，如 "spec"
+	Selector  *Selector // 选择器，如 [name=foo] 或 [*]
+	Recursive bool      // ..field：在当前节点及任意深度的后代中查找该字段
 }
 
 type SegmentType int
 
 const (
-	SegmentTypeField SegmentType = iota // 普通字段访问
-	SegmentTypeArray                    // 数组访问
+	SegmentTypeField    SegmentType = iota // 普通字段访问
+	SegmentTypeArray                       // 数组访问
+	SegmentTypeWildcard                    // * 匹配 mapping 的每个键
 )
 
 // Selector 表示数组选择器
 type Selector struct {
 	Type      SelectorType
-	Condition *Condition // 条件匹配
+	Condition *Condition // 条件匹配，仅 SelectorTypeCondition
+	Index     int        // 下标，负数从末尾倒数，仅 SelectorTypeIndex
+	Position  string     // 位置（PositionFirst 等），仅 SelectorTypePosition
+	Slice     *Slice     // 切片，仅 SelectorTypeSlice
 }
 
 type SelectorType int
 
 const (
-	SelectorTypeWildcard SelectorType = iota // [*] 通配符
-	SelectorTypeIndex                        // [0] 索引
-	SelectorTypeCondition                    // [name=foo] 条件
+	SelectorTypeWildcard  SelectorType = iota // [*] 通配符
+	SelectorTypeIndex                         // [0] 索引
+	SelectorTypeCondition                     // [name=foo] 条件
+	SelectorTypePosition                      // [first] [last] [even] [odd] 位置
+	SelectorTypeSlice                         // [0:3] [::2] [-2:] 切片
+	SelectorTypeWhere                         // [?] 由规则的 where 条件选择
+)
+
+// Slice 表示切片 [start:end:step]，语义同 Python：负数从末尾倒数，越界时截断
+type Slice struct {
+	Start *int // nil 表示从头（step 为负时从末尾）开始
+	End   *int // nil 表示到末尾（step 为负时到开头）为止
+	Step  int  // 非零，默认 1
+}
+
+// Indices 返回长度为 n 的序列中被切片选中的下标，按 step 的方向排列
+func (s *Slice) Indices(n int) []int {
+	// bound 将下标规范到 [lo, hi]
+	bound := func(p *int, def, lo, hi int) int {
+		if p == nil {
+			return def
+		}
+		i := *p
+		if i < 0 {
+			i += n
+		}
+		return max(lo, min(i, hi))
+	}
+
+	var indices []int
+	if s.Step > 0 {
+		for i, end := bound(s.Start, 0, 0, n), bound(s.End, n, 0, n); i < end; i += s.Step {
+			indices = append(indices, i)
+		}
+	} else {
+		for i, end := bound(s.Start, n-1, -1, n-1), bound(s.End, -1, -1, n-1); i > end; i += s.Step {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// 位置选择器取值，even/odd 按 0 起始的下标计算
+const (
+	PositionFirst = "first"
+	PositionLast  = "last"
+	PositionEven  = "even"
+	PositionOdd   = "odd"
 )
 
 // Condition 表示匹配条件
 type Condition struct {
-	Field string      // 字段名
-	Op    Operator    // 操作符
-	Value interface{} // 值
+	Field  string      // 字段名
+	Op     Operator    // 操作符
+	Value  interface{} // 值
+	Quoted bool        // 值带引号，只做字符串比较
 }
 
 type Operator int
 
 const (
-	OpEqual Operator = iota // = 精确匹配
-	OpRegex                 // = 正则匹配（值为 @pattern@）
+	OpEqual        Operator = iota // = 精确匹配
+	OpRegex                        // = 正则匹配（值为 @pattern@）
+	OpNotEqual                     // != 不等于
+	OpNotRegex                     // != 正则不匹配
+	OpGreater                      // >
+	OpGreaterEqual                 // >=
+	OpLess                         // <
+	OpLessEqual                    // <=
+	OpContains                     // contains 字符串包含子串，或序列包含元素
 )
 
 // Path 表示解析后的完整路径
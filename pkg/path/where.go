@@ -0,0 +1,219 @@
+package path
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dlclark/regexp2"
+	"gopkg.in/yaml.v3"
+)
+
+// Where 规则的 where 条件，决定路径中 [?] 选中数组的哪些元素
+// 叶子条件由 field、op 与 value/values 组成；all、any、not 组合其他条件，一个条件只能写其中一种
+//
+//	where:
+//	  all:
+//	    - field: name
+//	      op: regex
+//	      value: ^SW_
+//	    - not:
+//	        field: valueFrom
+//	        op: exists
+type Where struct {
+	Field  string   `yaml:"field,omitempty"`  // 相对元素的路径，如 name、metadata.labels.app、ports[0].name
+	Op     string   `yaml:"op,omitempty"`     // 运算符，省略时为 eq
+	Value  string   `yaml:"value,omitempty"`  // eq、ne、regex 的比较值
+	Values []string `yaml:"values,omitempty"` // in、not_in 的候选值
+	All    []*Where `yaml:"all,omitempty"`    // 全部满足
+	Any    []*Where `yaml:"any,omitempty"`    // 任一满足
+	Not    *Where   `yaml:"not,omitempty"`    // 取反
+}
+
+// where 条件的运算符
+const (
+	WhereEq     = "eq"     // 等于，比较方式同 [field=value]
+	WhereNe     = "ne"     // 不等于，字段不存在时满足
+	WhereRegex  = "regex"  // 正则匹配，同 [field=@pattern@]
+	WhereIn     = "in"     // 等于 values 中的任一值
+	WhereNotIn  = "not_in" // 不等于 values 中的任何值，字段不存在时满足
+	WhereExists = "exists" // 字段存在（值为 null 也算存在）
+)
+
+// op 返回运算符，省略时为 eq
+func (w *Where) op() string {
+	if w.Op == "" {
+		return WhereEq
+	}
+	return w.Op
+}
+
+// Validate 校验条件的结构、字段路径与正则
+func (w *Where) Validate() error {
+	forms := 0
+	for _, set := range []bool{w.Field != "", w.All != nil, w.Any != nil, w.Not != nil} {
+		if set {
+			forms++
+		}
+	}
+	if forms != 1 {
+		return fmt.Errorf("condition must have exactly one of field, all, any or not")
+	}
+
+	switch {
+	case w.All != nil || w.Any != nil:
+		list, name := w.All, "all"
+		if w.Any != nil {
+			list, name = w.Any, "any"
+		}
+		if len(list) == 0 {
+			return fmt.Errorf("%s must not be empty", name)
+		}
+		for i, c := range list {
+			if c == nil {
+				return fmt.Errorf("%s[%d]: empty condition", name, i)
+			}
+			if err := c.Validate(); err != nil {
+				return fmt.Errorf("%s[%d]: %w", name, i, err)
+			}
+		}
+		return nil
+	case w.Not != nil:
+		if err := w.Not.Validate(); err != nil {
+			return fmt.Errorf("not: %w", err)
+		}
+		return nil
+	}
+
+	p, err := Parse(w.Field)
+	if err != nil {
+		return fmt.Errorf("field: %w", err)
+	}
+	for _, seg := range p.Segments {
+		if seg.Selector != nil && seg.Selector.Type == SelectorTypeWhere {
+			return fmt.Errorf("field %q cannot contain [?]", w.Field)
+		}
+	}
+
+	switch op := w.op(); op {
+	case WhereEq, WhereNe, WhereRegex:
+		if w.Values != nil {
+			return fmt.Errorf("values only applies to %s and %s", WhereIn, WhereNotIn)
+		}
+		if op == WhereRegex {
+			if w.Value == "" {
+				return fmt.Errorf("regex pattern cannot be empty")
+			}
+			if _, err := regexp2.Compile(w.Value, 0); err != nil {
+				return fmt.Errorf("invalid regex pattern: %w", err)
+			}
+		}
+	case WhereIn, WhereNotIn:
+		if w.Value != "" {
+			return fmt.Errorf("%s takes values, not value", op)
+		}
+		if len(w.Values) == 0 {
+			return fmt.Errorf("values is required for %s", op)
+		}
+	case WhereExists:
+		if w.Value != "" || w.Values != nil {
+			return fmt.Errorf("exists does not take a value")
+		}
+	default:
+		return fmt.Errorf("unknown op %q, expected %s, %s, %s, %s, %s or %s", op, WhereEq, WhereNe, WhereRegex, WhereIn, WhereNotIn, WhereExists)
+	}
+	return nil
+}
+
+// matchWhere 检查元素是否满足 where 条件，只有正则匹配超时时返回错误
+// 字段路径匹配多个节点时任一节点满足即可，ne 与 not_in 要求所有节点都不满足
+func (n *Navigator) matchWhere(node *yaml.Node, w *Where) (bool, error) {
+	switch {
+	case w.All != nil:
+		for _, c := range w.All {
+			if ok, err := n.matchWhere(node, c); err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case w.Any != nil:
+		for _, c := range w.Any {
+			if ok, err := n.matchWhere(node, c); err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	case w.Not != nil:
+		ok, err := n.matchWhere(node, w.Not)
+		return !ok, err
+	}
+
+	values, err := n.whereField(node, w.Field)
+	if err != nil {
+		return false, err
+	}
+
+	switch op := w.op(); op {
+	case WhereExists:
+		return len(values) > 0, nil
+	case WhereNe:
+		ok, err := n.matchValues(values, WhereEq, w)
+		return !ok, err
+	case WhereNotIn:
+		ok, err := n.matchValues(values, WhereIn, w)
+		return !ok, err
+	default:
+		return n.matchValues(values, op, w)
+	}
+}
+
+// matchValues 任一标量节点按 op（eq、in 或 regex）满足条件时返回 true
+func (n *Navigator) matchValues(values []*yaml.Node, op string, w *Where) (bool, error) {
+	for _, v := range values {
+		if v.Kind != yaml.ScalarNode {
+			continue
+		}
+		value := v.Value
+		if n.Trim {
+			value = strings.TrimSpace(value)
+		}
+		switch op {
+		case WhereEq:
+			if n.equal(value, v.ShortTag(), &Condition{Value: w.Value}) {
+				return true, nil
+			}
+		case WhereIn:
+			for _, want := range w.Values {
+				if n.equal(value, v.ShortTag(), &Condition{Value: want}) {
+					return true, nil
+				}
+			}
+		case WhereRegex:
+			matched, err := n.matchRegex(value, w.Value)
+			if err != nil || matched {
+				return matched, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// whereField 查找元素中字段路径对应的节点（已展开别名），字段不存在时返回空列表
+func (n *Navigator) whereField(node *yaml.Node, field string) ([]*yaml.Node, error) {
+	p, err := Parse(field)
+	if err != nil {
+		return nil, nil
+	}
+	nav := *n
+	nav.CreateMissing = false
+	nav.ExpandAliases = false
+	matches, err := nav.Find(node, p)
+	if errors.Is(err, ErrTimeout) {
+		return nil, err
+	}
+	var nodes []*yaml.Node
+	for _, m := range matches {
+		nodes = append(nodes, resolveAlias(m.Node))
+	}
+	return nodes, nil
+}
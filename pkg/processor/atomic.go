@@ -0,0 +1,98 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ErrOwnerNotPreserved 替换已有文件时无法把临时文件的属主设为原文件的（如组可写、属于其他用户的文件），
+// 文件未写入；Options.AllowNonAtomic 时改为直接覆盖写入
+var ErrOwnerNotPreserved = errors.New("cannot preserve owner")
+
+// preserveOwner 设置临时文件的属主，测试中替换以模拟无权修改属主
+var preserveOwner = keepOwner
+
+// writeAtomic 以事务方式写入文件：先写入同目录下的临时文件并 fsync，再 rename 覆盖目标，
+// 写入中途崩溃或出错时目标文件保持原内容，不会留下写了一半的文件
+// 目标已存在时沿用其权限与属主（属主无法保留时返回 ErrOwnerNotPreserved，不写入），是符号链接时写入链接指向的文件；
+// 新建的文件权限为 0644
+func writeAtomic(name string, data []byte) (err error) {
+	mode := fs.FileMode(0644)
+	info, err := os.Stat(name)
+	switch {
+	case err == nil:
+		if name, err = filepath.EvalSymlinks(name); err != nil {
+			return err
+		}
+		mode = info.Mode().Perm()
+	case errors.Is(err, fs.ErrNotExist):
+		info = nil
+	default:
+		return err
+	}
+
+	dir, base := filepath.Split(name)
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(mode); err != nil {
+		return err
+	}
+	if info != nil {
+		if err = preserveOwner(tmp, info); err != nil {
+			return fmt.Errorf("%w of %s: %w", ErrOwnerNotPreserved, name, err)
+		}
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), name); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// writeInPlace 截断并覆盖写入已有文件，保留文件本身（属主、权限、硬链接）；
+// 不是原子的，写入中途崩溃会留下不完整的文件，只在 Options.AllowNonAtomic 时使用
+func writeInPlace(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// syncDir fsync 目录，使 rename 本身落盘；部分平台不支持对目录 fsync，失败时忽略
+func syncDir(dir string) {
+	if dir == "" {
+		dir = "."
+	}
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package processor
+
+import (
+	"io/fs"
+	"os"
+)
+
+// keepOwner 非 Unix 平台没有属主的概念，不做任何事
+func keepOwner(tmp *os.File, info fs.FileInfo) error {
+	return nil
+}
@@ -0,0 +1,72 @@
+package processor
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glesirok/yamleditor/pkg/rule"
+)
+
+// 无法保留属主时默认不写入；AllowNonAtomic 时直接覆盖写入并产生警告
+func TestWriteOwnerNotPreserved(t *testing.T) {
+	saved := preserveOwner
+	preserveOwner = func(*os.File, fs.FileInfo) error { return fs.ErrPermission }
+	t.Cleanup(func() { preserveOwner = saved })
+
+	config, err := rule.ParseInline([]byte(`{action: replace, path: replicas, value: 3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		nonAtomic bool
+		want      string
+	}{
+		{name: "fails by default", want: "replicas: 1\n"},
+		{name: "allow non-atomic", nonAtomic: true, want: "replicas: 3\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, "app.yaml")
+			if err := os.WriteFile(file, []byte("replicas: 1\n"), 0o664); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(file, 0o664); err != nil { // 不受 umask 影响
+				t.Fatal(err)
+			}
+			proc := NewProcessorFromConfig(config)
+			proc.SetOptions(Options{AllowNonAtomic: tt.nonAtomic})
+			result, err := proc.ProcessFile(file, file, false)
+
+			if tt.nonAtomic {
+				if err != nil {
+					t.Fatalf("ProcessFile() error = %v", err)
+				}
+				if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "not atomically") {
+					t.Errorf("Warnings = %q, want a non-atomic write warning", result.Warnings)
+				}
+			} else if !errors.Is(err, ErrOwnerNotPreserved) {
+				t.Fatalf("ProcessFile() error = %v, want ErrOwnerNotPreserved", err)
+			}
+
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("file = %q, want %q", data, tt.want)
+			}
+			if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0o664 {
+				t.Errorf("mode = %v, %v, want 0664", info.Mode().Perm(), err)
+			}
+			if tmp, _ := filepath.Glob(filepath.Join(dir, ".app.yaml.tmp-*")); len(tmp) != 0 {
+				t.Errorf("temporary files left behind: %v", tmp)
+			}
+		})
+	}
+}
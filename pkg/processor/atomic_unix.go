@@ -0,0 +1,22 @@
+//go:build unix
+
+package processor
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// keepOwner 将临时文件的属主与属组设为原文件的，与当前用户相同时不做任何事
+func keepOwner(tmp *os.File, info fs.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	uid, gid := int(st.Uid), int(st.Gid)
+	if uid == os.Getuid() && gid == os.Getgid() {
+		return nil
+	}
+	return tmp.Chown(uid, gid)
+}
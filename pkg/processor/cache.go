@@ -0,0 +1,183 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/glesirok/yamleditor/pkg/engine"
+	"gopkg.in/yaml.v3"
+)
+
+// Cache 增量处理缓存，记录每个输入文件上一次处理时的输入、规则与输出摘要
+// 三者都未变化时，再次处理该文件会直接跳过解析和规则执行；路径统一以 / 分隔记录
+type Cache struct {
+	path    string
+	entries map[string]cacheEntry
+	dirty   bool
+}
+
+// cacheEntry 单个输入文件的缓存记录，摘要均为 sha256 十六进制
+type cacheEntry struct {
+	Output     string `json:"output"`      // 输出路径
+	InputHash  string `json:"input_hash"`  // 处理前的输入内容
+	RulesHash  string `json:"rules_hash"`  // 处理时的规则集
+	OutputHash string `json:"output_hash"` // 处理结果
+	Matched    []int  `json:"matched"`     // 每条规则匹配到的节点数，命中缓存时用于还原统计
+}
+
+// LoadCache 加载缓存文件，文件不存在时返回空缓存
+func LoadCache(path string) (*Cache, error) {
+	c := &Cache{path: path, entries: map[string]cacheEntry{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, fmt.Errorf("parse cache: %w", err)
+	}
+	return c, nil
+}
+
+// Save 将缓存写回文件，没有变化时不写
+func (c *Cache) Save() error {
+	if !c.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal cache: %w", err)
+	}
+	if err := writeAtomic(c.path, data); err != nil {
+		return fmt.Errorf("write cache: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
+
+// lookup 返回 input 仍然有效的缓存记录
+// 输出文件可能被外部改动或删除，因此同时核对输出内容
+func (c *Cache) lookup(input, output, inputHash, rulesHash string) (cacheEntry, bool) {
+	e, ok := c.entries[reportPath(input)]
+	if !ok || e.Output != reportPath(output) || e.InputHash != inputHash || e.RulesHash != rulesHash {
+		return e, false
+	}
+	if filepath.Clean(output) == filepath.Clean(input) {
+		return e, e.OutputHash == inputHash
+	}
+	existing, err := os.ReadFile(output)
+	return e, err == nil && digest(existing) == e.OutputHash
+}
+
+// put 记录一次处理结果
+func (c *Cache) put(input, output, inputHash, rulesHash string, result []byte, fr *FileResult) {
+	matched := make([]int, len(fr.Rules))
+	for i, s := range fr.Rules {
+		matched[i] = s.NodesMatched
+	}
+	c.entries[reportPath(input)] = cacheEntry{
+		Output:     reportPath(output),
+		InputHash:  inputHash,
+		RulesHash:  rulesHash,
+		OutputHash: digest(result),
+		Matched:    matched,
+	}
+	c.dirty = true
+}
+
+// cachedResult 由缓存记录还原文件结果，只保留匹配统计
+func cachedResult(rules []*engine.Rule, e cacheEntry, opts Options) *FileResult {
+	result := &FileResult{Status: StatusUnchanged, Cached: true, Rules: make([]RuleStats, len(rules))}
+	for i, r := range rules {
+		result.Rules[i].Rule = r
+		result.Rules[i].Filtered = !opts.selected(r)
+		if i < len(e.Matched) && e.Matched[i] > 0 {
+			result.Rules[i].FilesMatched = 1
+			result.Rules[i].NodesMatched = e.Matched[i]
+		}
+	}
+	return result
+}
+
+// SetCache 设置增量处理缓存，nil 表示不使用缓存
+// 设置了回调时缓存不生效，回调每次都需要看到完整的处理过程
+func (p *Processor) SetCache(c *Cache) {
+	p.cache = c
+}
+
+// cacheEnabled 判断本次处理是否使用缓存
+func (p *Processor) cacheEnabled(dryRun bool) bool {
+	return p.cache != nil && !dryRun &&
+		p.hooks.OnDocumentStart == nil && p.hooks.OnRuleApplied == nil && p.hooks.OnDocumentEnd == nil
+}
+
+// dupsDigest 只有改变输出的重复键策略计入摘要
+func dupsDigest(policy DuplicateKeys) DuplicateKeys {
+	if policy == DuplicateKeysFirstWins || policy == DuplicateKeysLastWins {
+		return policy
+	}
+	return ""
+}
+
+// rulesDigest 规则集、参数、规则引用的环境变量、规则与文档筛选、输入输出格式、
+// 影响处理结果或成败的选项（strict、类型检查、变更上限、schema 内容等）的摘要，任一变化时随之变化
+func rulesDigest(rules []*engine.Rule, params, env map[string]string, opts Options, encoding string) (string, error) {
+	schemaDigest := ""
+	if opts.Schema != nil {
+		schemaDigest = opts.Schema.Digest()
+	}
+
+	// yaml 按键排序输出 map，摘要与参数顺序无关；后加入的字段为零值时省略，摘要与之前的版本相同
+	data, err := yaml.Marshal(struct {
+		Rules              []*engine.Rule
+		Params             map[string]string
+		OnlyPathPrefix     string
+		Encoding           string
+		Select             *engine.Selector  `yaml:",omitempty"`
+		Extract            bool              `yaml:",omitempty"`
+		Format             string            `yaml:",omitempty"`
+		JSONIndent         int               `yaml:",omitempty"`
+		Env                map[string]string `yaml:",omitempty"`
+		Templated          bool              `yaml:",omitempty"`
+		Schema             string            `yaml:",omitempty"`
+		Indent             int               `yaml:",omitempty"`
+		Normalize          bool              `yaml:",omitempty"`
+		PruneEmpty         bool              `yaml:",omitempty"`
+		DuplicateKeys      DuplicateKeys     `yaml:",omitempty"`
+		Strict             bool              `yaml:",omitempty"`
+		StrictTypes        bool              `yaml:",omitempty"`
+		ContinueOnNotFound bool              `yaml:",omitempty"`
+		MaxChangedFiles    int               `yaml:",omitempty"`
+		MaxChangesPerFile  int               `yaml:",omitempty"`
+	}{rules, params, opts.OnlyPathPrefix, encoding, opts.Select, opts.Extract, opts.Format, opts.JSONIndent, env, opts.Templated,
+		schemaDigest, opts.Indent, opts.Normalize, opts.PruneEmpty, dupsDigest(opts.DuplicateKeys),
+		opts.Strict, opts.StrictTypes, opts.ContinueOnNotFound, opts.MaxChangedFiles, opts.MaxChangesPerFile})
+	if err != nil {
+		return "", fmt.Errorf("marshal rules: %w", err)
+	}
+	return digest(data), nil
+}
+
+// environ 返回 names 中已设置的环境变量，lookup 与规则读取环境变量的方式相同（见 SetEnviron）
+func environ(names []string, lookup func(string) (string, bool)) map[string]string {
+	env := map[string]string{}
+	for _, name := range names {
+		if v, ok := lookup(name); ok {
+			env[name] = v
+		}
+	}
+	return env
+}
+
+// digest 返回内容的 sha256 十六进制
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
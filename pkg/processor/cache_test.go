@@ -0,0 +1,103 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glesirok/yamleditor/pkg/rule"
+)
+
+// cacheRun 一个输入文件与输出文件，用同一个缓存反复处理
+type cacheRun struct {
+	t       *testing.T
+	in, out string
+	cache   *Cache
+	proc    *Processor
+}
+
+func newCacheRun(t *testing.T, rules string) *cacheRun {
+	dir := t.TempDir()
+	r := &cacheRun{t: t, in: filepath.Join(dir, "in.yaml"), out: filepath.Join(dir, "out.yaml")}
+	if err := os.WriteFile(r.in, []byte("image: nginx\nreplicas: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if r.cache, err = LoadCache(filepath.Join(dir, "cache.json")); err != nil {
+		t.Fatal(err)
+	}
+	r.setRules(rules)
+	return r
+}
+
+// setRules 换用新的规则，模拟再次执行时规则文件已修改
+func (r *cacheRun) setRules(rules string) {
+	config, err := rule.ParseInline([]byte(rules))
+	if err != nil {
+		r.t.Fatalf("parse rules: %v", err)
+	}
+	r.proc = NewProcessorFromConfig(config)
+	r.proc.SetCache(r.cache)
+}
+
+// process 处理一次，返回是否命中缓存
+func (r *cacheRun) process() bool {
+	result, err := r.proc.ProcessFile(r.in, r.out, false)
+	if err != nil {
+		r.t.Fatalf("process: %v", err)
+	}
+	return result.Cached
+}
+
+func (r *cacheRun) write(name, content string) {
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// 输入、规则、规则读到的环境变量、影响结果的选项或输出文件变化时缓存失效，其他情况命中
+func TestCacheInvalidation(t *testing.T) {
+	const rules = "- action: replace\n  path: replicas\n  value: 3\n"
+	const envRules = "- action: replace\n  path: image\n  value: nginx:${TAG}\n  interpolate: env\n"
+	tests := []struct {
+		name   string
+		rules  string
+		change func(r *cacheRun)
+		cached bool
+	}{
+		{"nothing changed", rules, func(r *cacheRun) {}, true},
+		{"input changed", rules, func(r *cacheRun) { r.write(r.in, "image: nginx\nreplicas: 2\n") }, false},
+		{"output edited", rules, func(r *cacheRun) { r.write(r.out, "replicas: 5\n") }, false},
+		{"output deleted", rules, func(r *cacheRun) { os.Remove(r.out) }, false},
+		{"rule changed", rules, func(r *cacheRun) { r.setRules("- action: replace\n  path: replicas\n  value: 4\n") }, false},
+		{"same rules reloaded", rules, func(r *cacheRun) { r.setRules(rules) }, true},
+		{"cache reloaded from disk", rules, func(r *cacheRun) {
+			if err := r.cache.Save(); err != nil {
+				r.t.Fatal(err)
+			}
+			cache, err := LoadCache(r.cache.path)
+			if err != nil {
+				r.t.Fatal(err)
+			}
+			r.cache = cache
+			r.proc.SetCache(cache)
+		}, true},
+		{"strict enabled", rules, func(r *cacheRun) { r.proc.SetOptions(Options{Strict: true}) }, false},
+		{"output format changed", rules, func(r *cacheRun) { r.proc.SetOptions(Options{Format: FormatK8s}) }, false},
+		{"backup enabled", rules, func(r *cacheRun) { r.proc.SetOptions(Options{Backup: true}) }, true},
+		{"env changed", envRules, func(r *cacheRun) { r.proc.SetEnviron(map[string]string{"TAG": "1.28"}) }, false},
+		{"env unchanged", envRules, func(r *cacheRun) { r.proc.SetEnviron(map[string]string{"TAG": "1.27", "OTHER": "x"}) }, true},
+		{"volatile template", "- action: replace\n  path: image\n  value: '{{ now.Year }}'\n", func(r *cacheRun) {}, false},
+	}
+	for _, tt := range tests {
+		r := newCacheRun(t, tt.rules)
+		r.proc.SetEnviron(map[string]string{"TAG": "1.27"})
+		if r.process() {
+			t.Fatalf("%s: first run hit the cache", tt.name)
+		}
+		tt.change(r)
+		if got := r.process(); got != tt.cached {
+			t.Errorf("%s: cached = %v, want %v", tt.name, got, tt.cached)
+		}
+	}
+}
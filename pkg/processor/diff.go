@@ -0,0 +1,177 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/engine"
+)
+
+// DiffFormat dry-run 时的预览格式
+type DiffFormat string
+
+const (
+	DiffNone       DiffFormat = ""             // 输出完整的处理结果（默认）
+	DiffUnified    DiffFormat = "unified"      // unified diff
+	DiffSideBySide DiffFormat = "side-by-side" // 左右两栏对照，同 diff -y
+	DiffJSON       DiffFormat = "json"         // 每个文件一行 JSON，便于工具消费
+)
+
+// sideBySideWidth side-by-side 每栏的宽度（字符数），超出部分截断
+const sideBySideWidth = 60
+
+// FileDiff 单个文件的变更，DiffJSON 格式下每个文件输出一行
+type FileDiff struct {
+	File   string     `json:"file"`
+	Output string     `json:"output"`
+	Hunks  []DiffHunk `json:"hunks"` // 内容无变化时为空
+
+	// Changes 按文档结构比较得到的值变化（见 engine.DiffNodes），只改注释或格式时为空
+	Changes []NodeChange `json:"changes,omitempty"`
+}
+
+// NodeChange 文档中一个节点的值变化，新增时没有 old，删除时没有 new
+type NodeChange struct {
+	Doc  int         `json:"doc"`  // 文档在文件中的序号
+	Path string      `json:"path"` // 具体路径，整个文档增删时为空
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// DiffHunk 一个变更块，行号从 1 开始
+// Lines 每行以 " "、"-"、"+" 开头，同 unified diff，不含换行符
+type DiffHunk struct {
+	OldStart int      `json:"old_start"`
+	OldLines int      `json:"old_lines"`
+	NewStart int      `json:"new_start"`
+	NewLines int      `json:"new_lines"`
+	Lines    []string `json:"lines"`
+}
+
+// formatDiff 按格式生成 dry-run 预览，内容相同时 unified 与 side-by-side 返回空串
+func formatDiff(format DiffFormat, inputPath, outputPath string, original, output []byte) (string, error) {
+	switch format {
+	case DiffUnified:
+		return unifiedDiff(reportPath(inputPath), reportPath(outputPath), original, output), nil
+	case DiffSideBySide:
+		return sideBySideDiff(reportPath(inputPath), reportPath(outputPath), original, output), nil
+	case DiffJSON:
+		data, err := json.Marshal(jsonDiff(inputPath, outputPath, original, output))
+		if err != nil {
+			return "", fmt.Errorf("marshal diff: %w", err)
+		}
+		return string(data) + "\n", nil
+	default:
+		return "", fmt.Errorf("unknown diff format %q, expected %s, %s or %s", format, DiffUnified, DiffSideBySide, DiffJSON)
+	}
+}
+
+// jsonDiff 生成结构化的文件变更
+func jsonDiff(inputPath, outputPath string, original, output []byte) *FileDiff {
+	d := &FileDiff{File: reportPath(inputPath), Output: reportPath(outputPath), Hunks: []DiffHunk{}}
+	edits := lineEdits(original, output)
+	for _, h := range hunkRanges(edits) {
+		dh := DiffHunk{}
+		dh.OldStart, dh.OldLines, dh.NewStart, dh.NewLines = hunkSpan(edits, h.lo, h.hi)
+		for _, e := range edits[h.lo:h.hi] {
+			dh.Lines = append(dh.Lines, e.kind.prefix()+strings.TrimSuffix(e.line, "\n"))
+		}
+		d.Hunks = append(d.Hunks, dh)
+	}
+	if len(d.Hunks) > 0 {
+		d.Changes = nodeChanges(original, output)
+	}
+	return d
+}
+
+// nodeChanges 逐文档比较原内容与处理结果，按下标对应文档；任一方无法解析时返回 nil
+func nodeChanges(original, output []byte) []NodeChange {
+	before, err := decodeDocuments(original)
+	if err != nil {
+		return nil
+	}
+	after, err := decodeDocuments(output)
+	if err != nil {
+		return nil
+	}
+
+	var changes []NodeChange
+	for i := range max(len(before), len(after)) {
+		var old, new *yaml.Node
+		if i < len(before) {
+			old = before[i]
+		}
+		if i < len(after) {
+			new = after[i]
+		}
+		for _, c := range engine.DiffNodes(old, new) {
+			changes = append(changes, NodeChange{Doc: i, Path: c.Path, Old: jsonValue(c.Old), New: jsonValue(c.New)})
+		}
+	}
+	return changes
+}
+
+// sideBySideDiff 左栏为原内容、右栏为新内容，按变更块输出
+// 中间标记："|" 修改、"<" 只在左栏、">" 只在右栏、空白为相同行
+func sideBySideDiff(fromName, toName string, from, to []byte) string {
+	edits := lineEdits(from, to)
+	hunks := hunkRanges(edits)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range hunks {
+		aLine, aCount, bLine, bCount := hunkSpan(edits, h.lo, h.hi)
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", aLine, aCount, bLine, bCount)
+
+		for i := h.lo; i < h.hi; {
+			if edits[i].kind == editEqual {
+				writeSideBySide(&buf, edits[i].line, " ", edits[i].line)
+				i++
+				continue
+			}
+			// 连续的删除与插入逐行配对为修改，多出的行单独成行
+			var dels, ins []string
+			for ; i < h.hi && edits[i].kind == editDelete; i++ {
+				dels = append(dels, edits[i].line)
+			}
+			for ; i < h.hi && edits[i].kind == editInsert; i++ {
+				ins = append(ins, edits[i].line)
+			}
+			for j := 0; j < max(len(dels), len(ins)); j++ {
+				switch {
+				case j >= len(ins):
+					writeSideBySide(&buf, dels[j], "<", "")
+				case j >= len(dels):
+					writeSideBySide(&buf, "", ">", ins[j])
+				default:
+					writeSideBySide(&buf, dels[j], "|", ins[j])
+				}
+			}
+		}
+	}
+	return buf.String()
+}
+
+// writeSideBySide 输出一行左右对照
+func writeSideBySide(buf *strings.Builder, left, mark, right string) {
+	left = fitColumn(left)
+	pad := sideBySideWidth - utf8.RuneCountInString(left)
+	line := fmt.Sprintf("%s%s %s %s", left, strings.Repeat(" ", pad), mark, fitColumn(right))
+	buf.WriteString(strings.TrimRight(line, " ") + "\n")
+}
+
+// fitColumn 去掉换行符并截断到栏宽，制表符按单个空格显示以保持对齐
+func fitColumn(line string) string {
+	line = strings.ReplaceAll(strings.TrimSuffix(line, "\n"), "\t", " ")
+	if utf8.RuneCountInString(line) <= sideBySideWidth {
+		return line
+	}
+	r := []rune(line)
+	return string(r[:sideBySideWidth-1]) + "…"
+}
@@ -0,0 +1,136 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/path"
+)
+
+// DuplicateKeys mapping 中出现重复键时的处理策略
+// 解析器不拒绝重复键，输出时所有重复的键值对原样保留，而读取清单的工具各取其一
+type DuplicateKeys string
+
+const (
+	DuplicateKeysWarn      DuplicateKeys = "warn"       // 输出警告，文档不变（默认）；Strict 时同 error
+	DuplicateKeysError     DuplicateKeys = "error"      // 当前文件失败
+	DuplicateKeysFirstWins DuplicateKeys = "first-wins" // 保留第一次出现的键值对，删除之后重复的
+	DuplicateKeysLastWins  DuplicateKeys = "last-wins"  // 保留最后一次出现的键值对，删除之前重复的
+)
+
+// checkDuplicateKeys 在执行规则前按 Options.DuplicateKeys 处理文档中的重复键
+// offset 为去掉的开头注释块的行数，使报告的行号与文件一致；warn 策略的警告记入 result
+func (p *Processor) checkDuplicateKeys(file string, docs []*yaml.Node, offset int, result *FileResult) error {
+	policy := p.opts.DuplicateKeys
+	if policy == "" {
+		policy = DuplicateKeysWarn
+	}
+	if policy == DuplicateKeysWarn && p.opts.Strict {
+		policy = DuplicateKeysError
+	}
+
+	for i, root := range docs {
+		c := &dupChecker{policy: policy, offset: offset, seen: map[*yaml.Node]bool{}}
+		c.walk(root, "")
+		for _, d := range c.found {
+			if len(docs) > 1 {
+				d = fmt.Sprintf("document %d: %s", i, d)
+			}
+			switch policy {
+			case DuplicateKeysError:
+				msg := d
+				if p.opts.Strict && p.opts.DuplicateKeys != DuplicateKeysError {
+					msg += " (strict)"
+				}
+				return fmt.Errorf("%s", msg)
+			case DuplicateKeysWarn:
+				p.warn(result, "%s: %s", reportPath(file), d)
+			}
+		}
+	}
+	return nil
+}
+
+// dupChecker 单个文档的重复键检查
+type dupChecker struct {
+	policy DuplicateKeys
+	offset int
+	seen   map[*yaml.Node]bool // 已检查的节点，锚点只检查一次
+	found  []string            // "路径: 说明"
+}
+
+// walk 检查 node 下的所有 mapping，first-wins/last-wins 时删除被舍弃的键值对
+func (c *dupChecker) walk(node *yaml.Node, p string) {
+	if c.seen[node] || node.Kind == yaml.AliasNode {
+		return
+	}
+	c.seen[node] = true
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			c.walk(child, p)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			c.walk(child, path.ElemPath(p, i))
+		}
+	case yaml.MappingNode:
+		drop := c.duplicates(node, p)
+		if len(drop) > 0 {
+			content := node.Content[:0:0]
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if !drop[i] {
+					content = append(content, node.Content[i], node.Content[i+1])
+				}
+			}
+			node.Content = content
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			c.walk(node.Content[i+1], path.FieldPath(p, node.Content[i].Value))
+		}
+	}
+}
+
+// duplicates 记录 mapping 中的重复键，返回按策略应删除的键值对（键的下标）
+// 只比较标量键，合并键 << 不算重复
+func (c *dupChecker) duplicates(node *yaml.Node, p string) map[int]bool {
+	occurrences := map[string][]int{}
+	var keys []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if key.Kind != yaml.ScalarNode || key.ShortTag() == "!!merge" {
+			continue
+		}
+		if occurrences[key.Value] == nil {
+			keys = append(keys, key.Value)
+		}
+		occurrences[key.Value] = append(occurrences[key.Value], i)
+	}
+
+	drop := map[int]bool{}
+	for _, k := range keys {
+		idx := occurrences[k]
+		if len(idx) < 2 {
+			continue
+		}
+		lines := make([]string, len(idx))
+		for j, i := range idx {
+			lines[j] = fmt.Sprint(node.Content[i].Line + c.offset)
+		}
+		c.found = append(c.found, fmt.Sprintf("%s: duplicate key %q at lines %s", path.FieldPath(p, k), k, strings.Join(lines, ", ")))
+
+		switch c.policy {
+		case DuplicateKeysFirstWins:
+			for _, i := range idx[1:] {
+				drop[i] = true
+			}
+		case DuplicateKeysLastWins:
+			for _, i := range idx[:len(idx)-1] {
+				drop[i] = true
+			}
+		}
+	}
+	return drop
+}
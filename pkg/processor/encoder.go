@@ -0,0 +1,192 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Encoder 将规则处理后的文档序列化为输出内容
+type Encoder interface {
+	// Format 输出格式名，参与增量缓存的摘要，格式变化时缓存失效
+	Format() string
+	// Encode 按顺序序列化文件中的所有文档，original 为去除 BOM 后的原始输入
+	Encode(docs []*yaml.Node, original []byte) ([]byte, error)
+}
+
+// 内置输出格式
+const (
+	FormatPreserve = "preserve" // YAML，保留原文的空行与独立注释（默认）
+	FormatK8s      = "k8s"      // YAML，顶层字段按 Kubernetes 惯例排序，不保留原文排版
+	FormatJSON     = "json"     // 缩进 JSON
+)
+
+// NewEncoder 按格式名创建内置编码器
+func NewEncoder(format string) (Encoder, error) {
+	switch format {
+	case "", FormatPreserve:
+		return preserveEncoder{}, nil
+	case FormatK8s:
+		return k8sEncoder{}, nil
+	case FormatJSON:
+		return jsonEncoder{}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q, expected %s, %s or %s", format, FormatPreserve, FormatK8s, FormatJSON)
+	}
+}
+
+// SetEncoder 设置输出编码器，nil 表示默认的 preserve
+func (p *Processor) SetEncoder(enc Encoder) {
+	p.encoder = enc
+}
+
+// outputEncoder 返回当前生效的编码器
+func (p *Processor) outputEncoder() Encoder {
+	if p.encoder == nil {
+		return preserveEncoder{}
+	}
+	return p.encoder
+}
+
+// encodeYAML 以 indent 个空格缩进序列化，文档间以 --- 分隔
+// 空文档只输出分隔符，yaml.v3 会把它编码为一个空行
+func encodeYAML(docs []*yaml.Node, indent int) ([]byte, error) {
+	var buf strings.Builder
+	for i, root := range docs {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		if isEmptyDocument(root) {
+			continue
+		}
+
+		// yaml.v3 会把解析得到的合并键输出为 "!!merge <<"，清除标签后按原样输出 "<<"
+		clearMergeTags(root)
+
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(indent)
+		if err := encoder.Encode(root); err != nil {
+			return nil, fmt.Errorf("marshal yaml: %w", err)
+		}
+		encoder.Close()
+	}
+	return []byte(buf.String()), nil
+}
+
+// preserveEncoder indent 为 0 时沿用原文的缩进
+type preserveEncoder struct {
+	indent int
+}
+
+func (preserveEncoder) Format() string { return FormatPreserve }
+
+func (e preserveEncoder) Encode(docs []*yaml.Node, original []byte) ([]byte, error) {
+	indent := e.indent
+	if indent <= 0 {
+		indent = detectIndent(original)
+	}
+	output, err := encodeYAML(docs, indent)
+	if err != nil {
+		return nil, err
+	}
+	return restoreLayout(original, output), nil
+}
+
+// k8sFieldOrder Kubernetes 清单顶层字段的惯用顺序，其余字段保持原顺序排在之后
+var k8sFieldOrder = []string{"apiVersion", "kind", "metadata", "spec", "data", "stringData", "status"}
+
+// k8sEncoder indent 为 0 时缩进 2 个空格
+type k8sEncoder struct {
+	indent int
+}
+
+func (k8sEncoder) Format() string { return FormatK8s }
+
+func (e k8sEncoder) Encode(docs []*yaml.Node, original []byte) ([]byte, error) {
+	for _, root := range docs {
+		node := root
+		if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+			node = node.Content[0]
+		}
+		if node.Kind == yaml.MappingNode {
+			node.Content = sortFields(node.Content, k8sFieldOrder)
+		}
+	}
+	return encodeYAML(docs, max(e.indent, 2))
+}
+
+// sortFields 按 order 重排 mapping 的键值对，不在 order 中的键保持原顺序排在最后
+func sortFields(content []*yaml.Node, order []string) []*yaml.Node {
+	sorted := make([]*yaml.Node, 0, len(content))
+	used := make([]bool, len(content)/2)
+	for _, key := range order {
+		for i := 0; i+1 < len(content); i += 2 {
+			if !used[i/2] && content[i].Value == key {
+				sorted = append(sorted, content[i], content[i+1])
+				used[i/2] = true
+			}
+		}
+	}
+	for i := 0; i+1 < len(content); i += 2 {
+		if !used[i/2] {
+			sorted = append(sorted, content[i], content[i+1])
+		}
+	}
+	return sorted
+}
+
+// jsonEncoder 缩进 JSON，indent 为 0 时沿用 JSON 原文的缩进
+type jsonEncoder struct {
+	indent int
+}
+
+func (jsonEncoder) Format() string { return FormatJSON }
+
+// Encode 每个文档输出一个 JSON 值，多个文档间以 --- 分隔（仍是合法的 YAML），空文档省略
+func (e jsonEncoder) Encode(docs []*yaml.Node, original []byte) ([]byte, error) {
+	indent := strings.Repeat(" ", e.indent)
+	if e.indent <= 0 {
+		indent = detectJSONIndent(original)
+	}
+
+	var buf bytes.Buffer
+	for _, root := range docs {
+		if isEmptyDocument(root) {
+			continue
+		}
+		if buf.Len() > 0 {
+			buf.WriteString("---\n")
+		}
+		if err := writeJSON(&buf, root, indent, 0); err != nil {
+			return nil, fmt.Errorf("marshal json: %w", err)
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonValue 将 yaml 解码出的非字符串键 map 转为字符串键，JSON 对象键只能是字符串
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = jsonValue(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[fmt.Sprint(k)] = jsonValue(item)
+		}
+		return out
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonValue(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,20 @@
+package processor
+
+import "fmt"
+
+// Eval 对内存中的 YAML 内容应用规则并返回结果，不读写任何文件
+// name 仅用于模板中的 .File 与错误信息
+func (p *Processor) Eval(name string, data []byte) ([]byte, *FileResult, error) {
+	params, err := p.currentParams()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rules := p.currentRules()
+	result := &FileResult{Rules: make([]RuleStats, len(rules))}
+	output, err := p.transform(data, name, rules, params, result)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return output, result, nil
+}
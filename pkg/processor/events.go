@@ -0,0 +1,82 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/glesirok/yamleditor/pkg/engine"
+)
+
+// Event 一次节点修改事件，以 NDJSON 每行一个写出
+type Event struct {
+	File   string            `json:"file"`
+	Doc    int               `json:"doc"`  // 文档在文件中的序号
+	Rule   int               `json:"rule"` // 规则序号
+	RuleID string            `json:"rule_id,omitempty"`
+	Action engine.ActionType `json:"action"`
+	Path   string            `json:"path"` // 被修改节点的具体路径
+	Old    interface{}       `json:"old,omitempty"`
+	New    interface{}       `json:"new,omitempty"`
+
+	Description string `json:"description,omitempty"` // 规则的 description
+}
+
+// SetEvents 设置修改事件的输出，每条规则执行完即写出该规则产生的事件；nil 表示关闭
+// 被 OnRuleApplied 否决的修改不产生事件
+func (p *Processor) SetEvents(w io.Writer) {
+	if w == nil {
+		p.SetEventHandler(nil)
+		return
+	}
+	enc := json.NewEncoder(w)
+	p.SetEventHandler(func(ev Event) error {
+		if err := enc.Encode(ev); err != nil {
+			return fmt.Errorf("write event: %w", err)
+		}
+		return nil
+	})
+}
+
+// SetEventHandler 设置修改事件的回调，与 SetEvents 互相覆盖；nil 表示关闭
+// 回调返回错误时当前文件按失败处理
+func (p *Processor) SetEventHandler(fn func(Event) error) {
+	p.events = fn
+	p.trackChanges()
+}
+
+// trackChanges 有事件回调或需要记录修改时让引擎收集逐节点修改
+func (p *Processor) trackChanges() {
+	p.engine.SetTrackChanges(p.events != nil || p.opts.RecordChanges)
+}
+
+// emit 报告一条规则在文档上产生的修改事件，RecordChanges 时同时记入 result
+func (p *Processor) emit(doc *Document, ruleIdx int, r *engine.Rule, changes []engine.Change, result *FileResult) error {
+	if p.events == nil && !p.opts.RecordChanges {
+		return nil
+	}
+	for _, c := range changes {
+		ev := Event{
+			File:   reportPath(doc.File),
+			Doc:    doc.Index,
+			Rule:   ruleIdx,
+			RuleID: r.ID,
+			Action: r.Action,
+			Path:   c.Path,
+			Old:    c.Old,
+			New:    c.New,
+
+			Description: r.Description,
+		}
+		if p.opts.RecordChanges {
+			result.Changes = append(result.Changes, ev)
+		}
+		if p.events == nil {
+			continue
+		}
+		if err := p.events(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// ReadFileList 读取文件路径列表：含 NUL 时按 NUL 分隔（git diff -z、find -print0），
+// 否则按行分隔；忽略空项与行尾的 \r
+func ReadFileList(r io.Reader) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read file list: %w", err)
+	}
+
+	sep := []byte("\n")
+	if bytes.IndexByte(data, 0) >= 0 {
+		sep = []byte{0}
+	}
+	var files []string
+	for _, item := range bytes.Split(data, sep) {
+		name := string(item)
+		if len(sep) == 1 && sep[0] == '\n' {
+			name = strings.TrimSuffix(name, "\r")
+		}
+		if name != "" {
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+// ProcessFiles 批量处理列表中的文件，结果与报告同 ProcessDirectory
+// 只处理 .yaml/.yml 文件（Format 为 json 时只处理 .json 文件）且在 Include/Exclude 范围内的文件，重复的路径只处理一次；outputDir 为空时原地修改，
+// 否则输出到 outputDir 下与输入相同的相对路径，此时输入须为不含 .. 的相对路径，
+// 位于输出目录中的输入会被跳过
+func (p *Processor) ProcessFiles(files []string, outputDir string, dryRun, backup bool) (*ProcessResult, error) {
+	result := &ProcessResult{}
+
+	skipDir, err := outputSubtree(".", outputDir)
+	if err != nil {
+		return result, err
+	}
+
+	var entries []batchEntry
+	seen := map[string]bool{}
+	for _, file := range files {
+		file = filepath.Clean(file)
+		if !p.opts.accepts(file) || seen[file] {
+			continue
+		}
+		included, err := p.opts.included(file)
+		if err != nil {
+			return result, err
+		}
+		if !included {
+			continue
+		}
+		seen[file] = true
+		if skipDir != "" && (file == skipDir || strings.HasPrefix(file, skipDir+string(filepath.Separator))) {
+			continue
+		}
+
+		result.TotalFiles++
+
+		outputPath := file // 原地修改
+		if outputDir != "" {
+			if filepath.IsAbs(file) || file == ".." || strings.HasPrefix(file, ".."+string(filepath.Separator)) {
+				if p.fail(result, file, fmt.Errorf("%s is outside the current directory, cannot map it into the output directory", reportPath(file))) != nil {
+					return result, nil
+				}
+				continue
+			}
+			outputPath = filepath.Join(outputDir, file)
+		}
+		entries = append(entries, batchEntry{file, outputPath})
+	}
+
+	if err := p.runBatch(result, entries, dryRun, backup); err != nil {
+		return result, err
+	}
+	return result, nil
+}
@@ -0,0 +1,82 @@
+package processor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// matchGlob 判断 / 分隔的相对路径是否匹配模式：** 匹配任意层目录（含零层），其余片段按 filepath.Match 匹配；
+// 模式中没有 / 时与 .gitignore 相同，匹配任意深度的同名文件或目录，如 *.gen.yaml。
+// 模式中的路径分隔符先统一为 /，Windows 上 charts\*.yaml 与 charts/*.yaml 等价；模式语法错误时返回错误
+func matchGlob(pattern, name string) (bool, error) {
+	pattern = filepath.ToSlash(pattern)
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	parts := strings.Split(pattern, "/")
+	for _, part := range parts {
+		if part == "**" {
+			continue
+		}
+		// 逐片段匹配可能在到达后面的片段之前就失败，先校验全部片段，保证语法错误总能报告
+		if _, err := filepath.Match(part, ""); err != nil {
+			return false, err
+		}
+	}
+	return matchParts(parts, strings.Split(name, "/")), nil
+}
+
+// matchParts 逐片段匹配，** 依次尝试吞掉 0 到全部剩余片段；片段已由 matchGlob 校验
+func matchParts(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchParts(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchAny 判断路径是否匹配任一模式，模式语法错误时返回错误
+func matchAny(patterns []string, name string) (bool, error) {
+	name = filepath.ToSlash(name)
+	for _, pattern := range patterns {
+		ok, err := matchGlob(pattern, name)
+		if err != nil {
+			return false, fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// included 判断批量处理时是否处理相对路径为 rel 的文件：Include 非空时须匹配其中之一，且不匹配 Exclude
+func (o Options) included(rel string) (bool, error) {
+	if len(o.Include) > 0 {
+		ok, err := matchAny(o.Include, rel)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	excluded, err := matchAny(o.Exclude, rel)
+	return !excluded, err
+}
+
+// excludedDir 判断相对路径为 rel 的目录是否被 Exclude 整个排除，如 **/charts/** 排除 charts 目录
+func (o Options) excludedDir(rel string) (bool, error) {
+	return matchAny(o.Exclude, rel)
+}
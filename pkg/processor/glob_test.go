@@ -0,0 +1,38 @@
+package processor
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.gen.yaml", "a/b/x.gen.yaml", true},
+		{"charts/*.yaml", "charts/app.yaml", true},
+		{"charts/*.yaml", "charts/sub/app.yaml", false},
+		{"**/charts/**", "a/charts/b/c.yaml", true},
+		{filepath.FromSlash("charts/*.yaml"), "charts/app.yaml", true}, // 系统分隔符写法
+		{filepath.FromSlash("a/**/c.yaml"), "a/b/c.yaml", true},
+	}
+	for _, tt := range tests {
+		got, err := matchGlob(tt.pattern, tt.name)
+		if err != nil {
+			t.Errorf("matchGlob(%q, %q) error = %v", tt.pattern, tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+// 语法错误的模式总是报错，即使匹配在到达错误片段之前就已失败
+func TestMatchGlobMalformed(t *testing.T) {
+	for _, pattern := range []string{"[", "a/[", "other/**/x[", "*.yaml/["} {
+		if _, err := matchAny([]string{pattern}, "a/b.yaml"); err == nil {
+			t.Errorf("matchAny(%q) error = nil, want ErrBadPattern", pattern)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/glesirok/yamleditor/pkg/engine"
+)
+
+// splitHeader 分离文件开头的注释块（shebang、许可证声明等），返回注释块与其余内容
+// 注释块取开头连续的注释/空行中最后一个空行为止的部分，其后紧跟 --- 或到达文件结尾时取全部；
+// 紧贴内容的注释属于第一个节点，不计入注释块。首行为 #! 时注释块至少包含该行
+// 注释块不经过 YAML 解析，原样保留，不会因修改或删除第一个节点而丢失
+func splitHeader(data []byte) (header, body []byte) {
+	end := 0
+	if bytes.HasPrefix(data, []byte("#!")) {
+		end = len(data)
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			end = i + 1
+		}
+	}
+
+	for offset := 0; offset < len(data); {
+		line := data[offset:]
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i+1]
+		}
+		trimmed := bytes.TrimSpace(line)
+		switch {
+		case len(trimmed) == 0:
+			end = offset + len(line)
+		case trimmed[0] == '#':
+		case bytes.Equal(trimmed, []byte("---")) || bytes.HasPrefix(trimmed, []byte("--- ")):
+			return data[:offset], data[offset:]
+		default:
+			return data[:end], data[end:]
+		}
+		offset += len(line)
+	}
+	return data, nil
+}
+
+// setHeader 用 text 替换注释块，保留 shebang 行
+// text 中不以 # 开头的行自动加上 "# "，注释块与内容之间保留一个空行
+func setHeader(header []byte, text string) []byte {
+	var buf bytes.Buffer
+	if bytes.HasPrefix(header, []byte("#!")) {
+		shebang, _, _ := bytes.Cut(header, []byte("\n"))
+		buf.Write(shebang)
+		buf.WriteByte('\n')
+	}
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		switch {
+		case line == "":
+			buf.WriteString("#")
+		case !strings.HasPrefix(line, "#"):
+			buf.WriteString("# ")
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// applyHeaderRules 依次执行 set_header 规则，返回新的注释块
+func (p *Processor) applyHeaderRules(rules []*engine.Rule, file string, header []byte, result *FileResult) ([]byte, error) {
+	for i, r := range rules {
+		if r.Action != engine.ActionSetHeader || !p.opts.selected(r) {
+			continue
+		}
+		if len(r.Files) > 0 {
+			ok, err := matchAny(r.Files, file)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d, files: %w", i, err)
+			}
+			if !ok {
+				continue
+			}
+		}
+		text, _ := r.Value.(string)
+		updated := setHeader(header, text)
+
+		stats := RuleStats{Rule: r, FilesMatched: 1, NodesMatched: 1}
+		if !bytes.Equal(updated, header) {
+			stats.NodesChanged = 1
+			change := engine.Change{Old: string(header), New: string(updated)}
+			if err := p.emit(&Document{File: file}, i, r, []engine.Change{change}, result); err != nil {
+				return nil, err
+			}
+		}
+		result.Rules[i].add(stats)
+		header = updated
+	}
+	return header, nil
+}
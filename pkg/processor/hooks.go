@@ -0,0 +1,42 @@
+package processor
+
+import (
+	"errors"
+
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/engine"
+)
+
+// ErrVeto OnRuleApplied 返回该错误时撤销本条规则对文档的修改并继续处理
+var ErrVeto = errors.New("change vetoed")
+
+// Hooks 文档处理过程的回调，字段为 nil 时跳过
+// 回调返回 ErrVeto 以外的错误时，当前文件按失败处理
+type Hooks struct {
+	// OnDocumentStart 在应用规则前调用
+	OnDocumentStart func(doc *Document) error
+	// OnRuleApplied 在每条规则应用后调用，返回 ErrVeto 撤销该规则的修改
+	OnRuleApplied func(change *RuleChange) error
+	// OnDocumentEnd 在所有规则应用后、序列化前调用
+	OnDocumentEnd func(doc *Document, result *FileResult) error
+}
+
+// Document 正在处理的文档
+type Document struct {
+	File  string
+	Index int        // 文档在文件中的序号，从 0 开始
+	Root  *yaml.Node // 文档根节点，回调中可读可改
+}
+
+// RuleChange 一条规则在文档上的执行情况
+type RuleChange struct {
+	Document  *Document
+	RuleIndex int
+	Rule      *engine.Rule
+	Result    *engine.Result
+}
+
+// SetHooks 设置处理回调
+func (p *Processor) SetHooks(h Hooks) {
+	p.hooks = h
+}
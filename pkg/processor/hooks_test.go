@@ -0,0 +1,34 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/glesirok/yamleditor/pkg/rule"
+)
+
+// 否决 capture 规则后，后续规则看不到被撤销的变量
+func TestVetoRestoresVars(t *testing.T) {
+	config, err := rule.ParseInline([]byte(`
+- {action: replace, path: image, value: nginx, capture: img}
+- {action: replace, path: tag, value: v3, capture: img}
+- {action: replace, path: out, value: "{{ .Vars.img }}"}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc := NewProcessorFromConfig(config)
+	proc.SetHooks(Hooks{OnRuleApplied: func(change *RuleChange) error {
+		if change.RuleIndex == 1 {
+			return ErrVeto
+		}
+		return nil
+	}})
+	output, _, err := proc.Eval("input.yaml", []byte("image: nginx\ntag: v2\nout: x\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 第二条规则的修改与它捕获的 img 一起被撤销，out 取第一条规则捕获的值
+	if want := "image: nginx\ntag: v2\nout: nginx\n"; string(output) != want {
+		t.Errorf("output =\n%s\nwant\n%s", output, want)
+	}
+}
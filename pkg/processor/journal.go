@@ -0,0 +1,241 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/engine"
+)
+
+// 变更日志目录中每次执行的文件
+const (
+	journalManifest = "manifest.json" // 执行时间、规则文件与写入的文件列表
+	journalRules    = "rules"         // 本次执行的规则（YAML，展开 kinds、groups 后），不带扩展名，日志目录位于输入目录中时不会被当作输入
+	journalFiles    = "files"         // 写入前的文件内容，按写入顺序编号
+)
+
+// Journal 变更日志：每次执行在日志目录下建立一个子目录，记录每个被写入文件写入前的内容与本次执行的规则，
+// Undo 据此把文件恢复到执行前的状态；没有写入任何文件的执行不留下记录
+type Journal struct {
+	root     string
+	dir      string // 本次执行的子目录，写入第一个文件时创建
+	manifest JournalRun
+}
+
+// JournalRun 一次执行的记录
+type JournalRun struct {
+	ID       string         `json:"-"` // 子目录名
+	Time     time.Time      `json:"time"`
+	RuleFile string         `json:"rule_file,omitempty"`
+	Files    []JournalEntry `json:"files"`
+}
+
+// JournalEntry 一次写入的记录
+type JournalEntry struct {
+	Path       string `json:"path"`             // 写入的文件，绝对路径
+	Backup     string `json:"backup,omitempty"` // 写入前的内容在 files 目录中的文件名，空表示文件由本次执行创建
+	OutputHash string `json:"output_hash"`      // 写入内容的 sha256，撤销前核对文件之后未被改动
+}
+
+// OpenJournal 使用 dir 作为变更日志目录，目录不存在时在第一次写入时创建
+func OpenJournal(dir string) (*Journal, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("journal dir: %w", err)
+	}
+	return &Journal{root: root}, nil
+}
+
+// SetJournal 设置变更日志，nil 表示不记录
+// 每个写入的输出文件在写入前把原内容记入日志；dry-run 与内容未变化的文件不记录
+func (p *Processor) SetJournal(j *Journal) {
+	p.journal = j
+}
+
+// record 在写入 name 之前记录其当前内容，rules 为本次执行的规则，第一次记录时写入日志
+func (j *Journal) record(name string, output []byte, ruleFile string, rules []*engine.Rule) error {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return err
+	}
+	if j.dir == "" {
+		if err := j.begin(ruleFile, rules); err != nil {
+			return err
+		}
+	}
+
+	entry := JournalEntry{Path: abs, OutputHash: digest(output)}
+	original, err := os.ReadFile(abs)
+	switch {
+	case err == nil:
+		entry.Backup = strconv.Itoa(len(j.manifest.Files))
+		if err := writeAtomic(filepath.Join(j.dir, journalFiles, entry.Backup), original); err != nil {
+			return err
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+
+	j.manifest.Files = append(j.manifest.Files, entry)
+	return j.save()
+}
+
+// begin 创建本次执行的子目录并写入规则；目录名以时间开头，按名称排序即按执行先后排序
+func (j *Journal) begin(ruleFile string, rules []*engine.Rule) error {
+	if err := os.MkdirAll(j.root, 0755); err != nil {
+		return err
+	}
+	now := time.Now()
+	dir, err := os.MkdirTemp(j.root, now.UTC().Format("20060102T150405.000Z")+"-")
+	if err != nil {
+		return err
+	}
+	if err := os.Mkdir(filepath.Join(dir, journalFiles), 0755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(struct {
+		Rules []*engine.Rule `yaml:"rules"`
+	}{rules})
+	if err != nil {
+		return fmt.Errorf("marshal rules: %w", err)
+	}
+	if err := writeAtomic(filepath.Join(dir, journalRules), data); err != nil {
+		return err
+	}
+	if ruleFile != "" {
+		if ruleFile, err = filepath.Abs(ruleFile); err != nil {
+			return err
+		}
+	}
+	j.dir = dir
+	j.manifest = JournalRun{ID: filepath.Base(dir), Time: now, RuleFile: ruleFile}
+	return nil
+}
+
+// save 写回清单，每记录一个文件写一次，执行中途退出时已写入的文件仍可撤销
+func (j *Journal) save() error {
+	data, err := json.MarshalIndent(j.manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal journal: %w", err)
+	}
+	return writeAtomic(filepath.Join(j.dir, journalManifest), data)
+}
+
+// JournalRuns 按执行先后列出日志目录中的记录，目录不存在时返回空列表
+func JournalRuns(dir string) ([]JournalRun, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read journal: %w", err)
+	}
+
+	var runs []JournalRun
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name(), journalManifest))
+		if errors.Is(err, fs.ErrNotExist) {
+			// 尚未写入任何文件就退出的执行
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read journal: %w", err)
+		}
+		run := JournalRun{ID: e.Name()}
+		if err := json.Unmarshal(data, &run); err != nil {
+			return nil, fmt.Errorf("parse journal %s: %w", e.Name(), err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// ErrJournalConflict 撤销时文件在执行之后又被改动过
+var ErrJournalConflict = errors.New("files changed since the run")
+
+// Undo 撤销日志目录中最近的一次执行：被修改的文件恢复为执行前的内容，执行中新建的文件删除，
+// 完成后删除该次记录，再次调用撤销更早的一次。返回被撤销的记录，Files 中每个文件只保留一项
+// 任一文件在执行之后又被改动时不恢复任何文件，返回 ErrJournalConflict；force 为 true 时仍然覆盖
+func Undo(dir string, force bool) (*JournalRun, error) {
+	runs, err := JournalRuns(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("no runs recorded in %s", dir)
+	}
+	run := runs[len(runs)-1]
+	runDir := filepath.Join(dir, run.ID)
+
+	// 同一文件被写入多次时，恢复为第一次写入前的内容，核对最后一次写入的内容
+	first := map[string]JournalEntry{}
+	last := map[string]JournalEntry{}
+	var order []string
+	for _, e := range run.Files {
+		if _, ok := first[e.Path]; !ok {
+			first[e.Path] = e
+			order = append(order, e.Path)
+		}
+		last[e.Path] = e
+	}
+
+	if !force {
+		var changed []string
+		for _, name := range order {
+			data, err := os.ReadFile(name)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			if err != nil || digest(data) != last[name].OutputHash {
+				changed = append(changed, name)
+			}
+		}
+		if len(changed) > 0 {
+			return nil, fmt.Errorf("undo %s: %w: %s", run.ID, ErrJournalConflict, strings.Join(changed, ", "))
+		}
+	}
+
+	for _, name := range order {
+		e := first[name]
+		if e.Backup == "" {
+			if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("undo %s: %w", run.ID, err)
+			}
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(runDir, journalFiles, e.Backup))
+		if err != nil {
+			return nil, fmt.Errorf("undo %s: %w", run.ID, err)
+		}
+		if current, err := os.ReadFile(name); err == nil && bytes.Equal(current, data) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return nil, fmt.Errorf("undo %s: %w", run.ID, err)
+		}
+		if err := writeAtomic(name, data); err != nil {
+			return nil, fmt.Errorf("undo %s: %w", run.ID, err)
+		}
+	}
+
+	run.Files = nil
+	for _, name := range order {
+		run.Files = append(run.Files, first[name])
+	}
+	if err := os.RemoveAll(runDir); err != nil {
+		return nil, fmt.Errorf("remove journal %s: %w", run.ID, err)
+	}
+	return &run, nil
+}
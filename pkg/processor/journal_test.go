@@ -0,0 +1,155 @@
+package processor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeJournaled 按处理器写出文件的顺序记录并写入：先记入日志，再写文件
+func writeJournaled(t *testing.T, j *Journal, name, content string) {
+	t.Helper()
+	if err := j.record(name, []byte(content), "", nil); err != nil {
+		t.Fatalf("record %s: %v", name, err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	tests := []struct {
+		name     string
+		run      func(j *Journal, dir string) // 一次执行中写入的文件
+		after    map[string]string            // 执行之后又改动的文件
+		force    bool
+		conflict bool
+		want     map[string]string // 撤销后的文件内容，空字符串表示文件不存在
+	}{
+		{
+			name: "restore modified and remove created",
+			run: func(j *Journal, dir string) {
+				writeJournaled(t, j, filepath.Join(dir, "a.yaml"), "a: 2\n")
+				writeJournaled(t, j, filepath.Join(dir, "new.yaml"), "n: 1\n")
+			},
+			want: map[string]string{"a.yaml": "a: 1\n", "b.yaml": "b: 1\n", "new.yaml": ""},
+		},
+		{
+			name: "written twice restores the first backup",
+			run: func(j *Journal, dir string) {
+				writeJournaled(t, j, filepath.Join(dir, "a.yaml"), "a: 2\n")
+				writeJournaled(t, j, filepath.Join(dir, "a.yaml"), "a: 3\n")
+			},
+			want: map[string]string{"a.yaml": "a: 1\n"},
+		},
+		{
+			name: "changed after the run",
+			run: func(j *Journal, dir string) {
+				writeJournaled(t, j, filepath.Join(dir, "a.yaml"), "a: 2\n")
+				writeJournaled(t, j, filepath.Join(dir, "b.yaml"), "b: 2\n")
+			},
+			after:    map[string]string{"b.yaml": "b: edited\n"},
+			conflict: true,
+			want:     map[string]string{"a.yaml": "a: 2\n", "b.yaml": "b: edited\n"},
+		},
+		{
+			name: "created file removed after the run",
+			run: func(j *Journal, dir string) {
+				writeJournaled(t, j, filepath.Join(dir, "new.yaml"), "n: 1\n")
+			},
+			after:    map[string]string{"new.yaml": ""},
+			conflict: true,
+			want:     map[string]string{"new.yaml": ""},
+		},
+		{
+			name: "force overwrites later changes",
+			run: func(j *Journal, dir string) {
+				writeJournaled(t, j, filepath.Join(dir, "a.yaml"), "a: 2\n")
+				writeJournaled(t, j, filepath.Join(dir, "new.yaml"), "n: 1\n")
+			},
+			after: map[string]string{"a.yaml": "a: edited\n", "new.yaml": "n: edited\n"},
+			force: true,
+			want:  map[string]string{"a.yaml": "a: 1\n", "new.yaml": ""},
+		},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		journalDir := filepath.Join(dir, ".journal")
+		for name, content := range map[string]string{"a.yaml": "a: 1\n", "b.yaml": "b: 1\n"} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		j, err := OpenJournal(journalDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.run(j, dir)
+		for name, content := range tt.after {
+			p := filepath.Join(dir, name)
+			if content == "" {
+				err = os.Remove(p)
+			} else {
+				err = os.WriteFile(p, []byte(content), 0644)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, err = Undo(journalDir, tt.force)
+		if tt.conflict != errors.Is(err, ErrJournalConflict) || (!tt.conflict && err != nil) {
+			t.Errorf("%s: Undo error = %v, want conflict %v", tt.name, err, tt.conflict)
+		}
+		for name, want := range tt.want {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if want == "" && !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("%s: %s should not exist, got %q, %v", tt.name, name, data, err)
+			}
+			if want != "" && string(data) != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, name, data, want)
+			}
+		}
+		// 撤销成功后删除记录，冲突时保留以便解决后重试
+		runs, err := JournalRuns(journalDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wantRuns := map[bool]int{true: 1, false: 0}[tt.conflict]; len(runs) != wantRuns {
+			t.Errorf("%s: %d runs left, want %d", tt.name, len(runs), wantRuns)
+		}
+	}
+}
+
+// 每次撤销最近的一次执行，再次撤销更早的一次
+func TestUndoOrder(t *testing.T) {
+	dir := t.TempDir()
+	journalDir := filepath.Join(dir, ".journal")
+	name := filepath.Join(dir, "a.yaml")
+	if err := os.WriteFile(name, []byte("v: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, content := range []string{"v: 2\n", "v: 3\n"} {
+		j, err := OpenJournal(journalDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeJournaled(t, j, name, content)
+		// 记录目录名精确到毫秒
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	for _, want := range []string{"v: 2\n", "v: 1\n"} {
+		if _, err := Undo(journalDir, false); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := os.ReadFile(name); string(data) != want {
+			t.Errorf("after undo: %q, want %q", data, want)
+		}
+	}
+	if _, err := Undo(journalDir, false); err == nil {
+		t.Error("undo with no runs left: expected error")
+	}
+}
@@ -0,0 +1,243 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 输入文件格式，见 Options.Format
+const (
+	FormatAuto = "auto" // 按扩展名与内容识别（默认）
+	FormatYAML = "yaml"
+)
+
+// jsonInput 判断输入是否按 JSON 处理（JSON 也是合法的 YAML，解析与规则执行不变，只影响输出格式）：
+// 指定 json 格式或扩展名为 .json 时内容须是合法的 JSON；自动识别时内容以 { 或 [ 开头且是合法的 JSON
+func (o Options) jsonInput(file string, data []byte) (bool, error) {
+	switch {
+	case o.Format == FormatYAML:
+		return false, nil
+	case o.Format == FormatJSON || strings.EqualFold(filepath.Ext(file), ".json"):
+		if err := json.Unmarshal(data, new(json.RawMessage)); err != nil {
+			return false, fmt.Errorf("parse json: %w", err)
+		}
+		return true, nil
+	}
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(data), nil
+}
+
+// accepts 判断批量处理时是否处理该文件：指定 json 格式时处理 .json 文件，否则处理 .yaml/.yml 文件
+func (o Options) accepts(path string) bool {
+	if o.Format == FormatJSON {
+		return strings.HasSuffix(path, ".json")
+	}
+	return isYAML(path)
+}
+
+// encoderFor 返回处理一个文件使用的编码器：JSON 输入在默认的 preserve 格式下仍输出 JSON，
+// JSON 输出使用 Options.JSONIndent 指定的缩进，内置 YAML 格式使用 Options.Indent 指定的缩进
+func (p *Processor) encoderFor(jsonInput bool) Encoder {
+	enc := p.outputEncoder()
+	if _, ok := enc.(jsonEncoder); ok || (jsonInput && enc.Format() == FormatPreserve) {
+		return jsonEncoder{indent: p.opts.JSONIndent}
+	}
+	indent := p.opts.Indent
+	if indent == 0 && p.opts.Normalize {
+		indent = 2
+	}
+	switch enc.(type) {
+	case preserveEncoder:
+		return preserveEncoder{indent: indent}
+	case k8sEncoder:
+		return k8sEncoder{indent: indent}
+	}
+	return enc
+}
+
+// NewJSONEncoder 创建缩进为 indent 个空格的 JSON 编码器，0 表示沿用 JSON 输入的缩进（无法识别时为 2）
+func NewJSONEncoder(indent int) Encoder {
+	return jsonEncoder{indent: indent}
+}
+
+// detectJSONIndent 识别 JSON 原文的缩进：第一个缩进行使用 tab 时为 tab，否则为其空格数，识别不到时为两个空格
+func detectJSONIndent(original []byte) string {
+	for _, line := range splitLines(string(original)) {
+		content := strings.TrimLeft(line, " \t")
+		if isBlank(line) || len(content) == len(line) {
+			continue
+		}
+		if line[0] == '\t' {
+			return "\t"
+		}
+		return line[:len(line)-len(content)]
+	}
+	return "  "
+}
+
+// writeJSON 按节点顺序输出 JSON，对象键保持原顺序；别名展开为锚点内容，合并键展开为继承的字段
+func writeJSON(buf *bytes.Buffer, node *yaml.Node, indent string, depth int) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("null")
+			return nil
+		}
+		return writeJSON(buf, node.Content[0], indent, depth)
+	case yaml.AliasNode:
+		return writeJSON(buf, node.Alias, indent, depth)
+	case yaml.MappingNode:
+		pairs, err := jsonPairs(node)
+		if err != nil {
+			return err
+		}
+		if len(pairs) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteByte('{')
+		for i := 0; i < len(pairs); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			newline(buf, indent, depth+1)
+			buf.WriteString(jsonString(pairs[i].Value))
+			buf.WriteString(": ")
+			if err := writeJSON(buf, pairs[i+1], indent, depth+1); err != nil {
+				return err
+			}
+		}
+		newline(buf, indent, depth)
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			newline(buf, indent, depth+1)
+			if err := writeJSON(buf, item, indent, depth+1); err != nil {
+				return err
+			}
+		}
+		newline(buf, indent, depth)
+		buf.WriteByte(']')
+	case yaml.ScalarNode:
+		s, err := jsonScalar(node)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	}
+	return nil
+}
+
+// newline 换行并按层级缩进
+func newline(buf *bytes.Buffer, indent string, depth int) {
+	buf.WriteByte('\n')
+	buf.WriteString(strings.Repeat(indent, depth))
+}
+
+// jsonPairs 返回 mapping 输出为 JSON 对象时的键值对（交替排列）：
+// 自身的字段在前，合并键继承且未被覆盖的字段按继承顺序在后；键须为标量
+func jsonPairs(node *yaml.Node) ([]*yaml.Node, error) {
+	var pairs, merged []*yaml.Node
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: JSON object keys must be scalars", key.Line)
+		}
+		if key.ShortTag() == "!!merge" {
+			sources := []*yaml.Node{value}
+			if v := resolveAlias(value); v.Kind == yaml.SequenceNode {
+				sources = v.Content
+			}
+			for _, src := range sources {
+				inherited, err := jsonPairs(resolveAlias(src))
+				if err != nil {
+					return nil, err
+				}
+				merged = append(merged, inherited...)
+			}
+			continue
+		}
+		if !seen[key.Value] {
+			seen[key.Value] = true
+			pairs = append(pairs, key, value)
+		}
+	}
+	for i := 0; i+1 < len(merged); i += 2 {
+		if !seen[merged[i].Value] {
+			seen[merged[i].Value] = true
+			pairs = append(pairs, merged[i], merged[i+1])
+		}
+	}
+	return pairs, nil
+}
+
+// resolveAlias 返回别名指向的节点
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// jsonScalar 按标签输出标量：数字原文是合法的 JSON 数字时原样保留，
+// 其他写法（如 0x1F、1_000）按解码后的值输出；非数值的标签一律输出为字符串
+func jsonScalar(node *yaml.Node) (string, error) {
+	switch node.ShortTag() {
+	case "!!null":
+		return "null", nil
+	case "!!bool":
+		var b bool
+		if err := node.Decode(&b); err != nil {
+			return "", err
+		}
+		return fmt.Sprint(b), nil
+	case "!!int", "!!float":
+		if isJSONNumber(node.Value) {
+			return node.Value, nil
+		}
+		var v interface{}
+		if err := node.Decode(&v); err != nil {
+			return "", err
+		}
+		if f, ok := v.(float64); ok && (math.IsInf(f, 0) || math.IsNaN(f)) {
+			return "", fmt.Errorf("line %d: %s cannot be represented in JSON", node.Line, node.Value)
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("marshal json: %w", err)
+		}
+		return string(data), nil
+	default:
+		return jsonString(node.Value), nil
+	}
+}
+
+// isJSONNumber 判断文本是否为合法的 JSON 数字
+func isJSONNumber(s string) bool {
+	var n json.Number
+	return json.Unmarshal([]byte(s), &n) == nil && string(n) == s
+}
+
+// jsonString 输出 JSON 字符串，不转义 HTML 字符
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,217 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KRM 函数协议中 kustomize、kpt 为资源添加的注解，记录资源来自哪个文件的第几个文档
+const (
+	annotationPath        = "internal.config.kubernetes.io/path"
+	annotationIndex       = "internal.config.kubernetes.io/index"
+	annotationLegacyPath  = "config.kubernetes.io/path"
+	annotationLegacyIndex = "config.kubernetes.io/index"
+)
+
+// ResourceList KRM 函数（kustomize、kpt）的输入与输出，config.kubernetes.io/v1 ResourceList
+type ResourceList struct {
+	root           *yaml.Node   // ResourceList 本身，写出时保留 items 与 results 以外的字段
+	Items          []*yaml.Node // 资源
+	FunctionConfig *yaml.Node   // 函数配置，没有时为 nil
+	Results        []KRMResult  // 写出时替换输入中的 results
+}
+
+// KRMResult ResourceList.results 中的一项
+type KRMResult struct {
+	Message     string          `yaml:"message"`
+	Severity    string          `yaml:"severity"` // error、warning 或 info
+	ResourceRef *KRMResourceRef `yaml:"resourceRef,omitempty"`
+	File        *KRMFile        `yaml:"file,omitempty"`
+}
+
+// KRMResourceRef 结果对应的资源
+type KRMResourceRef struct {
+	APIVersion string `yaml:"apiVersion,omitempty"`
+	Kind       string `yaml:"kind,omitempty"`
+	Name       string `yaml:"name,omitempty"`
+	Namespace  string `yaml:"namespace,omitempty"`
+}
+
+// KRMFile 结果对应资源所在的文件
+type KRMFile struct {
+	Path  string `yaml:"path"`
+	Index int    `yaml:"index,omitempty"`
+}
+
+// ReadResourceList 解析 ResourceList；items 可以省略
+func ReadResourceList(data []byte) (*ResourceList, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("unmarshal resource list: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("input is not a ResourceList")
+	}
+	root := doc.Content[0]
+	if kind := krmField(root, "kind"); kind == nil || kind.Value != "ResourceList" {
+		return nil, fmt.Errorf("input is not a ResourceList")
+	}
+
+	list := &ResourceList{root: root}
+	if items := krmField(root, "items"); items != nil && items.ShortTag() != "!!null" {
+		if items.Kind != yaml.SequenceNode {
+			return nil, fmt.Errorf("items must be a list")
+		}
+		list.Items = items.Content
+	}
+	if fc := krmField(root, "functionConfig"); fc != nil && fc.ShortTag() != "!!null" {
+		list.FunctionConfig = fc
+	}
+	return list, nil
+}
+
+// Encode 序列化 ResourceList，items 与 results 取自 Items 与 Results，没有结果时不写 results；其他字段保持原顺序
+func (l *ResourceList) Encode() ([]byte, error) {
+	items := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: l.Items}
+	var results *yaml.Node
+	if len(l.Results) > 0 {
+		results = &yaml.Node{}
+		if err := results.Encode(l.Results); err != nil {
+			return nil, fmt.Errorf("marshal results: %w", err)
+		}
+	}
+
+	fields := map[string]*yaml.Node{"items": items, "results": results}
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for i := 0; i+1 < len(l.root.Content); i += 2 {
+		key, value := l.root.Content[i], l.root.Content[i+1]
+		if v, ok := fields[key.Value]; ok {
+			delete(fields, key.Value)
+			if value = v; value == nil {
+				continue
+			}
+		}
+		root.Content = append(root.Content, key, value)
+	}
+	for _, key := range []string{"items", "results"} {
+		if v := fields[key]; v != nil {
+			root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, v)
+		}
+	}
+	return encodeYAML([]*yaml.Node{{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}}, 2)
+}
+
+// TransformResourceList 对 ResourceList 中的每个资源单独应用规则，返回出错的资源数
+// 出错的资源保持不变，错误记入 Results，警告以 warning 级别记入 Results；资源所在的文件（注解中的 path）用于规则的 files 与模板中的 .File，
+// 没有该注解时为 命名空间/名称；规则把资源变为空文档时从 items 中移除
+func (p *Processor) TransformResourceList(list *ResourceList) int {
+	failed := 0
+	items := list.Items[:0:0]
+	for _, item := range list.Items {
+		name, file := resourceFile(item)
+		output, warnings, err := p.transformResource(name, item)
+		for _, w := range warnings {
+			list.Results = append(list.Results, KRMResult{
+				Message:     w,
+				Severity:    "warning",
+				ResourceRef: resourceRef(item),
+				File:        file,
+			})
+		}
+		if err != nil {
+			failed++
+			list.Results = append(list.Results, KRMResult{
+				Message:     err.Error(),
+				Severity:    "error",
+				ResourceRef: resourceRef(item),
+				File:        file,
+			})
+			items = append(items, item)
+			continue
+		}
+		if output != nil {
+			items = append(items, output)
+		}
+	}
+	list.Items = items
+	return failed
+}
+
+// transformResource 把资源作为单独的文档应用规则，返回修改后的资源与警告，规则把文档变为空时返回 nil
+func (p *Processor) transformResource(name string, item *yaml.Node) (*yaml.Node, []string, error) {
+	data, err := encodeYAML([]*yaml.Node{{Kind: yaml.DocumentNode, Content: []*yaml.Node{item}}}, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	output, result, err := p.Eval(name, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bytes.Equal(output, data) {
+		return item, result.Warnings, nil
+	}
+	docs, err := decodeDocuments(output)
+	if err != nil {
+		return nil, result.Warnings, fmt.Errorf("%s: parse result: %w", name, err)
+	}
+	if len(docs) == 0 || isEmptyDocument(docs[0]) {
+		return nil, result.Warnings, nil
+	}
+	if len(docs) > 1 {
+		return nil, result.Warnings, fmt.Errorf("%s: rules produced %d documents, expected one", name, len(docs))
+	}
+	return docs[0].Content[0], result.Warnings, nil
+}
+
+// resourceFile 返回资源在规则中的文件名与结果中的文件位置（没有 path 注解时为 nil）
+func resourceFile(item *yaml.Node) (string, *KRMFile) {
+	annotations := krmField(krmField(item, "metadata"), "annotations")
+	for _, keys := range [][2]string{{annotationPath, annotationIndex}, {annotationLegacyPath, annotationLegacyIndex}} {
+		if path := krmField(annotations, keys[0]); path != nil && path.Value != "" {
+			file := &KRMFile{Path: path.Value}
+			if index := krmField(annotations, keys[1]); index != nil {
+				file.Index, _ = strconv.Atoi(index.Value)
+			}
+			return path.Value, file
+		}
+	}
+
+	ref := resourceRef(item)
+	if ref.Namespace != "" {
+		return ref.Namespace + "/" + ref.Name, nil
+	}
+	return ref.Name, nil
+}
+
+// resourceRef 返回资源的类型与名称
+func resourceRef(item *yaml.Node) *KRMResourceRef {
+	value := func(n *yaml.Node) string {
+		if n == nil || n.Kind != yaml.ScalarNode {
+			return ""
+		}
+		return n.Value
+	}
+	metadata := krmField(item, "metadata")
+	return &KRMResourceRef{
+		APIVersion: value(krmField(item, "apiVersion")),
+		Kind:       value(krmField(item, "kind")),
+		Name:       value(krmField(metadata, "name")),
+		Namespace:  value(krmField(metadata, "namespace")),
+	}
+}
+
+// krmField 返回 mapping 中 key 对应的值节点，node 为 nil 或不是 mapping 时返回 nil
+func krmField(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/glesirok/yamleditor/pkg/rule"
+)
+
+// ResourceList 读入、逐个资源应用规则再写出：其他字段保持原顺序，出错的资源原样保留并记入 results，
+// 资源的文件名取自 path 注解，没有注解时为 命名空间/名称
+func TestTransformResourceList(t *testing.T) {
+	const rules = `- action: replace
+  path: spec.replicas
+  value: 3
+  files: ["apps/*.yaml"]
+- action: replace
+  path: metadata.name
+  value: "{{ .File }}"
+`
+	tests := []struct {
+		name       string
+		input      string
+		want       string
+		wantFailed int
+	}{
+		{
+			name: "round trip",
+			input: `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+functionConfig:
+  data: {mode: test}
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: web
+    annotations:
+      internal.config.kubernetes.io/path: apps/web.yaml
+  spec:
+    replicas: 1
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: settings
+    namespace: prod
+extra: kept
+`,
+			want: `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+functionConfig:
+  data: {mode: test}
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: apps/web.yaml
+      annotations:
+        internal.config.kubernetes.io/path: apps/web.yaml
+    spec:
+      replicas: 3
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: prod/settings
+      namespace: prod
+extra: kept
+`,
+		},
+		{
+			name: "failed resource is kept and reported",
+			input: `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: broken
+    annotations:
+      config.kubernetes.io/path: apps/broken.yaml
+      config.kubernetes.io/index: "1"
+results:
+- message: stale
+  severity: info
+`,
+			want: `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: broken
+      annotations:
+        config.kubernetes.io/path: apps/broken.yaml
+        config.kubernetes.io/index: "1"
+results:
+  - message: 'apps/broken.yaml: apply rule 0, path:{spec.replicas}: find nodes: field ''spec'' not found'
+    severity: error
+    resourceRef:
+      apiVersion: apps/v1
+      kind: Deployment
+      name: broken
+    file:
+      path: apps/broken.yaml
+      index: 1
+`,
+			wantFailed: 1,
+		},
+		{
+			name:  "no items",
+			input: "apiVersion: config.kubernetes.io/v1\nkind: ResourceList\n",
+			want:  "apiVersion: config.kubernetes.io/v1\nkind: ResourceList\nitems: []\n",
+		},
+	}
+	config, err := rule.ParseInline([]byte(rules))
+	if err != nil {
+		t.Fatalf("parse rules: %v", err)
+	}
+	for _, tt := range tests {
+		list, err := ReadResourceList([]byte(tt.input))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		// 结果只反映本次执行，输入中的 results 被替换
+		list.Results = nil
+		if failed := NewProcessorFromConfig(config).TransformResourceList(list); failed != tt.wantFailed {
+			t.Errorf("%s: failed = %d, want %d", tt.name, failed, tt.wantFailed)
+		}
+		output, err := list.Encode()
+		if err != nil {
+			t.Fatalf("%s: encode: %v", tt.name, err)
+		}
+		if string(output) != tt.want {
+			t.Errorf("%s:\n--- got\n%s--- want\n%s", tt.name, output, tt.want)
+		}
+	}
+}
+
+func TestReadResourceListInvalid(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"- a\n",
+		"kind: ConfigMap\n",
+		"kind: ResourceList\nitems: {a: b}\n",
+	} {
+		if _, err := ReadResourceList([]byte(input)); err == nil {
+			t.Errorf("ReadResourceList(%q): expected error", input)
+		}
+	}
+}
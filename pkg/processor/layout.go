@@ -0,0 +1,314 @@
+package processor
+
+import (
+	"regexp"
+	"strings"
+)
+
+// restoreLayout 恢复序列化时丢失的空行、文档分隔符与被移动的独立注释
+// yaml.v3 重新编码时会丢弃节点间的空行，并把与节点隔开的注释块挪到相邻节点旁。
+// 这里按去除缩进后的内容逐行比对原文与输出：原文中被丢弃的空行放回原位，
+// 被挪动的注释行保留在原位置，未修改的行沿用原文的缩进与内容；规则实际修改的内容以输出为准
+// 差异超过 maxEdits 时只对齐首尾相同的部分，中间按输出写出，首尾的格式仍然保留
+func restoreLayout(original, output []byte) []byte {
+	a, b := splitLines(string(original)), splitLines(string(output))
+	na, nb := normalizeLines(a), normalizeLines(b)
+	edits, ok := diffLines(na, nb)
+	rough := !ok
+	if rough {
+		edits = alignEnds(na, nb)
+	}
+
+	// 同时出现在删除与插入两侧的注释行视为被移动，按两侧出现次数的较小值计
+	// 文档分隔符 --- 总是放回原位（如文件开头的 ---，重新编码时不会输出）
+	deleted, inserted := map[string]int{}, map[string]int{}
+	for _, e := range edits {
+		if rough || !strings.HasPrefix(e.line, "#") && e.line != docSeparator {
+			continue
+		}
+		switch e.kind {
+		case editDelete:
+			deleted[e.line]++
+		case editInsert:
+			inserted[e.line]++
+		}
+	}
+	moved, suppressed := map[string]int{}, map[string]int{}
+	for line, n := range deleted {
+		moved[line] = min(n, inserted[line])
+		suppressed[line] = moved[line]
+		if line == docSeparator {
+			moved[line] = n
+		}
+	}
+
+	var buf strings.Builder
+	var levels indenter
+	lastBlank := false
+	// restore 放回原文第 x 行（空行或被移动的注释），不连续输出原文中没有的多个空行
+	restore := func(x int, line string) {
+		switch {
+		case isBlank(a[x]):
+			if !lastBlank || (x > 0 && isBlank(a[x-1])) {
+				buf.WriteString(withNewline(a[x]))
+				lastBlank = true
+			}
+		case moved[line] > 0:
+			buf.WriteString(withNewline(a[x]))
+			if line == docSeparator {
+				levels.reset()
+			}
+			moved[line]--
+			lastBlank = false
+		}
+	}
+	restorable := func(x int, line string) bool {
+		return isBlank(a[x]) || moved[line] > 0
+	}
+
+	x, y := 0, 0
+	for i := 0; i < len(edits); {
+		if edits[i].kind == editEqual {
+			buf.WriteString(levels.line(b[y], a[x], true))
+			lastBlank = isBlank(b[y])
+			x, y, i = x+1, y+1, i+1
+			continue
+		}
+
+		// 一段连续的删除/插入：被替换内容之前的空行放在新内容之前，其余放在之后
+		var dels []int
+		var delLines []string
+		var ins []int
+		for ; i < len(edits) && edits[i].kind != editEqual; i++ {
+			if edits[i].kind == editDelete {
+				dels = append(dels, x)
+				delLines = append(delLines, edits[i].line)
+				x++
+			} else {
+				ins = append(ins, i)
+				y++
+			}
+		}
+
+		// 新内容比其后的行缩进更深时是在上一段末尾追加（如向 mapping 末尾添加键），空行都放在新内容之后
+		appended := len(ins) > 0 && y < len(b) && indentOf(b[y-len(ins)]) > indentOf(b[y])
+		lead := 0
+		for !appended && lead < len(dels) && restorable(dels[lead], delLines[lead]) {
+			restore(dels[lead], delLines[lead])
+			lead++
+		}
+
+		// 被修改的行（删除与插入的内容行一一对应时）沿用原行的缩进
+		yy := y - len(ins)
+		pairs := pairLines(a, dels, b, yy, len(ins))
+		for _, k := range ins {
+			if suppressed[edits[k].line] > 0 {
+				suppressed[edits[k].line]--
+			} else {
+				buf.WriteString(levels.line(b[yy], pairs[yy], false))
+				lastBlank = isBlank(b[yy])
+			}
+			yy++
+		}
+
+		rest := lead
+		if rough {
+			// 粗略对齐时中间的空行无法定位，只放回首尾的空行
+			rest = len(dels)
+			for rest > lead && isBlank(a[dels[rest-1]]) {
+				rest--
+			}
+		}
+		for k := rest; k < len(dels); k++ {
+			restore(dels[k], delLines[k])
+		}
+	}
+	return []byte(buf.String())
+}
+
+// detectIndent 推断原文的缩进宽度：取相邻内容行间缩进增量中出现最多的值（相同时取较小者），
+// 无法推断或超出 yaml.v3 支持的 2~9 时使用 2
+func detectIndent(original []byte) int {
+	counts := map[int]int{}
+	prev := -1
+	for _, line := range splitLines(string(original)) {
+		content := strings.TrimLeft(line, " ")
+		if isBlank(line) || strings.HasPrefix(content, "#") {
+			continue
+		}
+		indent := len(line) - len(content)
+		if prev >= 0 && indent > prev {
+			counts[indent-prev]++
+		}
+		prev = indent
+	}
+
+	best := 2
+	for step, n := range counts {
+		if step < 2 || step > 9 {
+			continue
+		}
+		if n > counts[best] || (n == counts[best] && step < best) {
+			best = step
+		}
+	}
+	return best
+}
+
+// docSeparator 去除首尾空白后的文档分隔符行
+const docSeparator = "---"
+
+// commentSpacing 行尾注释前的空白，yaml.v3 重新编码时统一为一个空格
+var commentSpacing = regexp.MustCompile(`[ \t]+#`)
+
+// normalizeLines 去除缩进与行尾空白、统一行尾注释前的空白，重新缩进的行仍视为相同
+func normalizeLines(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = commentSpacing.ReplaceAllString(strings.TrimSpace(line), " #")
+	}
+	return out
+}
+
+// alignEnds 差异过大时的粗略对齐：首尾相同的行视为未修改，中间整体替换
+func alignEnds(a, b []string) []edit {
+	head := 0
+	for head < len(a) && head < len(b) && a[head] == b[head] {
+		head++
+	}
+	tail := 0
+	for tail < len(a)-head && tail < len(b)-head && a[len(a)-1-tail] == b[len(b)-1-tail] {
+		tail++
+	}
+
+	edits := make([]edit, 0, len(a)+len(b)-head-tail)
+	for _, line := range a[:head] {
+		edits = append(edits, edit{editEqual, line})
+	}
+	edits = append(edits, replaceAll(a[head:len(a)-tail], b[head:len(b)-tail])...)
+	for _, line := range a[len(a)-tail:] {
+		edits = append(edits, edit{editEqual, line})
+	}
+	return edits
+}
+
+// indenter 决定写出的每一行的缩进
+// 未修改的行沿用原文的缩进（如 kubectl 风格不缩进的列表），新插入的行按所在层级在输出与写出之间的缩进差调整，
+// 保证层级关系与输出一致；原文缩进与层级关系不符时（如行被移动到别处）改用调整后的缩进
+type indenter struct {
+	levels []indentLevel // 当前所在的各层内容行，由浅到深
+}
+
+// indentLevel 一层内容行在输出中的缩进与写出的缩进
+type indentLevel struct {
+	out, final int
+	item       bool // 序列元素（以 - 开头）
+}
+
+func (t *indenter) reset() {
+	t.levels = t.levels[:0]
+}
+
+// line 返回输出中的行 output 实际写出的内容；original 为原文中对应的行，没有时为空串，
+// 与层级关系一致时沿用其缩进；matched 表示该行未修改，写出原文的内容以保留行尾注释前手写的对齐空白
+func (t *indenter) line(output, original string, matched bool) string {
+	content := strings.TrimLeft(output, " ")
+	if isBlank(output) {
+		return output
+	}
+	if matched {
+		content = strings.TrimSpace(original)
+		if strings.HasSuffix(output, "\n") {
+			content += "\n"
+		}
+	}
+	trimmed := strings.TrimSpace(content)
+	if trimmed == docSeparator || trimmed == "..." {
+		t.reset()
+		return content
+	}
+
+	out := indentOf(output)
+	orig := -1
+	if original != "" {
+		orig = indentOf(original)
+	}
+	keep := len(t.levels)
+	for keep > 0 && t.levels[keep-1].out > out {
+		keep--
+	}
+	item := strings.HasPrefix(trimmed, "-") && (len(trimmed) == 1 || trimmed[1] == ' ')
+
+	final := out
+	switch {
+	case orig >= 0 && strings.HasPrefix(trimmed, "#"):
+		// 未修改的注释保持原位
+		final = orig
+	case keep > 0 && t.levels[keep-1].out == out:
+		// 同层的行缩进相同
+		keep--
+		final = t.levels[keep].final
+	case keep > 0:
+		// 下一层：原文缩进更深，或为紧贴在键下方、不缩进的序列元素时沿用
+		parent := t.levels[keep-1]
+		final = out + parent.final - parent.out
+		if orig > parent.final || orig == parent.final && item && !parent.item {
+			final = orig
+		}
+	case orig >= 0:
+		final = orig
+	}
+
+	// 注释不影响层级
+	if !strings.HasPrefix(trimmed, "#") {
+		t.levels = append(t.levels[:keep], indentLevel{out: out, final: final, item: item})
+	}
+	return strings.Repeat(" ", final) + content
+}
+
+// pairLines 把一段修改中删除的原文内容行与插入的输出内容行按顺序对应，返回输出行号到原文行的映射；
+// 两侧内容行（空行与注释除外）数量不同时无法对应，返回 nil
+func pairLines(a []string, dels []int, b []string, from, n int) map[int]string {
+	var olds []string
+	for _, x := range dels {
+		if isContent(a[x]) {
+			olds = append(olds, a[x])
+		}
+	}
+	var news []int
+	for y := from; y < from+n; y++ {
+		if isContent(b[y]) {
+			news = append(news, y)
+		}
+	}
+	if len(olds) == 0 || len(olds) != len(news) {
+		return nil
+	}
+	pairs := make(map[int]string, len(news))
+	for i, y := range news {
+		pairs[y] = olds[i]
+	}
+	return pairs
+}
+
+// isContent 不是空行、注释或文档分隔符
+func isContent(line string) bool {
+	s := strings.TrimSpace(line)
+	return s != "" && !strings.HasPrefix(s, "#") && s != docSeparator && s != "..."
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// withNewline 原文最后一行可能没有换行符，插入到输出中间时补上
+func withNewline(line string) string {
+	if strings.HasSuffix(line, "\n") {
+		return line
+	}
+	return line + "\n"
+}
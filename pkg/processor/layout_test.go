@@ -0,0 +1,182 @@
+package processor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glesirok/yamleditor/pkg/rule"
+)
+
+// testdata/layout 下每个目录是一个用例：input.yaml 应用 rules.yaml 后应得到 want.yaml
+func TestLayoutFixtures(t *testing.T) {
+	dirs, err := filepath.Glob(filepath.Join("testdata", "layout", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) == 0 {
+		t.Fatal("no fixtures found")
+	}
+	for _, dir := range dirs {
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			input := readFixture(t, dir, "input.yaml")
+			want := readFixture(t, dir, "want.yaml")
+			got := evalRules(t, string(readFixture(t, dir, "rules.yaml")), input)
+			if got != string(want) {
+				t.Errorf("output mismatch\n--- got\n%s\n--- want\n%s", got, want)
+			}
+		})
+	}
+}
+
+// 规则没有修改任何内容时，所有用例的输入都应原样输出
+func TestLayoutNoopRoundTrip(t *testing.T) {
+	dirs, err := filepath.Glob(filepath.Join("testdata", "layout", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const noop = `{action: delete, path: no.such.key, continue_on_not_found: true}`
+	for _, dir := range dirs {
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			input := readFixture(t, dir, "input.yaml")
+			if got := evalRules(t, noop, input); got != string(input) {
+				t.Errorf("no-op rule changed the file\n--- got\n%s\n--- want\n%s", got, input)
+			}
+		})
+	}
+}
+
+func TestRestoreLayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		output   string
+		want     string
+	}{
+		{
+			name:     "unchanged lines keep original indentation",
+			original: "spec:\n  containers:\n  - name: app\n    image: x\n",
+			output:   "spec:\n  containers:\n    - name: app\n      image: y\n",
+			want:     "spec:\n  containers:\n  - name: app\n    image: y\n",
+		},
+		{
+			name:     "inserted sequence item follows its siblings",
+			original: "items:\n- a: 1\n",
+			output:   "items:\n  - a: 1\n  - b: 2\n    c: 3\n",
+			want:     "items:\n- a: 1\n- b: 2\n  c: 3\n",
+		},
+		{
+			name:     "changed line keeps original indentation",
+			original: "l:\n- x: 1\n- y: 2\n",
+			output:   "l:\n  - x: 5\n  - y: 2\n",
+			want:     "l:\n- x: 5\n- y: 2\n",
+		},
+		{
+			name:     "blank line groups",
+			original: "a: 1\n\n\nb: 2\n\nc: 3\n",
+			output:   "a: 1\nb: 5\nc: 3\n",
+			want:     "a: 1\n\n\nb: 5\n\nc: 3\n",
+		},
+		{
+			name:     "line comment alignment",
+			original: "a: 1      # first\nbb: 2     # second\n",
+			output:   "a: 1 # first\nbb: 3 # second\n",
+			want:     "a: 1      # first\nbb: 3 # second\n",
+		},
+		{
+			name:     "moved head comment stays in place",
+			original: "a: 1\n\n# about b\n\nb: 2\n",
+			output:   "a: 1\n# about b\nb: 2\n",
+			want:     "a: 1\n\n# about b\n\nb: 2\n",
+		},
+		{
+			name:     "leading document separator",
+			original: "---\na: 1\n",
+			output:   "a: 2\n",
+			want:     "---\na: 2\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(restoreLayout([]byte(tt.original), []byte(tt.output))); got != tt.want {
+				t.Errorf("restoreLayout() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// 差异超过 maxEdits 时仍保留首尾未修改部分的格式
+func TestRestoreLayoutLargeDiff(t *testing.T) {
+	var original, output, want strings.Builder
+	head := "# head\nlist:\n- a: 1\n\n"
+	original.WriteString(head + "m:\n")
+	output.WriteString("# head\nlist:\n  - a: 1\nm:\n")
+	want.WriteString(head + "m:\n")
+	for i := 0; i < maxEdits+100; i++ {
+		fmt.Fprintf(&original, "  k%d: v%d\n", i, i)
+		fmt.Fprintf(&output, "  k%d: w%d\n", i, i)
+		fmt.Fprintf(&want, "  k%d: w%d\n", i, i)
+	}
+	original.WriteString("\ntail:\n- b: 2   # end\n")
+	output.WriteString("tail:\n  - b: 2 # end\n")
+	want.WriteString("\ntail:\n- b: 2   # end\n")
+
+	if got := string(restoreLayout([]byte(original.String()), []byte(output.String()))); got != want.String() {
+		t.Errorf("restoreLayout() head/tail mismatch\n--- got\n%s\n--- want\n%s", excerpt(got), excerpt(want.String()))
+	}
+}
+
+func readFixture(t *testing.T, dir, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// evalRules 以内联规则处理 input，返回输出
+func evalRules(t *testing.T, rules string, input []byte) string {
+	t.Helper()
+	config, err := rule.ParseInline([]byte(rules))
+	if err != nil {
+		t.Fatalf("parse rules: %v", err)
+	}
+	output, _, err := NewProcessorFromConfig(config).Eval("input.yaml", input)
+	if err != nil {
+		t.Fatalf("eval: %v", err)
+	}
+	return string(output)
+}
+
+// excerpt 只保留开头与结尾几行，便于查看大文件的差异
+func excerpt(s string) string {
+	lines := strings.SplitAfter(s, "\n")
+	if len(lines) <= 12 {
+		return s
+	}
+	return strings.Join(lines[:6], "") + "...\n" + strings.Join(lines[len(lines)-6:], "")
+}
+
+func TestDetectIndent(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		want     int
+	}{
+		{"two spaces", "a:\n  b:\n    c: 1\n", 2},
+		{"four spaces", "a:\n    b:\n        c: 1\n    d: 2\n", 4},
+		{"comments ignored", "a:\n        # note\n    b: 1\n", 4},
+		{"flat file", "a: 1\nb: 2\n", 2},
+		{"unsupported width", "a:\n            b: 1\n", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectIndent([]byte(tt.original)); got != tt.want {
+				t.Errorf("detectIndent() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
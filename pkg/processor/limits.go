@@ -0,0 +1,33 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLimitExceeded 计划中的变更超出 Options.MaxChangedFiles 或 MaxChangesPerFile
+var ErrLimitExceeded = errors.New("planned changes exceed limit")
+
+// checkLimits 检查计划中的变更规模，超出上限时返回 ErrLimitExceeded
+func (p *Processor) checkLimits(planned []*plannedFile) error {
+	changed := 0
+	for _, f := range planned {
+		if !f.changed() {
+			continue
+		}
+		changed++
+
+		nodes := 0
+		for _, s := range f.result.Rules {
+			nodes += s.NodesChanged
+		}
+		if limit := p.opts.MaxChangesPerFile; limit > 0 && nodes > limit {
+			return fmt.Errorf("%w: %s would change %d node(s), max %d per file; no files written",
+				ErrLimitExceeded, reportPath(f.input), nodes, limit)
+		}
+	}
+	if limit := p.opts.MaxChangedFiles; limit > 0 && changed > limit {
+		return fmt.Errorf("%w: %d file(s) would change, max %d; no files written", ErrLimitExceeded, changed, limit)
+	}
+	return nil
+}
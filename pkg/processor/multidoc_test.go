@@ -0,0 +1,61 @@
+package processor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/glesirok/yamleditor/pkg/engine"
+	"github.com/glesirok/yamleditor/pkg/rule"
+)
+
+// 多文档文件中只有找不到节点可以在其余文档上跳过，类型不符仍使文件失败
+func TestMultiDocumentErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		input    string
+		want     string
+		mismatch bool
+	}{
+		{
+			name:  "missing in one document",
+			rule:  `{action: append, path: items, value: b}`,
+			input: "items: [a]\n---\nother: 1\n",
+			want:  "items: [a, b]\n---\nother: 1\n",
+		},
+		{
+			name:     "type mismatch in one document",
+			rule:     `{action: append, path: items, value: b}`,
+			input:    "items: [a]\n---\nitems: {k: v}\n",
+			mismatch: true,
+		},
+		{
+			name:     "type mismatch in first document",
+			rule:     `{action: append, path: items, value: b}`,
+			input:    "items: {k: v}\n---\nitems: [a]\n",
+			mismatch: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := rule.ParseInline([]byte(tt.rule))
+			if err != nil {
+				t.Fatal(err)
+			}
+			output, _, err := NewProcessorFromConfig(config).Eval("input.yaml", []byte(tt.input))
+			if tt.mismatch {
+				var mismatch *engine.ErrTypeMismatch
+				if !errors.As(err, &mismatch) {
+					t.Fatalf("Eval() error = %v, want type mismatch", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+			if string(output) != tt.want {
+				t.Errorf("output =\n%s\nwant\n%s", output, tt.want)
+			}
+		})
+	}
+}
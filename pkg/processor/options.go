@@ -0,0 +1,113 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/glesirok/yamleditor/pkg/engine"
+	"github.com/glesirok/yamleditor/pkg/path"
+	"github.com/glesirok/yamleditor/pkg/schema"
+)
+
+// Options 处理器的可选行为，零值即默认行为
+type Options struct {
+	// Backup 原地修改且内容有变化时，将原文件备份为 .bak（ProcessFile 使用）
+	Backup bool
+	// BackupFormat 备份格式，空值同 BackupCopy
+	BackupFormat BackupFormat
+
+	// Format 输入文件格式：空值或 auto 按扩展名与内容识别，yaml、json 强制指定；
+	// json 时批量处理只处理 .json 文件，否则只处理 .yaml/.yml 文件
+	Format string
+	// JSONIndent JSON 输出的缩进空格数，0 表示沿用 JSON 输入的缩进（无法识别时为 2）
+	JSONIndent int
+	// Indent YAML 输出的缩进空格数，0 表示沿用输入的缩进（Normalize 时为 2）
+	Indent int
+
+	// Normalize 执行规则后整理每个文档：mapping 键按名称排序，布尔与 null 统一写为 true、false、null，
+	// YAML 输出统一缩进，便于比较不同来源的清单
+	Normalize bool
+
+	// Include 非空时批量处理只处理匹配其中任一 glob 模式的文件，Exclude 匹配的文件与目录跳过；
+	// 模式匹配相对输入目录的路径（文件列表模式下为列表中的路径），** 匹配任意层目录
+	Include []string
+	Exclude []string
+
+	// Templated 输入是 Helm chart 等含 Go 模板（{{ ... }}）的 YAML：模板替换为占位符后再解析，
+	// 输出时原样还原；规则看到的是占位符文本，见 maskTemplates
+	Templated bool
+
+	// CompareOutput 写入前与输出路径上已有的文件比较，内容相同则不写（保持 mtime），
+	// 目录模式下同时报告输出目录中没有对应输入的文件；dry-run 时只输出每个文件的比较结果
+	CompareOutput bool
+
+	// DiffFormat dry-run 时的预览格式，空值输出完整的处理结果
+	DiffFormat DiffFormat
+	// Check dry-run 时只计算每个文件的状态，不输出预览；设置了 DiffFormat 时仍输出差异
+	Check bool
+
+	// Strict 将警告升级为错误：continue_on_not_found 的规则未匹配到节点、
+	// 通配展开时跳过元素、coerce 转换了类型、替换丢弃了注释等都会使当前文件失败
+	Strict bool
+
+	// DuplicateKeys mapping 中出现重复键时的处理，空值同 DuplicateKeysWarn
+	DuplicateKeys DuplicateKeys
+
+	// StrictTypes replace、regex_replace、merge 写入的值与原节点类型不同（如把字符串写到整数字段）时规则报错，
+	// 设置了 coerce 的规则转换为原类型
+	StrictTypes bool
+
+	// ContinueOnNotFound 对所有规则生效的 continue_on_not_found：路径未找到时跳过规则而不是使文件失败
+	ContinueOnNotFound bool
+
+	// PruneEmpty 对所有 delete 规则生效的 prune_empty：删除后变空的父 mapping/sequence 一并删除
+	PruneEmpty bool
+
+	// OnlyPathPrefix 非空时只执行 path 位于该前缀之下的规则（按片段比较），其余规则跳过
+	OnlyPathPrefix string
+
+	// Select 非 nil 时只对满足选择器的文档执行规则，其余文档原样输出
+	Select *engine.Selector
+	// Extract 与 Select 一起使用：输出中只保留选中的文档与文件开头的注释块
+	Extract bool
+
+	// Schema 非 nil 时按其中的 OpenAPI 定义校验处理后的每个文档，字段类型错误或出现未知字段时该文件失败
+	Schema *schema.Schema
+
+	// RecordChanges 在 FileResult/FileReport 的 Changes 中记录逐节点修改，用于生成审计报告
+	RecordChanges bool
+
+	// FailFast 目录模式下第一个文件失败即停止，剩余文件不再处理；默认记录失败并继续
+	FailFast bool
+
+	// AllowNonAtomic 替换已有文件时无法保留其属主（ErrOwnerNotPreserved）的，改为直接覆盖写入原文件并产生警告；
+	// 这种写入不是原子的，中途崩溃会留下不完整的文件。默认该文件失败
+	AllowNonAtomic bool
+
+	// MaxChangedFiles 内容会发生变化的文件数上限，超出时不写任何文件并返回 ErrLimitExceeded，0 表示不限
+	MaxChangedFiles int
+	// MaxChangesPerFile 单个文件中被修改的节点数上限，超出时同上，0 表示不限
+	MaxChangesPerFile int
+
+	// RegexTimeout 单次正则匹配（路径条件与 regex_replace）的超时，0 表示不限
+	RegexTimeout time.Duration
+	// RuleTimeout 单条规则在一个文档上正则匹配的总耗时上限，0 表示不限
+	RuleTimeout time.Duration
+}
+
+// hasLimits 是否设置了变更上限
+func (o Options) hasLimits() bool {
+	return o.MaxChangedFiles > 0 || o.MaxChangesPerFile > 0
+}
+
+// selected 判断规则是否在本次执行范围内
+func (o Options) selected(r *engine.Rule) bool {
+	return o.OnlyPathPrefix == "" || path.HasPrefix(r.Path, o.OnlyPathPrefix)
+}
+
+// SetOptions 设置处理选项
+func (p *Processor) SetOptions(opts Options) {
+	p.opts = opts
+	p.engine.SetTimeouts(opts.RegexTimeout, opts.RuleTimeout)
+	p.engine.SetStrictTypes(opts.StrictTypes)
+	p.trackChanges()
+}
@@ -0,0 +1,75 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrDirOverlap 输出目录与输入目录相同，或输入目录位于输出目录之中
+var ErrDirOverlap = errors.New("output directory overlaps input directory")
+
+// outputSubtree 检查输出目录与输入目录的关系（按解析符号链接后的绝对路径比较）
+// 两者相同或输入目录位于输出目录之中时返回 ErrDirOverlap：前者绕过了原地修改的确认，
+// 后者写出的文件可能覆盖尚未处理的输入；输出目录位于输入目录之中时，
+// 返回遍历输入目录时应跳过的输出子目录（以 inputDir 为前缀），否则返回空串
+func outputSubtree(inputDir, outputDir string) (string, error) {
+	if outputDir == "" {
+		return "", nil
+	}
+	in, err := resolveDir(inputDir)
+	if err != nil {
+		return "", fmt.Errorf("resolve input dir: %w", err)
+	}
+	out, err := resolveDir(outputDir)
+	if err != nil {
+		return "", fmt.Errorf("resolve output dir: %w", err)
+	}
+
+	switch {
+	case in == out:
+		return "", fmt.Errorf("%w: both are %s, use in-place mode instead", ErrDirOverlap, reportPath(inputDir))
+	case within(in, out):
+		return "", fmt.Errorf("%w: input %s is inside output %s", ErrDirOverlap, reportPath(inputDir), reportPath(outputDir))
+	case within(out, in):
+		rel, err := filepath.Rel(in, out)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(inputDir, rel), nil
+	}
+	return "", nil
+}
+
+// resolveDir 返回目录的绝对路径并解析符号链接；目录尚不存在时解析最近的已存在上级
+func resolveDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(abs)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, missing[i])
+			}
+			return resolved, nil
+		}
+		parent := filepath.Dir(abs)
+		if !os.IsNotExist(err) || parent == abs {
+			return "", err
+		}
+		missing = append(missing, filepath.Base(abs))
+		abs = parent
+	}
+}
+
+// within 判断 path 是否位于 dir 之下（不含 dir 本身），两者均为清理过的绝对路径
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,105 @@
+package processor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glesirok/yamleditor/pkg/rule"
+)
+
+func TestOutputSubtree(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"in/sub", "in2", "out"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	symlink(t, filepath.Join(root, "in"), filepath.Join(root, "in-link"))
+	symlink(t, filepath.Join(root, "in", "sub"), filepath.Join(root, "sub-link"))
+	t.Chdir(root)
+
+	tests := []struct {
+		name    string
+		in, out string
+		want    string // 应跳过的输出子目录
+		overlap bool
+	}{
+		{name: "no output", in: "in"},
+		{name: "separate directories", in: "in", out: "out"},
+		{name: "sibling sharing a name prefix", in: "in", out: "in2"},
+		{name: "same directory", in: "in", out: "in", overlap: true},
+		{name: "same directory, relative spellings", in: "./in/", out: "in/sub/..", overlap: true},
+		{name: "same directory, absolute and relative", in: filepath.Join(root, "in"), out: "in", overlap: true},
+		{name: "same directory through a symlink", in: "in", out: "in-link", overlap: true},
+		{name: "input nested in output", in: "in/sub", out: "in", overlap: true},
+		{name: "input nested in output through a symlink", in: "sub-link", out: "in", overlap: true},
+		{name: "output nested in input", in: "in", out: "in/sub", want: filepath.Join("in", "sub")},
+		{name: "output nested in input, not created yet", in: "in", out: "in/new/dir", want: filepath.Join("in", "new", "dir")},
+		{name: "output nested in input, relative spelling", in: "./in", out: "out/../in/sub", want: filepath.Join("in", "sub")},
+		{name: "output nested in input through a symlink", in: "in", out: "sub-link", want: filepath.Join("in", "sub")},
+		{name: "output nested in symlinked input", in: "in-link", out: "in/sub", want: filepath.Join("in-link", "sub")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := outputSubtree(tt.in, tt.out)
+			if tt.overlap {
+				if !errors.Is(err, ErrDirOverlap) {
+					t.Fatalf("outputSubtree(%q, %q) error = %v, want ErrDirOverlap", tt.in, tt.out, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("outputSubtree(%q, %q) error = %v", tt.in, tt.out, err)
+			}
+			if got != tt.want {
+				t.Errorf("outputSubtree(%q, %q) = %q, want %q", tt.in, tt.out, got, tt.want)
+			}
+		})
+	}
+}
+
+// 输出目录位于输入目录之中时，再次运行不会把上次写出的文件当作输入
+func TestProcessDirectoryNestedOutput(t *testing.T) {
+	root := t.TempDir()
+	in := filepath.Join(root, "in")
+	out := filepath.Join(in, "out")
+	if err := os.MkdirAll(in, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(in, "app.yaml"), []byte("replicas: 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := rule.ParseInline([]byte(`{action: replace, path: replicas, value: 3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for run := 1; run <= 2; run++ {
+		result, err := NewProcessorFromConfig(config).ProcessDirectory(in, out, false, false)
+		if err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+		if result.TotalFiles != 1 {
+			t.Errorf("run %d: TotalFiles = %d, want 1", run, result.TotalFiles)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(out, "app.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "replicas: 3\n" {
+		t.Errorf("output = %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(out, "out")); !os.IsNotExist(err) {
+		t.Errorf("output directory was processed as input: %v", err)
+	}
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+}
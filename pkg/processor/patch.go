@@ -0,0 +1,261 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BackupFormat 原地修改时的备份格式
+type BackupFormat string
+
+const (
+	BackupCopy  BackupFormat = "copy"  // 完整复制原文件为 .bak（默认）
+	BackupPatch BackupFormat = "patch" // 保存为反向补丁 .orig.patch，patch 到修改后的文件上即恢复原文件
+)
+
+// patchContext 补丁中每个变更块前后保留的上下文行数
+const patchContext = 3
+
+// editKind 逐行编辑操作
+type editKind int
+
+const (
+	editEqual editKind = iota
+	editDelete
+	editInsert
+)
+
+type edit struct {
+	kind editKind
+	line string
+}
+
+// unifiedDiff 生成从 from 到 to 的 unified diff，内容相同时返回空串
+// 输出可直接用 patch(1) 应用: patch <to 对应的文件> < diff
+func unifiedDiff(fromName, toName string, from, to []byte) string {
+	edits := lineEdits(from, to)
+	hunks := hunkRanges(edits)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range hunks {
+		writeHunk(&buf, edits, h.lo, h.hi)
+	}
+	return buf.String()
+}
+
+// lineEdits 逐行比较 from 与 to，差异过大时退化为整体替换
+func lineEdits(from, to []byte) []edit {
+	a, b := splitLines(string(from)), splitLines(string(to))
+	edits, ok := diffLines(a, b)
+	if !ok {
+		edits = replaceAll(a, b)
+	}
+	return edits
+}
+
+// hunk 变更块在编辑序列中的范围 [lo, hi)，含前后上下文
+type hunk struct {
+	lo, hi int
+}
+
+// hunkRanges 把编辑序列划分为变更块，两处变更间的相同行不超过 2*patchContext 时合并为一个块
+func hunkRanges(edits []edit) []hunk {
+	var hunks []hunk
+	for start := 0; start < len(edits); {
+		// 找到下一处变更
+		for start < len(edits) && edits[start].kind == editEqual {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+
+		// 向后扩展
+		end := start
+		for end < len(edits) {
+			if edits[end].kind != editEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].kind == editEqual {
+				run++
+			}
+			if run == len(edits) || run-end > 2*patchContext {
+				break
+			}
+			end = run
+		}
+
+		lo := max(start-patchContext, 0)
+		hi := min(end+patchContext, len(edits))
+		hunks = append(hunks, hunk{lo, hi})
+		start = hi
+	}
+	return hunks
+}
+
+// hunkSpan 返回 edits[lo:hi] 在原内容与新内容中的起始行号和行数
+// 行数为 0 时起始行号指向块之前的一行
+func hunkSpan(edits []edit, lo, hi int) (aLine, aCount, bLine, bCount int) {
+	// 块之前的行数决定起始行号
+	aLine, bLine = 1, 1
+	for _, e := range edits[:lo] {
+		if e.kind != editInsert {
+			aLine++
+		}
+		if e.kind != editDelete {
+			bLine++
+		}
+	}
+
+	for _, e := range edits[lo:hi] {
+		if e.kind != editInsert {
+			aCount++
+		}
+		if e.kind != editDelete {
+			bCount++
+		}
+	}
+	if aCount == 0 {
+		aLine--
+	}
+	if bCount == 0 {
+		bLine--
+	}
+	return aLine, aCount, bLine, bCount
+}
+
+// writeHunk 输出 edits[lo:hi] 对应的变更块
+func writeHunk(buf *strings.Builder, edits []edit, lo, hi int) {
+	aLine, aCount, bLine, bCount := hunkSpan(edits, lo, hi)
+	fmt.Fprintf(buf, "@@ -%d,%d +%d,%d @@\n", aLine, aCount, bLine, bCount)
+	for _, e := range edits[lo:hi] {
+		buf.WriteString(e.kind.prefix() + e.line)
+		if !strings.HasSuffix(e.line, "\n") {
+			buf.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// prefix 返回 unified diff 中该编辑的行首标记
+func (k editKind) prefix() string {
+	switch k {
+	case editDelete:
+		return "-"
+	case editInsert:
+		return "+"
+	}
+	return " "
+}
+
+// splitLines 按行切分并保留换行符
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// maxEdits diffLines 搜索的最大编辑距离，超过后放弃逐行比对，避免整体改写的大文件耗时耗内存
+const maxEdits = 2000
+
+// diffLines Myers 差分算法，返回把 a 变为 b 的最短编辑序列
+// 编辑距离超过 maxEdits 时返回 false
+func diffLines(a, b []string) ([]edit, bool) {
+	n, m := len(a), len(b)
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	// trace[d] 保存第 d 步开始前 v[offset-d : offset+d+1]，用于回溯
+	var trace [][]int
+
+	found := false
+	for d := 0; d <= n+m && d <= maxEdits; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	// 回溯得到编辑序列（逆序）
+	var edits []edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = at(prevK)
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{editEqual, a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				edits = append(edits, edit{editInsert, b[y]})
+			} else {
+				x--
+				edits = append(edits, edit{editDelete, a[x]})
+			}
+		}
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits, true
+}
+
+// replaceAll 整体替换的编辑序列：删除 a 的全部行后插入 b 的全部行
+func replaceAll(a, b []string) []edit {
+	edits := make([]edit, 0, len(a)+len(b))
+	for _, line := range a {
+		edits = append(edits, edit{editDelete, line})
+	}
+	for _, line := range b {
+		edits = append(edits, edit{editInsert, line})
+	}
+	return edits
+}
@@ -2,13 +2,20 @@ package processor
 
 import (
 	"bytes"
+	"cmp"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 	"github.com/glesirok/yamleditor/pkg/engine"
+	"github.com/glesirok/yamleditor/pkg/path"
 	"github.com/glesirok/yamleditor/pkg/rule"
 )
 
@@ -17,6 +24,112 @@ type ProcessResult struct {
 	TotalFiles   int
 	SuccessFiles int
 	FailedFiles  []FailedFile
+	Rules        []RuleStats  // 每条规则在整个批次上的汇总，顺序与规则文件一致
+	Unchanged    []string     // 输出与原文件/已有输出相同、未重写的文件
+	Stale        []string     // CompareOutput: 输出目录中没有对应输入的文件
+	Cached       int          // 命中增量缓存而跳过的文件数
+	Aborted      bool         // FailFast: 因文件失败提前停止，之后的文件未处理
+	Files        []FileReport // 每个文件的处理结果，按处理顺序
+	Warnings     []string     // 成功处理的文件产生的警告，按处理顺序
+}
+
+// FileReport 目录模式下单个文件的处理结果
+type FileReport struct {
+	Path   string     // 输入路径，以 / 分隔
+	Output string     // 输出路径，以 / 分隔
+	Status FileStatus // 成功时的输出状态
+	Cached bool       // 命中增量缓存
+	Error  error      // 失败原因，nil 表示成功
+
+	Rules    []RuleStats // 每条规则在该文件上的统计，顺序与规则文件一致；失败的文件为空
+	Changes  []Event     // Options.RecordChanges: 该文件的逐节点修改
+	Warnings []string    // 该文件处理时的警告
+}
+
+// FileStatus 文件的输出状态
+type FileStatus string
+
+const (
+	StatusWritten   FileStatus = "written"   // 已写入
+	StatusCreated   FileStatus = "created"   // CompareOutput: 输出文件原本不存在
+	StatusUpdated   FileStatus = "updated"   // 原地修改或 CompareOutput: 内容有变化
+	StatusUnchanged FileStatus = "unchanged" // 输出与原文件/已有输出相同，未写入
+)
+
+// FileResult 单文件处理结果
+type FileResult struct {
+	Status FileStatus
+	Cached bool        // 命中增量缓存，未重新解析和执行规则
+	Rules  []RuleStats // 每条规则在该文件上的统计，顺序与规则文件一致
+
+	// Changes 逐节点的修改记录，按执行顺序排列，仅在 Options.RecordChanges 时填充；
+	// 被 OnRuleApplied 否决的修改不记录，命中缓存的文件没有记录
+	Changes []Event
+
+	// Warnings 处理时的警告（弃用的规则、通配展开时跳过的元素、重复键等），命中缓存的文件没有警告
+	Warnings []string
+}
+
+// RuleStats 单条规则的执行统计
+type RuleStats struct {
+	Rule         *engine.Rule
+	FilesMatched int           // 至少匹配到一个节点的文件数
+	NodesMatched int           // 匹配到的节点数
+	NodesChanged int           // 实际修改的节点数
+	Replacements int           // regex_replace 的替换次数
+	Vetoed       int           // 被 OnRuleApplied 否决的次数
+	FilesSkipped int           // 因 continue_on_not_found 路径未找到而跳过的文件数
+	Duration     time.Duration // 累计执行耗时
+	Filtered     bool          // 被 OnlyPathPrefix 筛选掉，未执行
+}
+
+// add 累加另一份统计
+func (s *RuleStats) add(o RuleStats) {
+	s.FilesMatched += o.FilesMatched
+	s.NodesMatched += o.NodesMatched
+	s.NodesChanged += o.NodesChanged
+	s.Replacements += o.Replacements
+	s.Vetoed += o.Vetoed
+	s.FilesSkipped += o.FilesSkipped
+	s.Duration += o.Duration
+	s.Filtered = s.Filtered || o.Filtered
+}
+
+// Skipped 返回因 continue_on_not_found 路径未找到而在至少一个文件上跳过的规则
+func Skipped(stats []RuleStats) []RuleStats {
+	var skipped []RuleStats
+	for _, s := range stats {
+		if s.FilesSkipped > 0 {
+			skipped = append(skipped, s)
+		}
+	}
+	return skipped
+}
+
+// Unused 返回执行过但在所有文件上都没有匹配到节点的规则，被筛选跳过的规则不计入
+func Unused(stats []RuleStats) []RuleStats {
+	var unused []RuleStats
+	for _, s := range stats {
+		if !s.Filtered && s.NodesMatched == 0 {
+			unused = append(unused, s)
+		}
+	}
+	return unused
+}
+
+// merge 将单文件统计合并进批次汇总
+// 按规则身份合并，批次中途重新加载规则时新规则追加在后面
+func (r *ProcessResult) merge(fr *FileResult) {
+	for _, s := range fr.Rules {
+		i := 0
+		for i < len(r.Rules) && r.Rules[i].Rule != s.Rule {
+			i++
+		}
+		if i == len(r.Rules) {
+			r.Rules = append(r.Rules, RuleStats{Rule: s.Rule})
+		}
+		r.Rules[i].add(s)
+	}
 }
 
 // FailedFile 失败文件信息
@@ -27,31 +140,257 @@ type FailedFile struct {
 
 // Processor 批量处理 YAML 文件
 type Processor struct {
-	rules  []*engine.Rule
-	engine *engine.Engine
+	ruleFile string
+	rulesMu  sync.RWMutex
+	rules    []*engine.Rule
+	params   map[string]rule.Param // 规则文件声明的参数，随规则一起重新加载
+	values   map[string]string     // 调用方提供的参数值
+	settings rule.Settings         // 规则文件的运行设置
+	engine   *engine.Engine
+	hooks    Hooks
+	opts     Options
+	cache    *Cache
+	journal  *Journal              // 变更日志，nil 表示不记录
+	events   func(Event) error     // 修改事件回调，nil 表示关闭
+	warnings func(string)          // 警告回调，nil 表示只记入结果
+	encoder  Encoder               // 输出编码器，nil 表示 preserve
+	warned   map[*engine.Rule]bool // 已输出过弃用警告的规则
 }
 
 // NewProcessor 创建处理器
 func NewProcessor(ruleFile string) (*Processor, error) {
-	rules, err := rule.LoadFromFile(ruleFile)
+	config, err := rule.LoadConfig(ruleFile)
 	if err != nil {
 		return nil, fmt.Errorf("load rules: %w", err)
 	}
 
+	p := NewProcessorFromConfig(config)
+	p.ruleFile = ruleFile
+	return p, nil
+}
+
+// NewProcessorFromConfig 由已加载的配置创建处理器，不对应规则文件，不支持 Reload
+func NewProcessorFromConfig(config *rule.Config) *Processor {
 	return &Processor{
-		rules:  rules,
-		engine: engine.NewEngine(),
-	}, nil
+		rules:    config.Rules,
+		params:   config.Params,
+		settings: config.Settings,
+		engine:   engine.NewEngine(),
+	}
+}
+
+// Settings 返回规则文件的运行设置
+func (p *Processor) Settings() rule.Settings {
+	p.rulesMu.RLock()
+	defer p.rulesMu.RUnlock()
+	return p.settings
+}
+
+// currentRules 返回当前生效的规则集
+// 每个文件开始处理时取一次，处理过程中不受重新加载影响
+func (p *Processor) currentRules() []*engine.Rule {
+	p.rulesMu.RLock()
+	defer p.rulesMu.RUnlock()
+	return p.rules
+}
+
+// SetParams 设置规则参数值，并按规则文件的声明校验
+// 缺少必需参数时返回的错误列出所有缺失项，此时处理任何文件都会失败
+func (p *Processor) SetParams(values map[string]string) error {
+	p.rulesMu.Lock()
+	p.values = values
+	p.rulesMu.Unlock()
+
+	_, err := p.currentParams()
+	return err
+}
+
+// currentParams 返回合并默认值后的参数
+func (p *Processor) currentParams() (map[string]string, error) {
+	p.rulesMu.RLock()
+	defer p.rulesMu.RUnlock()
+	return rule.ResolveParams(p.params, p.values)
+}
+
+// SetCipher 设置 encrypt/decrypt 规则使用的加解密后端
+func (p *Processor) SetCipher(c engine.Cipher) {
+	p.engine.SetCipher(c)
+}
+
+// SetEnviron 设置规则可读取的环境变量，代替进程的环境变量，见 engine.Engine.SetEnviron
+func (p *Processor) SetEnviron(env map[string]string) {
+	p.engine.SetEnviron(env)
 }
 
 // ProcessFile 处理单个 YAML 文件
-func (p *Processor) ProcessFile(inputPath, outputPath string, dryRun bool) error {
+// 原地修改（outputPath == inputPath）且 Options.Backup 开启时，内容有变化才备份原文件
+func (p *Processor) ProcessFile(inputPath, outputPath string, dryRun bool) (*FileResult, error) {
+	return p.processFile(inputPath, outputPath, dryRun, p.opts.Backup)
+}
+
+func (p *Processor) processFile(inputPath, outputPath string, dryRun, backup bool) (*FileResult, error) {
+	f, err := p.planFile(inputPath, outputPath, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkLimits([]*plannedFile{f}); err != nil {
+		return nil, err
+	}
+	if err := p.commitFile(f, dryRun, backup); err != nil {
+		return nil, err
+	}
+	return f.result, nil
+}
+
+// plannedFile 已执行完规则、尚未写出的文件
+type plannedFile struct {
+	input, output        string
+	original, content    []byte // 原内容与处理结果
+	result               *FileResult
+	inPlace              bool
+	useCache             bool
+	inputHash, rulesHash string
+}
+
+// changed 处理结果与原内容是否不同，命中缓存的文件视为没有变化
+func (f *plannedFile) changed() bool {
+	return !f.result.Cached && !bytes.Equal(f.original, f.content)
+}
+
+// planFile 读取文件并执行规则，不写出任何内容
+func (p *Processor) planFile(inputPath, outputPath string, dryRun bool) (*plannedFile, error) {
 	// 读取文件
 	data, err := os.ReadFile(inputPath)
 	if err != nil {
-		return fmt.Errorf("read file: %w", err)
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+	f := &plannedFile{input: inputPath, output: outputPath, original: data}
+
+	params, err := p.currentParams()
+	if err != nil {
+		return nil, err
+	}
+
+	// 输入与规则都未变化时直接跳过
+	rules := p.currentRules()
+	env, volatile := engine.TemplateEnv(rules)
+	// 用到 now 等函数时每次执行的结果可能不同，不使用缓存
+	f.useCache = p.cacheEnabled(dryRun) && !volatile
+	if f.useCache {
+		f.inputHash = digest(data)
+		if f.rulesHash, err = rulesDigest(rules, params, environ(env, p.engine.LookupEnv), p.opts, p.outputEncoder().Format()); err != nil {
+			return nil, err
+		}
+		if e, ok := p.cache.lookup(inputPath, outputPath, f.inputHash, f.rulesHash); ok {
+			f.result = cachedResult(rules, e, p.opts)
+			return f, nil
+		}
+	}
+
+	f.result = &FileResult{Rules: make([]RuleStats, len(rules))}
+	if f.content, err = p.transform(data, inputPath, rules, params, f.result); err != nil {
+		return nil, err
+	}
+
+	// 原地修改时与原内容比较，没有变化就不写也不备份
+	f.inPlace = filepath.Clean(outputPath) == filepath.Clean(inputPath)
+	f.result.Status = StatusWritten
+	switch {
+	case f.inPlace && bytes.Equal(f.original, f.content):
+		f.result.Status = StatusUnchanged
+	case f.inPlace:
+		f.result.Status = StatusUpdated
+	case p.opts.CompareOutput:
+		f.result.Status, err = compareOutput(outputPath, f.content)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// commitFile 写出 planFile 的结果；dry-run 时只输出预览
+func (p *Processor) commitFile(f *plannedFile, dryRun, backup bool) error {
+	result, output := f.result, f.content
+	if result.Cached {
+		return nil
+	}
+
+	if dryRun && p.opts.CompareOutput {
+		fmt.Printf("%s: %s\n", result.Status, f.output)
+		return nil
 	}
 
+	if dryRun && p.opts.Check && p.opts.DiffFormat == DiffNone {
+		return nil
+	}
+
+	if dryRun && p.opts.DiffFormat != DiffNone {
+		diff, err := formatDiff(p.opts.DiffFormat, f.input, f.output, f.original, output)
+		if err != nil {
+			return err
+		}
+		fmt.Print(diff)
+		return nil
+	}
+
+	if dryRun {
+		fmt.Printf("=== Dry-run: %s ===\n", f.input)
+		for i, stats := range result.Rules {
+			if stats.Rule.Action == engine.ActionRegexReplace {
+				fmt.Printf("# rule %d, path:{%s}: %d replacement(s)\n", i, stats.Rule.Path, stats.Replacements)
+			}
+		}
+		fmt.Println(string(output))
+		fmt.Println()
+		return nil
+	}
+
+	if result.Status == StatusUnchanged {
+		if f.useCache {
+			p.cache.put(f.input, f.output, f.inputHash, f.rulesHash, output, result)
+		}
+		return nil
+	}
+
+	if backup && f.inPlace {
+		if err := p.backup(f.input, f.original, output); err != nil {
+			return fmt.Errorf("backup file: %w", err)
+		}
+	}
+
+	// 确保输出目录存在
+	if outputDir := filepath.Dir(f.output); outputDir != "." {
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			return fmt.Errorf("create output dir: %w", err)
+		}
+	}
+
+	if p.journal != nil {
+		if err := p.journal.record(f.output, output, p.ruleFile, p.currentRules()); err != nil {
+			return fmt.Errorf("journal: %w", err)
+		}
+	}
+
+	// 写入临时文件后 rename 覆盖，中途失败时原文件不受影响
+	if err := writeAtomic(f.output, output); err != nil {
+		if !p.opts.AllowNonAtomic || !errors.Is(err, ErrOwnerNotPreserved) {
+			return fmt.Errorf("write file: %w", err)
+		}
+		if werr := writeInPlace(f.output, output); werr != nil {
+			return fmt.Errorf("write file: %w", werr)
+		}
+		p.warn(result, "%s: %v; wrote the file in place, not atomically", reportPath(f.output), err)
+	}
+
+	if f.useCache {
+		p.cache.put(f.input, f.output, f.inputHash, f.rulesHash, output, result)
+	}
+	return nil
+}
+
+// transform 解析 data、依次应用规则并按输出编码器重新序列化，保留 BOM
+func (p *Processor) transform(data []byte, file string, rules []*engine.Rule, params map[string]string, result *FileResult) ([]byte, error) {
 	// 检测并移除 UTF-8 BOM
 	hasBOM := false
 	if bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}) {
@@ -59,54 +398,477 @@ func (p *Processor) ProcessFile(inputPath, outputPath string, dryRun bool) error
 		data = data[3:] // 移除BOM，传递给yaml解析器
 	}
 
-	// 解析 YAML
-	var root yaml.Node
-	if err := yaml.Unmarshal(data, &root); err != nil {
-		return fmt.Errorf("parse yaml: %w", err)
+	// 模板表达式替换为占位符后再解析，输出时还原
+	var mask *templateMask
+	if p.opts.Templated {
+		var err error
+		if data, mask, err = maskTemplates(data); err != nil {
+			return nil, err
+		}
+	}
+
+	jsonInput, err := p.opts.jsonInput(file, data)
+	if err != nil {
+		return nil, err
+	}
+	enc := p.encoderFor(jsonInput)
+	if mask != nil && enc.Format() == FormatJSON {
+		return nil, fmt.Errorf("templated input cannot be written as JSON")
 	}
 
-	// 应用所有规则
-	for i, r := range p.rules {
-		if err := p.engine.Apply(&root, r); err != nil {
-			// 由规则logic决定是否忽略错误
-			return fmt.Errorf("apply rule %d, path:{%s}: %w", i, r.Path, err)
+	// 开头的注释块单独保留，不参与解析
+	header, data := splitHeader(data)
+
+	// 解析 YAML，文件可包含以 --- 分隔的多个文档
+	docs, err := decodeDocuments(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse yaml: %w", err)
+	}
+	if err := p.checkDuplicateKeys(file, docs, bytes.Count(header, []byte("\n")), result); err != nil {
+		return nil, err
+	}
+	for i, r := range rules {
+		result.Rules[i].Rule = r
+		result.Rules[i].Filtered = !p.opts.selected(r)
+	}
+	if header, err = p.applyHeaderRules(rules, file, header, result); err != nil {
+		return nil, err
+	}
+
+	selected, err := p.selectDocuments(docs)
+	if err != nil {
+		return nil, err
+	}
+	if p.opts.Extract {
+		// 只输出选中的文档，排版按这些文档的原文恢复
+		docs, data = extract(docs, selected, data)
+		selected = nil
+	}
+
+	// 没有任何文档（空文件或只有注释）时原样输出
+	output := data
+	if len(docs) > 0 {
+		// 多个文档时，规则只要在其中一个文档上找到节点即可
+		var missed []error
+		nonEmpty := 0
+		for i, root := range docs {
+			if (selected == nil || selected[i]) && !isEmptyDocument(root) {
+				nonEmpty++
+			}
+		}
+		if nonEmpty > 1 {
+			missed = make([]error, len(rules))
+		}
+
+		p.engine.SetParams(params)
+		for i, root := range docs {
+			if (selected != nil && !selected[i]) || isEmptyDocument(root) {
+				continue
+			}
+			if err := p.applyRules(rules, &Document{File: file, Index: i, Root: root}, result, missed); err != nil {
+				return nil, err
+			}
+		}
+		for i, err := range missed {
+			if err != nil && result.Rules[i].NodesMatched == 0 {
+				return nil, err
+			}
+		}
+		// 匹配文件数按文件计，不按文档计；规则在任一文档上找到节点时不算跳过
+		for i := range result.Rules {
+			s := &result.Rules[i]
+			s.FilesMatched = min(s.FilesMatched, 1)
+			if s.FilesMatched > 0 {
+				s.FilesSkipped = 0
+			}
+			s.FilesSkipped = min(s.FilesSkipped, 1)
+		}
+
+		if p.opts.Normalize {
+			for i, root := range docs {
+				if selected == nil || selected[i] {
+					engine.Normalize(root)
+				}
+			}
+		}
+
+		if err := p.validateSchema(docs); err != nil {
+			return nil, err
+		}
+
+		if output, err = enc.Encode(docs, data); err != nil {
+			return nil, err
+		}
+		// 规则没有修改任何内容时原样输出，不因重新编码改变格式（如流式序列中的空白）
+		if e, ok := enc.(preserveEncoder); ok && e.indent <= 0 && !bytes.Equal(output, data) && reencoded(enc, data, output) {
+			output = data
+		}
+	}
+
+	// JSON 不支持注释，不输出注释块；注释块之后原有的 --- 需保留，否则注释会并入第一个文档
+	if enc.Format() != FormatJSON {
+		if len(header) > 0 && bytes.HasPrefix(data, []byte("---")) && !bytes.HasPrefix(output, []byte("---")) {
+			output = slices.Concat([]byte("---\n"), output)
 		}
+		output = slices.Concat(header, output)
 	}
 
-	// 序列化 YAML（保持2空格缩进）
-	var buf strings.Builder
-	encoder := yaml.NewEncoder(&buf)
-	encoder.SetIndent(2)
-	if err := encoder.Encode(&root); err != nil {
-		return fmt.Errorf("marshal yaml: %w", err)
+	if mask != nil {
+		output = mask.restore(output)
 	}
-	encoder.Close()
-	output := []byte(buf.String())
 
 	// 如果原文件有 BOM，添加回去
 	if hasBOM {
 		output = append([]byte{0xEF, 0xBB, 0xBF}, output...)
 	}
 
-	if dryRun {
-		fmt.Printf("=== Dry-run: %s ===\n", inputPath)
-		fmt.Println(string(output))
-		fmt.Println()
+	return output, nil
+}
+
+// decodeDocuments 按顺序解析 data 中的所有文档
+func decodeDocuments(data []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var root yaml.Node
+		err := dec.Decode(&root)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, &root)
+	}
+}
+
+// reencoded 判断 output 是否只是 data 原样重新编码的结果，即原文重新编码后与 output 相同
+// 规则统计中的修改数不足以判断（replace 为相同的值也计为修改），因此比较编码结果
+func reencoded(enc Encoder, data, output []byte) bool {
+	docs, err := decodeDocuments(data)
+	if err != nil {
+		return false
+	}
+	original, err := enc.Encode(docs, data)
+	return err == nil && bytes.Equal(original, output)
+}
+
+// isEmptyDocument 判断是否为 --- 之间没有内容的空文档，如文件末尾多余的 ---
+func isEmptyDocument(root *yaml.Node) bool {
+	if len(root.Content) != 1 || root.HeadComment != "" || root.FootComment != "" {
+		return false
+	}
+	n := root.Content[0]
+	return n.Kind == yaml.ScalarNode && n.Tag == "!!null" && n.Value == "" && n.Style == 0 &&
+		n.HeadComment == "" && n.LineComment == "" && n.FootComment == ""
+}
+
+// isNotFound 判断规则错误是否只是在当前文档中找不到节点；类型不符等结构错误不算，照常使文件失败
+func isNotFound(err error) bool {
+	return errors.Is(err, engine.ErrNotFoundNodes) || errors.Is(err, engine.ErrPathNotFound) ||
+		errors.Is(err, engine.ErrNoMatch)
+}
+
+// clearMergeTags 清除合并键上的 !!merge 标签，标签为空时按值解析仍是合并键
+func clearMergeTags(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!merge" {
+		node.Tag = ""
+	}
+	for _, child := range node.Content {
+		clearMergeTags(child)
+	}
+}
+
+// backup 按 BackupFormat 备份原地修改前的内容
+// patch 格式只保存从修改后内容恢复原内容的反向补丁: patch file < file.orig.patch
+func (p *Processor) backup(file string, original, output []byte) error {
+	if p.opts.BackupFormat == BackupPatch {
+		name := filepath.Base(file)
+		diff := unifiedDiff(name, name, output, original)
+		return os.WriteFile(file+".orig.patch", []byte(diff), 0644)
+	}
+	return os.WriteFile(file+".bak", original, 0644)
+}
+
+// applyRules 对单个文档依次应用所有规则，统计写入 result
+// missed 非 nil 时（多文档文件）规则在本文档上未找到节点不立即报错，错误记入 missed[i]，
+// 由调用方在所有文档处理完后对整个文件都未匹配的规则报错
+func (p *Processor) applyRules(rules []*engine.Rule, doc *Document, result *FileResult, missed []error) error {
+	if p.hooks.OnDocumentStart != nil {
+		if err := p.hooks.OnDocumentStart(doc); err != nil {
+			return fmt.Errorf("document start hook: %w", err)
+		}
+	}
+
+	// capture 变量仅在当前文档内有效
+	p.engine.Reset()
+	p.engine.SetFile(doc.File)
+	for i, r := range rules {
+		result.Rules[i].Rule = r
+		if !p.opts.selected(r) {
+			result.Rules[i].Filtered = true
+			continue
+		}
+		// set_header 作用于整个文件，已在解析前执行
+		if r.Action == engine.ActionSetHeader {
+			continue
+		}
+		// files 限定了文件范围的规则在其他文件上不执行
+		if len(r.Files) > 0 {
+			ok, err := matchAny(r.Files, doc.File)
+			if err != nil {
+				return fmt.Errorf("rule %d, files: %w", i, err)
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		// 有 OnRuleApplied 时先保存文档与 capture 变量的快照，以便否决后恢复
+		var snapshot *yaml.Node
+		var vars map[string]*engine.Capture
+		if p.hooks.OnRuleApplied != nil {
+			snapshot = path.CopyTree(doc.Root)
+			vars = p.engine.SaveVars()
+		}
+
+		if r.Deprecated != "" && !p.warned[r] {
+			if p.opts.Strict {
+				return fmt.Errorf("rule %d, path:{%s}: deprecated: %s (strict)", i, r.Path, r.Deprecated)
+			}
+			// 每条规则只警告一次，避免批量处理时刷屏
+			p.warn(result, "rule %d, path:{%s} is deprecated: %s", i, r.Path, r.Deprecated)
+			if p.warned == nil {
+				p.warned = map[*engine.Rule]bool{}
+			}
+			p.warned[r] = true
+		}
+
+		applied := r
+		if p.opts.ContinueOnNotFound && !r.ContinueOnNotFound {
+			cp := *r
+			cp.ContinueOnNotFound = true
+			applied = &cp
+		}
+		if p.opts.PruneEmpty && r.Action == engine.ActionDelete && !r.PruneEmpty {
+			cp := *applied
+			cp.PruneEmpty = true
+			applied = &cp
+		}
+
+		start := time.Now()
+		res, err := p.engine.Apply(doc.Root, applied)
+		if err != nil {
+			err = fmt.Errorf("apply rule %d, path:{%s}: %w", i, r.Path, err)
+			if missed != nil && isNotFound(err) {
+				missed[i] = cmp.Or(missed[i], err)
+				continue
+			}
+			// 由规则logic决定是否忽略错误
+			return err
+		}
+		if p.opts.Strict && !res.Inapplicable {
+			if res.Matched == 0 {
+				err := fmt.Errorf("rule %d, path:{%s}: matched no nodes (strict)", i, r.Path)
+				if missed == nil {
+					return err
+				}
+				missed[i] = cmp.Or(missed[i], err)
+			}
+			if len(res.Skipped) > 0 {
+				return fmt.Errorf("rule %d, path:{%s}: skipped %s (strict)", i, r.Path, strings.Join(res.Skipped, "; "))
+			}
+			if len(res.Warnings) > 0 {
+				return fmt.Errorf("rule %d, path:{%s}: %s (strict)", i, r.Path, strings.Join(res.Warnings, "; "))
+			}
+		}
+		for _, skipped := range res.Skipped {
+			p.warn(result, "%s: rule %d skipped %s", reportPath(doc.File), i, skipped)
+		}
+		for _, w := range res.Warnings {
+			p.warn(result, "%s: rule %d: %s", reportPath(doc.File), i, w)
+		}
+
+		stats := RuleStats{
+			Rule:         r,
+			NodesMatched: res.Matched,
+			NodesChanged: res.Changed,
+			Replacements: res.Replacements,
+			Duration:     time.Since(start),
+		}
+		if res.Matched > 0 {
+			stats.FilesMatched = 1
+		}
+		if res.Missing != nil {
+			stats.FilesSkipped = 1
+		}
+
+		if snapshot != nil {
+			err := p.hooks.OnRuleApplied(&RuleChange{Document: doc, RuleIndex: i, Rule: r, Result: res})
+			switch {
+			case errors.Is(err, ErrVeto):
+				*doc.Root = *snapshot
+				p.engine.RestoreVars(vars)
+				stats.NodesChanged, stats.Replacements = 0, 0
+				stats.Vetoed = 1
+			case err != nil:
+				return fmt.Errorf("rule applied hook: rule %d: %w", i, err)
+			}
+		}
+		if stats.Vetoed == 0 {
+			if err := p.emit(doc, i, r, res.Changes, result); err != nil {
+				return err
+			}
+		}
+		result.Rules[i].add(stats)
+		result.Rules[i].Rule = r
+	}
+
+	if p.hooks.OnDocumentEnd != nil {
+		if err := p.hooks.OnDocumentEnd(doc, result); err != nil {
+			return fmt.Errorf("document end hook: %w", err)
+		}
+	}
+	return nil
+}
+
+// compareOutput 比较生成内容与输出路径上已有的文件
+func compareOutput(outputPath string, output []byte) (FileStatus, error) {
+	existing, err := os.ReadFile(outputPath)
+	switch {
+	case os.IsNotExist(err):
+		return StatusCreated, nil
+	case err != nil:
+		return "", fmt.Errorf("read existing output: %w", err)
+	case bytes.Equal(existing, output):
+		return StatusUnchanged, nil
+	default:
+		return StatusUpdated, nil
+	}
+}
+
+// findStale 返回输出目录中没有对应输入文件的待处理文件（accepts 判断）
+func findStale(inputDir, outputDir string, accepts func(string) bool) ([]string, error) {
+	var stale []string
+	err := filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !accepts(path) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(outputDir, path)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(inputDir, relPath)); os.IsNotExist(err) {
+			stale = append(stale, reportPath(path))
+		}
 		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
 	}
+	return stale, err
+}
 
-	// 确保输出目录存在
-	if outputDir := filepath.Dir(outputPath); outputDir != "." {
-		if err := os.MkdirAll(outputDir, 0755); err != nil {
-			return fmt.Errorf("create output dir: %w", err)
+// reportPath 返回报告中使用的路径，统一为 / 分隔
+// Windows 上输入可能混用 \ 与 /，统一后报告与缓存在各平台间保持一致
+func reportPath(path string) string {
+	return filepath.ToSlash(path)
+}
+
+// isYAML 判断是否为 .yaml/.yml 文件
+func isYAML(path string) bool {
+	return strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")
+}
+
+// batchEntry 批量处理中的一个文件及其输出路径
+type batchEntry struct {
+	input, output string
+}
+
+// runBatch 依次处理文件，结果记录到 result
+// 设置了变更上限时先对全部文件执行规则，确认未超出上限后再统一写出；超出时不写任何文件
+func (p *Processor) runBatch(result *ProcessResult, entries []batchEntry, dryRun, backup bool) error {
+	if !p.opts.hasLimits() {
+		for _, e := range entries {
+			f, err := p.planFile(e.input, e.output, dryRun)
+			if err == nil {
+				err = p.commitFile(f, dryRun, backup)
+			}
+			if p.record(result, e, f, err) != nil {
+				break
+			}
+		}
+		return nil
+	}
+
+	var planned []*plannedFile
+	var plannedEntries []batchEntry
+	for _, e := range entries {
+		f, err := p.planFile(e.input, e.output, dryRun)
+		if err != nil {
+			if p.fail(result, e.input, err) != nil {
+				return nil
+			}
+			continue
+		}
+		planned = append(planned, f)
+		plannedEntries = append(plannedEntries, e)
+	}
+	if err := p.checkLimits(planned); err != nil {
+		return err
+	}
+	for i, f := range planned {
+		if p.record(result, plannedEntries[i], f, p.commitFile(f, dryRun, backup)) != nil {
+			break
 		}
 	}
+	return nil
+}
+
+// record 记录单个文件的处理结果，失败时按 fail 处理
+func (p *Processor) record(result *ProcessResult, e batchEntry, f *plannedFile, err error) error {
+	if err != nil {
+		return p.fail(result, e.input, err)
+	}
 
-	// 写入文件
-	if err := os.WriteFile(outputPath, output, 0644); err != nil {
-		return fmt.Errorf("write file: %w", err)
+	fileResult := f.result
+	result.SuccessFiles++
+	result.merge(fileResult)
+	result.Warnings = append(result.Warnings, fileResult.Warnings...)
+	if fileResult.Status == StatusUnchanged {
+		result.Unchanged = append(result.Unchanged, reportPath(e.output))
+	}
+	if fileResult.Cached {
+		result.Cached++
 	}
+	result.Files = append(result.Files, FileReport{
+		Path:   reportPath(e.input),
+		Output: reportPath(e.output),
+		Status: fileResult.Status,
+		Cached: fileResult.Cached,
 
+		Rules:    fileResult.Rules,
+		Changes:  fileResult.Changes,
+		Warnings: fileResult.Warnings,
+	})
+	return nil
+}
+
+// fail 记录失败文件，FailFast 时标记中止并返回 filepath.SkipAll 停止遍历，否则继续处理下一个文件
+func (p *Processor) fail(result *ProcessResult, path string, err error) error {
+	result.FailedFiles = append(result.FailedFiles, FailedFile{
+		Path:  reportPath(path),
+		Error: err,
+	})
+	result.Files = append(result.Files, FileReport{Path: reportPath(path), Error: err})
+	if p.opts.FailFast {
+		result.Aborted = true
+		return filepath.SkipAll
+	}
 	return nil
 }
 
@@ -114,15 +876,40 @@ func (p *Processor) ProcessFile(inputPath, outputPath string, dryRun bool) error
 func (p *Processor) ProcessDirectory(inputDir, outputDir string, dryRun, backup bool) (*ProcessResult, error) {
 	result := &ProcessResult{}
 
-	// 遍历目录
+	// 输出目录位于输入目录之中时跳过输出子目录，避免把刚写出（或上次写出）的文件当作输入
+	skipDir, err := outputSubtree(inputDir, outputDir)
+	if err != nil {
+		return result, err
+	}
+
+	// 遍历目录，收集全部文件后再处理
+	var entries []batchEntry
 	walkErr := filepath.Walk(inputDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			// filepath.Walk 本身的错误（如权限问题），直接返回终止遍历
 			return err
 		}
+		if info.IsDir() && path == skipDir {
+			return filepath.SkipDir
+		}
 
-		// 只处理 .yaml 和 .yml 文件
-		if info.IsDir() || (!strings.HasSuffix(path, ".yaml") && !strings.HasSuffix(path, ".yml")) {
+		// Include/Exclude 按相对输入目录的路径匹配，被排除的目录整个跳过
+		if rel, err := filepath.Rel(inputDir, path); err == nil && rel != "." {
+			if info.IsDir() {
+				excluded, err := p.opts.excludedDir(rel)
+				if err != nil {
+					return err
+				}
+				if excluded {
+					return filepath.SkipDir
+				}
+			} else if ok, err := p.opts.included(rel); err != nil || !ok {
+				return err
+			}
+		}
+
+		// 只处理 .yaml 和 .yml 文件（Format 为 json 时只处理 .json 文件）
+		if info.IsDir() || !p.opts.accepts(path) {
 			return nil
 		}
 
@@ -131,11 +918,7 @@ func (p *Processor) ProcessDirectory(inputDir, outputDir string, dryRun, backup
 		// 计算输出路径
 		relPath, err := filepath.Rel(inputDir, path)
 		if err != nil {
-			result.FailedFiles = append(result.FailedFiles, FailedFile{
-				Path:  path,
-				Error: fmt.Errorf("compute relative path: %w", err),
-			})
-			return nil // 继续处理下一个文件
+			return p.fail(result, path, fmt.Errorf("compute relative path: %w", err))
 		}
 
 		var outputPath string
@@ -144,30 +927,7 @@ func (p *Processor) ProcessDirectory(inputDir, outputDir string, dryRun, backup
 		} else {
 			outputPath = path // 原地修改
 		}
-
-		// 备份
-		if backup && !dryRun && outputDir == "" {
-			backupPath := path + ".bak"
-			if err := copyFile(path, backupPath); err != nil {
-				result.FailedFiles = append(result.FailedFiles, FailedFile{
-					Path:  path,
-					Error: fmt.Errorf("backup file: %w", err),
-				})
-				return nil // 继续处理下一个文件
-			}
-		}
-
-		// 处理文件
-		fmt.Printf("Processing: %s\n", path)
-		if err := p.ProcessFile(path, outputPath, dryRun); err != nil {
-			result.FailedFiles = append(result.FailedFiles, FailedFile{
-				Path:  path,
-				Error: err,
-			})
-			return nil // 继续处理下一个文件
-		}
-
-		result.SuccessFiles++
+		entries = append(entries, batchEntry{path, outputPath})
 		return nil
 	})
 
@@ -175,15 +935,20 @@ func (p *Processor) ProcessDirectory(inputDir, outputDir string, dryRun, backup
 	if walkErr != nil {
 		return result, walkErr
 	}
+	if result.Aborted {
+		return result, nil
+	}
+	if err := p.runBatch(result, entries, dryRun, backup); err != nil {
+		return result, err
+	}
 
-	return result, nil
-}
-
-// copyFile 复制文件
-func copyFile(src, dst string) error {
-	data, err := os.ReadFile(src)
-	if err != nil {
-		return err
+	if p.opts.CompareOutput && outputDir != "" {
+		stale, err := findStale(inputDir, outputDir, p.opts.accepts)
+		if err != nil {
+			return result, fmt.Errorf("scan output dir: %w", err)
+		}
+		result.Stale = stale
 	}
-	return os.WriteFile(dst, data, 0644)
+
+	return result, nil
 }
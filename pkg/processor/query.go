@@ -0,0 +1,91 @@
+package processor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/engine"
+	"github.com/glesirok/yamleditor/pkg/path"
+)
+
+// QueryMatch 路径在文档中匹配到的一个节点
+type QueryMatch struct {
+	File string
+	Doc  int        // 文档在文件中的序号
+	Path string     // 具体路径，如 spec.containers[0].image
+	Node *yaml.Node // 匹配的节点，别名已解析为锚点节点
+}
+
+// QueryResult 路径在一个文件上的查询结果
+type QueryResult struct {
+	Matches []QueryMatch
+	// Misses 未匹配的原因，每项为 "document N: 原因"，包括路径未找到的文档与通配展开时缺少字段的元素
+	Misses []string
+}
+
+// Query 解析 data 中的所有文档并返回 expr 匹配到的节点，不修改任何内容
+// sel 非 nil 时只查询满足选择器的文档；路径在文档中未找到不是错误，原因记入 Misses
+func Query(name string, data []byte, expr string, sel *engine.Selector) (*QueryResult, error) {
+	p, err := path.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("parse path: %w", err)
+	}
+	docs, err := decodeDocuments(bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF}))
+	if err != nil {
+		return nil, fmt.Errorf("%s: parse yaml: %w", name, err)
+	}
+
+	e := engine.NewEngine()
+	nav := &path.Navigator{}
+	result := &QueryResult{}
+	for i, root := range docs {
+		if isEmptyDocument(root) {
+			continue
+		}
+		if sel != nil {
+			ok, err := e.Selects(root, sel)
+			if err != nil {
+				return nil, fmt.Errorf("%s: select document %d: %w", name, i, err)
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		matches, misses, err := nav.FindWithMisses(root, p)
+		if errors.Is(err, path.ErrPathNotFound) || errors.Is(err, path.ErrNoMatch) {
+			result.Misses = append(result.Misses, fmt.Sprintf("document %d: %v", i, err))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: document %d: %w", name, i, err)
+		}
+		for _, m := range misses {
+			result.Misses = append(result.Misses, fmt.Sprintf("document %d: %s", i, m))
+		}
+		for _, m := range matches {
+			node := m.Node
+			for node.Kind == yaml.AliasNode && node.Alias != nil {
+				node = node.Alias
+			}
+			result.Matches = append(result.Matches, QueryMatch{File: name, Doc: i, Path: m.Path, Node: node})
+		}
+	}
+	return result, nil
+}
+
+// QueryValue 返回节点解码后的值，mapping 的键转为字符串，可直接编码为 JSON
+func QueryValue(node *yaml.Node) (interface{}, error) {
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return nil, err
+	}
+	return jsonValue(v), nil
+}
+
+// QueryYAML 把节点序列化为 YAML，缩进 2 个空格
+func QueryYAML(node *yaml.Node) ([]byte, error) {
+	return encodeYAML([]*yaml.Node{path.CopyTree(node)}, 2)
+}
@@ -0,0 +1,74 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/glesirok/yamleditor/pkg/rule"
+)
+
+// Reload 重新加载规则文件
+// 新规则全部通过校验、且已提供的参数满足新的参数声明后才整体替换；
+// 失败时返回错误，旧规则继续生效
+func (p *Processor) Reload() error {
+	if p.ruleFile == "" {
+		return fmt.Errorf("reload rules: processor has no rule file")
+	}
+	config, err := rule.LoadConfig(p.ruleFile)
+	if err != nil {
+		return fmt.Errorf("reload rules: %w", err)
+	}
+
+	p.rulesMu.Lock()
+	defer p.rulesMu.Unlock()
+	if _, err := rule.ResolveParams(config.Params, p.values); err != nil {
+		return fmt.Errorf("reload rules: %w", err)
+	}
+	p.rules = config.Rules
+	p.params = config.Params
+	p.settings = config.Settings
+	return nil
+}
+
+// WatchRules 轮询规则文件，内容变化时调用 Reload，供长驻模式使用
+// 每次重新加载后以其结果调用 onReload（可为 nil），ctx 取消时返回
+func (p *Processor) WatchRules(ctx context.Context, interval time.Duration, onReload func(error)) {
+	last := fileDigest(p.ruleFile)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		digest := fileDigest(p.ruleFile)
+		if digest == nil || bytes.Equal(digest, last) {
+			// 文件暂时不可读（如编辑器替换文件的间隙）时等待下一轮
+			continue
+		}
+		last = digest
+
+		err := p.Reload()
+		if onReload != nil {
+			onReload(err)
+		}
+	}
+}
+
+// fileDigest 返回文件内容的 sha256，读取失败返回 nil
+func fileDigest(path string) []byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
@@ -0,0 +1,31 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrSchemaInvalid 处理结果不符合 Options.Schema
+var ErrSchemaInvalid = errors.New("output does not match the schema")
+
+// validateSchema 按 Options.Schema 校验处理后的每个文档，有问题时返回列出全部问题的错误；
+// 定义中没有的类型不检查
+func (p *Processor) validateSchema(docs []*yaml.Node) error {
+	if p.opts.Schema == nil {
+		return nil
+	}
+	var problems []string
+	for i, root := range docs {
+		found, _ := p.opts.Schema.Validate(root)
+		for _, problem := range found {
+			problems = append(problems, fmt.Sprintf("document %d: %s", i, problem))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w:\n  %s", ErrSchemaInvalid, strings.Join(problems, "\n  "))
+}
@@ -0,0 +1,84 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// selectDocuments 按 Options.Select 标记要执行规则的文档，未设置选择器时返回 nil（全部执行）
+func (p *Processor) selectDocuments(docs []*yaml.Node) ([]bool, error) {
+	if p.opts.Select == nil {
+		return nil, nil
+	}
+	selected := make([]bool, len(docs))
+	for i, root := range docs {
+		if isEmptyDocument(root) {
+			continue
+		}
+		ok, err := p.engine.Selects(root, p.opts.Select)
+		if err != nil {
+			return nil, fmt.Errorf("select document %d: %w", i, err)
+		}
+		selected[i] = ok
+	}
+	return selected, nil
+}
+
+// extract 返回选中的文档及其对应的原文，原文用于 preserve 输出恢复排版
+// 原文无法按文档切分时返回空原文，输出不恢复空行与注释位置
+func extract(docs []*yaml.Node, selected []bool, data []byte) ([]*yaml.Node, []byte) {
+	sources := splitDocumentSources(data, len(docs))
+	var kept []*yaml.Node
+	var original []byte
+	for i, root := range docs {
+		if !selected[i] {
+			continue
+		}
+		kept = append(kept, root)
+		if sources != nil {
+			original = append(original, sources[i]...)
+		}
+	}
+	return kept, original
+}
+
+// splitDocumentSources 按行首的 --- 切分原文，每段包含分隔符行本身与其后的内容
+// 第一个分隔符之前只有注释与空行时并入第一段；段数与 n 不一致时返回 nil
+func splitDocumentSources(data []byte, n int) [][]byte {
+	var sources [][]byte
+	start, content := 0, false // 当前段的起点与是否含注释以外的内容
+	for offset := 0; offset < len(data); {
+		line := data[offset:]
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i+1]
+		}
+		trimmed := bytes.TrimSpace(line)
+		if isSeparator(line) {
+			// 开头只有注释的部分不是文档
+			if len(sources) > 0 || content {
+				sources = append(sources, data[start:offset])
+				start = offset
+			}
+			content = true
+		} else if len(trimmed) > 0 && trimmed[0] != '#' {
+			content = true
+		}
+		offset += len(line)
+	}
+	if content {
+		sources = append(sources, data[start:])
+	}
+
+	if len(sources) != n {
+		return nil
+	}
+	return sources
+}
+
+// isSeparator 判断是否为行首的文档分隔符 ---，其后可跟空白或内容（如 --- !!map）
+func isSeparator(line []byte) bool {
+	rest, ok := bytes.CutPrefix(line, []byte(docSeparator))
+	return ok && (len(rest) == 0 || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\r' || rest[0] == '\n')
+}
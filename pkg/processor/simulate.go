@@ -0,0 +1,278 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/engine"
+	"github.com/glesirok/yamleditor/pkg/rule"
+)
+
+// SimStatus 规则在骨架文档上的模拟结果
+type SimStatus string
+
+const (
+	SimResolved    SimStatus = "resolved"    // 路径解析到节点
+	SimUnreachable SimStatus = "unreachable" // 路径中的字段在骨架中不存在，通常是拼写错误
+	SimNoMatch     SimStatus = "no-match"    // 条件选择器没有匹配骨架中的元素，真实清单中可能匹配
+	SimOtherKind   SimStatus = "other-kind"  // 规则限定了其他 kind，或 match 选择器、when 条件不匹配骨架文档
+	SimFiltered    SimStatus = "filtered"    // 被 Options.OnlyPathPrefix 排除
+	SimError       SimStatus = "error"       // 其他执行错误
+)
+
+// SimRule 单条规则的模拟结果
+type SimRule struct {
+	Rule    *engine.Rule
+	Status  SimStatus
+	Matched int
+	Detail  string // 未解析到节点或出错时的原因
+}
+
+// Simulation 规则集在某个 kind 骨架文档上的模拟结果
+type Simulation struct {
+	Kind   string
+	Rules  []SimRule
+	Output []byte // 依次应用所有可执行规则后的文档
+}
+
+// Simulate 为 kind 构造最小骨架文档，依次应用规则并记录每条规则能否解析
+// 单条规则失败不影响后续规则；未提供的必需参数以 <name> 占位
+func (p *Processor) Simulate(kind string) (*Simulation, error) {
+	data, err := Skeleton(kind)
+	if err != nil {
+		return nil, err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("parse skeleton: %w", err)
+	}
+
+	rules := p.currentRules()
+	p.engine.SetParams(p.simulationParams())
+	p.engine.Reset()
+	p.engine.SetFile("<" + kind + ">")
+
+	sim := &Simulation{Kind: kind, Rules: make([]SimRule, len(rules))}
+	for i, r := range rules {
+		s := &sim.Rules[i]
+		s.Rule = r
+		if !p.opts.selected(r) {
+			s.Status = SimFiltered
+			continue
+		}
+
+		if r.Action == engine.ActionSetHeader {
+			s.Status = SimResolved
+			s.Matched = 1
+			continue
+		}
+
+		res, err := p.engine.Apply(&root, r)
+		if err == nil && res.Missing != nil {
+			// continue_on_not_found 忽略的错误同样说明路径不可达
+			err = res.Missing
+		}
+		var mismatch *engine.ErrTypeMismatch
+		switch {
+		case err == nil && res.Inapplicable:
+			s.Status = SimOtherKind
+			switch {
+			case r.Kind != "":
+				s.Detail = "kind " + r.Kind
+			case r.Match != nil && *r.Match == (engine.Selector{Kind: r.Match.Kind}):
+				// 加载时 kind 已改写为 match.kind
+				s.Detail = "kind " + r.Match.Kind
+			case r.Match != nil:
+				s.Detail = "match selector"
+			default:
+				s.Detail = "when " + r.When
+			}
+		case err == nil && res.Matched > 0:
+			s.Status = SimResolved
+			s.Matched = res.Matched
+		case errors.Is(err, engine.ErrNoMatch):
+			s.Status = SimNoMatch
+			s.Detail = err.Error()
+		case errors.Is(err, engine.ErrPathNotFound), errors.Is(err, engine.ErrNotFoundNodes), errors.As(err, &mismatch):
+			s.Status = SimUnreachable
+			s.Detail = err.Error()
+		default:
+			s.Status = SimError
+			s.Detail = err.Error()
+		}
+	}
+
+	if sim.Output, err = p.outputEncoder().Encode([]*yaml.Node{&root}, data); err != nil {
+		return nil, err
+	}
+	return sim, nil
+}
+
+// simulationParams 合并默认值后的参数，缺少的参数以 <name> 占位
+func (p *Processor) simulationParams() map[string]string {
+	p.rulesMu.RLock()
+	defer p.rulesMu.RUnlock()
+
+	params := map[string]string{}
+	for name, decl := range p.params {
+		params[name] = "<" + name + ">"
+		if decl.Default != "" {
+			params[name] = decl.Default
+		}
+	}
+	for name, v := range p.values {
+		params[name] = v
+	}
+	return params
+}
+
+// skeletonAPIVersions 可构造骨架的类型及其 apiVersion
+var skeletonAPIVersions = map[string]string{
+	"Pod":                   "v1",
+	"PodTemplate":           "v1",
+	"ReplicationController": "v1",
+	"Deployment":            "apps/v1",
+	"StatefulSet":           "apps/v1",
+	"DaemonSet":             "apps/v1",
+	"ReplicaSet":            "apps/v1",
+	"Job":                   "batch/v1",
+	"CronJob":               "batch/v1",
+	"Service":               "v1",
+	"ConfigMap":             "v1",
+	"Secret":                "v1",
+	"Ingress":               "networking.k8s.io/v1",
+}
+
+// SkeletonKinds 返回可构造骨架的类型
+func SkeletonKinds() []string {
+	kinds := make([]string, 0, len(skeletonAPIVersions))
+	for kind := range skeletonAPIVersions {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Skeleton 返回 kind 的最小骨架文档：常用字段各一份示例值，工作负载带一个名为 app 的容器
+func Skeleton(kind string) ([]byte, error) {
+	apiVersion, ok := skeletonAPIVersions[kind]
+	if !ok {
+		return nil, fmt.Errorf("no skeleton for kind %q, supported: %s", kind, strings.Join(SkeletonKinds(), ", "))
+	}
+
+	labels := map[string]interface{}{"app": "example"}
+	doc := map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name":        "example",
+			"namespace":   "default",
+			"labels":      labels,
+			"annotations": map[string]interface{}{"example.com/note": "example"},
+		},
+	}
+
+	switch kind {
+	case "Service":
+		doc["spec"] = map[string]interface{}{
+			"selector": labels,
+			"ports":    []interface{}{map[string]interface{}{"name": "http", "port": 80, "targetPort": 8080}},
+		}
+	case "ConfigMap":
+		doc["data"] = map[string]interface{}{"key": "value"}
+	case "Secret":
+		doc["type"] = "Opaque"
+		doc["data"] = map[string]interface{}{"key": "dmFsdWU="}
+	case "Ingress":
+		doc["spec"] = map[string]interface{}{
+			"rules": []interface{}{map[string]interface{}{
+				"host": "example.com",
+				"http": map[string]interface{}{"paths": []interface{}{map[string]interface{}{
+					"path":     "/",
+					"pathType": "Prefix",
+					"backend": map[string]interface{}{"service": map[string]interface{}{
+						"name": "example",
+						"port": map[string]interface{}{"number": 80},
+					}},
+				}}},
+			}},
+		}
+	}
+
+	if prefix, ok := rule.PodTemplatePath(kind); ok {
+		template := podTemplateSkeleton(kind, labels)
+		if prefix == "" {
+			doc["spec"] = template["spec"]
+		} else {
+			setSkeletonPath(doc, strings.Split(prefix, "."), template)
+		}
+		addWorkloadFields(kind, doc, labels)
+	}
+
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("marshal skeleton: %w", err)
+	}
+	return data, nil
+}
+
+// podTemplateSkeleton Pod 模板骨架，Job/CronJob 的 Pod 模板必须显式设置 restartPolicy
+func podTemplateSkeleton(kind string, labels map[string]interface{}) map[string]interface{} {
+	container := map[string]interface{}{
+		"name":  "app",
+		"image": "example:latest",
+		"ports": []interface{}{map[string]interface{}{"name": "http", "containerPort": 8080}},
+		"env":   []interface{}{map[string]interface{}{"name": "EXAMPLE", "value": "example"}},
+		"resources": map[string]interface{}{
+			"requests": map[string]interface{}{"cpu": "100m", "memory": "128Mi"},
+			"limits":   map[string]interface{}{"cpu": "500m", "memory": "256Mi"},
+		},
+	}
+	spec := map[string]interface{}{"containers": []interface{}{container}}
+	if kind == "Job" || kind == "CronJob" {
+		spec["restartPolicy"] = "OnFailure"
+	}
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": labels},
+		"spec":     spec,
+	}
+}
+
+// addWorkloadFields 补充各工作负载 spec 中 Pod 模板以外的常用字段
+func addWorkloadFields(kind string, doc, labels map[string]interface{}) {
+	spec, _ := doc["spec"].(map[string]interface{})
+	switch kind {
+	case "Deployment", "ReplicaSet", "StatefulSet", "DaemonSet":
+		spec["selector"] = map[string]interface{}{"matchLabels": labels}
+		if kind != "DaemonSet" {
+			spec["replicas"] = 1
+		}
+		if kind == "StatefulSet" {
+			spec["serviceName"] = "example"
+		}
+	case "ReplicationController":
+		spec["replicas"] = 1
+		spec["selector"] = labels
+	case "Job":
+		spec["backoffLimit"] = 6
+	case "CronJob":
+		spec["schedule"] = "0 * * * *"
+	}
+}
+
+// setSkeletonPath 在 doc 的 keys 路径处放入 value，缺少的中间层逐层创建
+func setSkeletonPath(doc map[string]interface{}, keys []string, value interface{}) {
+	m := doc
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := m[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			m[key] = next
+		}
+		m = next
+	}
+	m[keys[len(keys)-1]] = value
+}
@@ -0,0 +1,40 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+)
+
+// ProcessStream 从 r 读取 YAML，应用规则后把结果写到 w，不读写任何文件，用于管道中的 stdin/stdout
+// name 用于模板中的 .File 与错误信息；dry-run 且设置了 Options.DiffFormat 时写出差异预览而不是结果
+func (p *Processor) ProcessStream(name string, r io.Reader, w io.Writer, dryRun bool) (*FileResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	output, result, err := p.Eval(name, data)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &plannedFile{input: name, output: name, original: data, content: output, result: result}
+	if err := p.checkLimits([]*plannedFile{f}); err != nil {
+		return nil, err
+	}
+	result.Status = StatusWritten
+	if !f.changed() {
+		result.Status = StatusUnchanged
+	}
+
+	if dryRun && p.opts.DiffFormat != DiffNone {
+		diff, err := formatDiff(p.opts.DiffFormat, name, name, data, output)
+		if err != nil {
+			return nil, err
+		}
+		output = []byte(diff)
+	}
+	if _, err := w.Write(output); err != nil {
+		return nil, fmt.Errorf("write output: %w", err)
+	}
+	return result, nil
+}
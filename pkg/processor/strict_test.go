@@ -0,0 +1,54 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/glesirok/yamleditor/pkg/rule"
+)
+
+// coerce 的类型转换与替换时丢弃的注释输出警告，Strict 时使文件失败
+func TestStrictWarnings(t *testing.T) {
+	const input = `spec:
+  replicas: 3
+  ports: # 对外端口
+    - 80 # http
+    - 443 # https
+  env:
+    name: app # 应用名
+`
+	tests := []struct {
+		name  string
+		rules string
+		want  string // 警告中的内容，空表示没有警告
+	}{
+		{"coerce", "- action: replace\n  path: spec.replicas\n  value: \"5\"\n  coerce: true\n", `spec.replicas: coerced str "5" to int`},
+		{"coerce same type", "- action: replace\n  path: spec.replicas\n  value: 5\n  coerce: true\n", ""},
+		{"sequence shrinks", "- action: replace\n  path: spec.ports\n  value: [8080]\n", "spec.ports: 2 comment(s) dropped"},
+		{"sequence same length", "- action: replace\n  path: spec.ports\n  value: [8080, 8443]\n", ""},
+		{"key removed", "- action: replace\n  path: spec.env\n  value: {value: x}\n", "spec.env: 1 comment(s) dropped"},
+		{"merge replaces scalar", "- action: merge\n  path: spec\n  value: {env: plain}\n", "spec.env: 1 comment(s) dropped"},
+	}
+	for _, tt := range tests {
+		for _, strict := range []bool{false, true} {
+			config, err := rule.ParseInline([]byte(tt.rules))
+			if err != nil {
+				t.Fatalf("%s: parse rules: %v", tt.name, err)
+			}
+			p := NewProcessorFromConfig(config)
+			p.SetOptions(Options{Strict: strict})
+			_, result, err := p.Eval("input.yaml", []byte(input))
+
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("%s (strict=%v): unexpected error %v", tt.name, strict, err)
+			case tt.want == "" && len(result.Warnings) > 0:
+				t.Errorf("%s (strict=%v): unexpected warnings %q", tt.name, strict, result.Warnings)
+			case tt.want != "" && strict && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("%s (strict): error = %v, want containing %q", tt.name, err, tt.want)
+			case tt.want != "" && !strict && (err != nil || len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], tt.want)):
+				t.Errorf("%s: warnings = %q, err = %v, want one containing %q", tt.name, result.Warnings, err, tt.want)
+			}
+		}
+	}
+}
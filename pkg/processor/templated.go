@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+// 模板占位符，见 maskTemplates
+const (
+	templateMarker = "__yamleditor_tpl_"
+	lineMarker     = "__yamleditor_line_"
+)
+
+var (
+	// templateExpr Go 模板表达式，可以跨行
+	templateExpr = regexp.MustCompile(`(?s)\{\{.*?\}\}`)
+	// templateOnlyLine 替换后只剩占位符的行，如 {{- if .Values.enabled }}
+	templateOnlyLine = regexp.MustCompile(`^[ \t]*(?:` + templateMarker + `\d+__[ \t]*)+$`)
+	// maskedExpr、maskedLine 输出中的两种占位符
+	maskedExpr = regexp.MustCompile(templateMarker + `(\d+)__`)
+	maskedLine = regexp.MustCompile(`(?m)^[ \t]*# ` + lineMarker + `(\d+)__[ \t]*$`)
+)
+
+// templateMask 记录被占位符替换的模板原文
+type templateMask struct {
+	exprs []string // 每个 {{ ... }} 的原文
+	lines []string // 整行模板所在行（占位符替换后）的原文
+}
+
+// maskTemplates 将 Helm 等 Go 模板中的 {{ ... }} 替换为占位符，使内容可以按 YAML 解析：
+// 行内的模板替换为普通标量 __yamleditor_tpl_N__（如 image: {{ .Values.image }}），
+// 只有模板的整行（if/range/end、include ... | nindent 等）替换为注释，保留原缩进；
+// 输出时由 restore 还原。内容中已有占位符前缀时报错
+func maskTemplates(data []byte) ([]byte, *templateMask, error) {
+	if bytes.Contains(data, []byte(templateMarker)) || bytes.Contains(data, []byte(lineMarker)) {
+		return nil, nil, fmt.Errorf("templated input already contains the placeholder prefix %s", templateMarker)
+	}
+
+	m := &templateMask{}
+	data = templateExpr.ReplaceAllFunc(data, func(expr []byte) []byte {
+		m.exprs = append(m.exprs, string(expr))
+		return []byte(templateMarker + strconv.Itoa(len(m.exprs)-1) + "__")
+	})
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	for i, line := range lines {
+		content := bytes.TrimRight(line, "\r\n")
+		if !templateOnlyLine.Match(content) {
+			continue
+		}
+		indent := content[:len(content)-len(bytes.TrimLeft(content, " \t"))]
+		m.lines = append(m.lines, string(content))
+		lines[i] = slices.Concat(indent, []byte("# "+lineMarker+strconv.Itoa(len(m.lines)-1)+"__"), line[len(content):])
+	}
+	return bytes.Join(lines, nil), m, nil
+}
+
+// restore 将输出中的占位符还原为模板原文：整行模板按原行（含缩进）还原，再还原行内的模板
+func (m *templateMask) restore(output []byte) []byte {
+	output = maskedLine.ReplaceAllFunc(output, func(marker []byte) []byte {
+		i, _ := strconv.Atoi(string(maskedLine.FindSubmatch(marker)[1]))
+		return []byte(m.lines[i])
+	})
+	return maskedExpr.ReplaceAllFunc(output, func(marker []byte) []byte {
+		i, _ := strconv.Atoi(string(maskedExpr.FindSubmatch(marker)[1]))
+		return []byte(m.exprs[i])
+	})
+}
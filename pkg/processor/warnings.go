@@ -0,0 +1,33 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+)
+
+// SetWarnings 设置警告的输出，每条警告写为一行 "warning: ..."；nil 表示只记入结果
+func (p *Processor) SetWarnings(w io.Writer) {
+	if w == nil {
+		p.SetWarningHandler(nil)
+		return
+	}
+	p.SetWarningHandler(func(msg string) {
+		fmt.Fprintf(w, "warning: %s\n", msg)
+	})
+}
+
+// SetWarningHandler 设置警告的回调，与 SetWarnings 互相覆盖；nil 表示只记入结果
+// 警告（弃用的规则、通配展开时跳过的元素、重复键等）总是记入 FileResult.Warnings，
+// 回调在产生警告时立即调用，便于批量处理时及时输出
+func (p *Processor) SetWarningHandler(fn func(msg string)) {
+	p.warnings = fn
+}
+
+// warn 记录一条警告
+func (p *Processor) warn(result *FileResult, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	result.Warnings = append(result.Warnings, msg)
+	if p.warnings != nil {
+		p.warnings(msg)
+	}
+}
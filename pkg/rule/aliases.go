@@ -0,0 +1,81 @@
+package rule
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/glesirok/yamleditor/pkg/engine"
+	"github.com/glesirok/yamleditor/pkg/path"
+)
+
+// expandAliases 将规则路径开头的 $name 替换为 aliases: 中声明的路径前缀
+// 如 aliases: {podspec: spec.template.spec} 时，$podspec.containers[*] 展开为 spec.template.spec.containers[*]
+func expandAliases(config *Config) error {
+	for name, prefix := range config.Aliases {
+		if err := validateAlias(name, prefix); err != nil {
+			return err
+		}
+	}
+
+	return eachRule(config, func(r *engine.Rule) error {
+		return expandAlias(r, config.Aliases)
+	})
+}
+
+// eachRule 对 rules:、kinds: 与 groups: 下展开前的每条规则调用 fn，错误信息标明规则位置
+func eachRule(config *Config, fn func(r *engine.Rule) error) error {
+	for i, r := range config.Rules {
+		if err := fn(r); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	for kind, rules := range config.Kinds {
+		for i, r := range rules {
+			if err := fn(r); err != nil {
+				return fmt.Errorf("kinds.%s rule %d: %w", kind, i, err)
+			}
+		}
+	}
+	for _, g := range config.Groups {
+		for i, r := range g.Rules {
+			if err := fn(r); err != nil {
+				return fmt.Errorf("groups.%s rule %d: %w", g.Name, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateAlias 校验别名声明：名称需能作为变量名，前缀是合法路径且不引用其他别名
+func validateAlias(name, prefix string) error {
+	if !captureName.MatchString(name) {
+		return fmt.Errorf("invalid alias name %q", name)
+	}
+	if strings.HasPrefix(prefix, "$") {
+		return fmt.Errorf("alias %q: aliases cannot reference other aliases", name)
+	}
+	if _, err := path.Parse(prefix); err != nil {
+		return fmt.Errorf("alias %q: %w", name, err)
+	}
+	return nil
+}
+
+// expandAlias 展开单条规则的路径别名，别名后只能紧跟 . 、[ 或路径结尾
+func expandAlias(r *engine.Rule, aliases map[string]string) error {
+	if !strings.HasPrefix(r.Path, "$") {
+		return nil
+	}
+
+	name := r.Path[1:]
+	rest := ""
+	if i := strings.IndexAny(name, ".["); i >= 0 {
+		name, rest = name[:i], name[i:]
+	}
+	prefix, ok := aliases[name]
+	if !ok {
+		return fmt.Errorf("unknown path alias $%s", name)
+	}
+
+	r.Path = prefix + rest
+	return nil
+}
@@ -0,0 +1,96 @@
+package rule
+
+import (
+	"fmt"
+
+	"github.com/glesirok/yamleditor/pkg/engine"
+)
+
+// Group 一组共用文件范围与文档选择器的规则，如"Deployment 做 X，Service 做 Y"写在同一个规则文件中
+type Group struct {
+	Name  string           `yaml:"name"`
+	Files []string         `yaml:"files,omitempty"` // 只作用于路径匹配其中任一 glob 的文件，同 Rule.Files
+	Match *engine.Selector `yaml:"match,omitempty"` // 只作用于满足选择器的文档，与规则自身的 match 合并
+	Rules []*engine.Rule   `yaml:"rules"`
+}
+
+// expandGroups 将 groups: 下的规则带上组的 files 与 match 展开为普通规则，按组的顺序追加在 rules 与 kinds 之后
+func expandGroups(config *Config) error {
+	seen := map[string]bool{}
+	for i, g := range config.Groups {
+		if g.Name == "" {
+			return fmt.Errorf("groups[%d]: name is required", i)
+		}
+		if seen[g.Name] {
+			return fmt.Errorf("groups: duplicate group name %q", g.Name)
+		}
+		seen[g.Name] = true
+		if err := validateGroup(g); err != nil {
+			return fmt.Errorf("groups.%s: %w", g.Name, err)
+		}
+		if len(g.Rules) == 0 {
+			return fmt.Errorf("groups.%s: no rules defined", g.Name)
+		}
+
+		for j, r := range g.Rules {
+			expanded, err := groupRule(g, r)
+			if err != nil {
+				return fmt.Errorf("groups.%s rule %d: %w", g.Name, j, err)
+			}
+			config.Rules = append(config.Rules, expanded)
+		}
+	}
+	return nil
+}
+
+// validateGroup 校验组的 files 与 match
+func validateGroup(g *Group) error {
+	if err := ValidateGlobs(g.Files); err != nil {
+		return fmt.Errorf("files: %w", err)
+	}
+	if g.Match != nil && *g.Match == (engine.Selector{}) {
+		return fmt.Errorf("match must set at least one of kind, apiVersion, name, namespace")
+	}
+	return nil
+}
+
+// groupRule 返回带上组的 files 与 match 的规则副本：规则不能另设 files，
+// match 按字段合并，同一字段两边都设置且不同时报错
+func groupRule(g *Group, r *engine.Rule) (*engine.Rule, error) {
+	expanded := *r
+	if len(g.Files) > 0 {
+		if len(r.Files) > 0 {
+			return nil, fmt.Errorf("files is set by the group")
+		}
+		expanded.Files = g.Files
+	}
+
+	if g.Match != nil {
+		match := *g.Match
+		if r.Match != nil {
+			for _, f := range []struct {
+				name       string
+				group, own string
+				dst        *string
+			}{
+				{"kind", g.Match.Kind, r.Match.Kind, &match.Kind},
+				{"apiVersion", g.Match.APIVersion, r.Match.APIVersion, &match.APIVersion},
+				{"name", g.Match.Name, r.Match.Name, &match.Name},
+				{"namespace", g.Match.Namespace, r.Match.Namespace, &match.Namespace},
+			} {
+				if f.group != "" && f.own != "" && f.group != f.own {
+					return nil, fmt.Errorf("match.%s %q conflicts with group match.%s %q", f.name, f.own, f.name, f.group)
+				}
+				if f.own != "" {
+					*f.dst = f.own
+				}
+			}
+		}
+		expanded.Match = &match
+	}
+
+	if err := Validate(&expanded); err != nil {
+		return nil, err
+	}
+	return &expanded, nil
+}
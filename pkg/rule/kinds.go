@@ -0,0 +1,86 @@
+package rule
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/glesirok/yamleditor/pkg/engine"
+)
+
+// podTemplatePaths 各工作负载类型中 Pod 模板（PodTemplateSpec）的位置
+// kinds: 下的规则路径相对于 Pod 模板书写，如 spec.containers[*].image
+var podTemplatePaths = map[string]string{
+	"Pod":         "",
+	"PodTemplate": "template",
+	"Deployment":  "spec.template",
+	"StatefulSet": "spec.template",
+	"DaemonSet":   "spec.template",
+	"ReplicaSet":  "spec.template",
+	"Job":         "spec.template",
+	"CronJob":     "spec.jobTemplate.spec.template",
+
+	"ReplicationController": "spec.template",
+}
+
+// expandKinds 将 kinds: 下按类型组织的规则展开为带 kind 条件的普通规则，追加在 rules 之后
+// 类型按名称排序展开，保证规则顺序稳定
+func expandKinds(config *Config) error {
+	kinds := make([]string, 0, len(config.Kinds))
+	for kind := range config.Kinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	for _, kind := range kinds {
+		prefix, ok := podTemplatePaths[kind]
+		if !ok {
+			return fmt.Errorf("kinds: unknown kind %q, supported: %s", kind, supportedKinds())
+		}
+
+		for i, r := range config.Kinds[kind] {
+			if r.Match != nil && r.Match.Kind != "" && r.Match.Kind != kind {
+				return fmt.Errorf("kinds.%s rule %d: kind %q conflicts with section", kind, i, r.Match.Kind)
+			}
+			if err := Validate(r); err != nil {
+				return fmt.Errorf("kinds.%s rule %d: %w", kind, i, err)
+			}
+
+			expanded := *r
+			match := engine.Selector{}
+			if r.Match != nil {
+				match = *r.Match
+			}
+			match.Kind = kind
+			expanded.Match = &match
+			switch {
+			case prefix == "":
+			case r.Path == "":
+				// 省略 path 的规则作用于整个 Pod 模板
+				expanded.Path = prefix
+			case strings.HasPrefix(r.Path, ".."):
+				// ..field 自带分隔符
+				expanded.Path = prefix + r.Path
+			default:
+				expanded.Path = prefix + "." + r.Path
+			}
+			config.Rules = append(config.Rules, &expanded)
+		}
+	}
+	return nil
+}
+
+// PodTemplatePath 返回 kind 中 Pod 模板的路径，Pod 本身为空串；不含 Pod 模板的类型返回 false
+func PodTemplatePath(kind string) (string, bool) {
+	p, ok := podTemplatePaths[kind]
+	return p, ok
+}
+
+func supportedKinds() string {
+	kinds := make([]string, 0, len(podTemplatePaths))
+	for kind := range podTemplatePaths {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return strings.Join(kinds, ", ")
+}
@@ -0,0 +1,65 @@
+package rule
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// functionConfigRules ConfigMap 形式的 functionConfig 中存放规则的键
+const functionConfigRules = "rules"
+
+// ParseFunctionConfig 解析 KRM 函数 ResourceList 中的 functionConfig，返回规则与参数值：
+//   - ConfigMap：data.rules 为规则，写法同 ParseInline；data 中的其他键为参数值
+//   - 其他类型：去掉 apiVersion、kind、metadata 后的字段即规则文件的内容，没有参数值
+func ParseFunctionConfig(fc *yaml.Node) (*Config, map[string]string, error) {
+	if fc == nil || fc.Kind != yaml.MappingNode || len(fc.Content) == 0 {
+		return nil, nil, fmt.Errorf("functionConfig is required")
+	}
+
+	if kind := mappingValue(fc, "kind"); kind != nil && kind.Value == "ConfigMap" {
+		data := mappingValue(fc, "data")
+		if data == nil || data.Kind != yaml.MappingNode {
+			return nil, nil, fmt.Errorf("functionConfig ConfigMap has no data.%s", functionConfigRules)
+		}
+		var rules *yaml.Node
+		values := map[string]string{}
+		for i := 0; i+1 < len(data.Content); i += 2 {
+			key, value := data.Content[i], data.Content[i+1]
+			if value.Kind != yaml.ScalarNode {
+				return nil, nil, fmt.Errorf("functionConfig: data.%s must be a string", key.Value)
+			}
+			if key.Value == functionConfigRules {
+				rules = value
+				continue
+			}
+			values[key.Value] = value.Value
+		}
+		if rules == nil {
+			return nil, nil, fmt.Errorf("functionConfig ConfigMap has no data.%s", functionConfigRules)
+		}
+		config, err := ParseInline([]byte(rules.Value))
+		if err != nil {
+			return nil, nil, fmt.Errorf("functionConfig data.%s: %w", functionConfigRules, err)
+		}
+		return config, values, nil
+	}
+
+	body := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for i := 0; i+1 < len(fc.Content); i += 2 {
+		switch fc.Content[i].Value {
+		case "apiVersion", "kind", "metadata":
+			continue
+		}
+		body.Content = append(body.Content, fc.Content[i], fc.Content[i+1])
+	}
+	data, err := yaml.Marshal(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal functionConfig: %w", err)
+	}
+	config, err := parseConfig(data, "", true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("functionConfig: %w", err)
+	}
+	return config, map[string]string{}, nil
+}
@@ -0,0 +1,381 @@
+package rule
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/engine"
+	"github.com/glesirok/yamleditor/pkg/path"
+)
+
+// Problem 规则文件中的一处错误
+type Problem struct {
+	Line    int    // 规则文件中的行号，无法定位时为 0
+	Rule    string // 出错的规则，如 "rule 3"、"kinds.Deployment rule 0"；文件级错误为空
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Rule == "" {
+		return p.Message
+	}
+	return p.Rule + ": " + p.Message
+}
+
+// Lint 检查规则文件内容，返回所有错误（按行号排序）而不是在第一处停止，没有错误时返回 nil
+// 检查项与 ParseConfig 相同，另外报告规则与选项中拼错的字段名（加载时会被忽略）
+func Lint(data []byte) []Problem {
+	return lint(data, "")
+}
+
+// LintFile 读取并检查规则文件，value_from 的相对路径相对规则文件所在目录
+func LintFile(filePath string) ([]Problem, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return lint(data, filepath.Dir(filePath)), nil
+}
+
+func lint(data []byte, dir string) []Problem {
+	l := &linter{dir: dir}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		l.addErr(0, "", err)
+		return l.problems
+	}
+	if len(doc.Content) == 0 {
+		l.add(0, "", "empty rule file")
+		return l.problems
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		l.add(root.Line, "", "rule file must be a mapping with a rules list")
+		return l.problems
+	}
+
+	var header struct {
+		Version int `yaml:"version"`
+	}
+	if l.decode(root, &header, "") {
+		if err := CheckVersion(header.Version); err != nil {
+			// 新版本的字段按当前结构检查没有意义
+			l.addErr(fieldLine(root, "version"), "", err)
+			return l.problems
+		}
+	}
+	l.unknownFields(root, reflect.TypeOf(Config{}), "")
+
+	var settings Settings
+	if node := mappingValue(root, "settings"); node != nil && l.decode(node, &settings, "") {
+		switch settings.Targets {
+		case "", engine.TargetsAnchorsOnly, engine.TargetsResolvedCopies, engine.TargetsError:
+		default:
+			l.add(fieldLine(node, "targets"), "", fmt.Sprintf("settings: unknown targets: %s", settings.Targets))
+		}
+		if err := ValidateGlobs(settings.Include); err != nil {
+			l.add(fieldLine(node, "include"), "", fmt.Sprintf("settings: include: %v", err))
+		}
+		if err := ValidateGlobs(settings.Exclude); err != nil {
+			l.add(fieldLine(node, "exclude"), "", fmt.Sprintf("settings: exclude: %v", err))
+		}
+	}
+
+	var params map[string]Param
+	if node := mappingValue(root, "params"); node != nil && l.decode(node, &params, "") {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if name := node.Content[i].Value; !captureName.MatchString(name) {
+				l.add(node.Content[i].Line, "", fmt.Sprintf("invalid param name %q", name))
+			}
+		}
+	}
+
+	var aliases map[string]string
+	if node := mappingValue(root, "aliases"); node != nil && l.decode(node, &aliases, "") {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := validateAlias(node.Content[i].Value, node.Content[i+1].Value); err != nil {
+				l.addErr(node.Content[i].Line, "", err)
+			}
+		}
+	}
+
+	rules := mappingValue(root, "rules")
+	kinds := mappingValue(root, "kinds")
+	groups := mappingValue(root, "groups")
+	if rules == nil && kinds == nil && groups == nil {
+		l.add(root.Line, "", "no rules defined")
+	}
+	if rules != nil {
+		if rules.Kind != yaml.SequenceNode {
+			l.add(rules.Line, "", "rules must be a list")
+		} else {
+			for i, node := range rules.Content {
+				l.rule(node, fmt.Sprintf("rule %d", i), "", aliases, params)
+			}
+		}
+	}
+	if kinds != nil && kinds.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(kinds.Content); i += 2 {
+			kind, list := kinds.Content[i], kinds.Content[i+1]
+			if _, ok := podTemplatePaths[kind.Value]; !ok {
+				l.add(kind.Line, "", fmt.Sprintf("kinds: unknown kind %q, supported: %s", kind.Value, supportedKinds()))
+				continue
+			}
+			if list.Kind != yaml.SequenceNode {
+				l.add(list.Line, "", fmt.Sprintf("kinds.%s must be a list", kind.Value))
+				continue
+			}
+			for j, node := range list.Content {
+				l.rule(node, fmt.Sprintf("kinds.%s rule %d", kind.Value, j), kind.Value, aliases, params)
+			}
+		}
+	} else if kinds != nil {
+		l.add(kinds.Line, "", "kinds must be a mapping of kind to rules")
+	}
+	if groups != nil && groups.Kind == yaml.SequenceNode {
+		seen := map[string]bool{}
+		for i, node := range groups.Content {
+			l.group(node, i, seen, aliases, params)
+		}
+	} else if groups != nil {
+		l.add(groups.Line, "", "groups must be a list")
+	}
+
+	sort.SliceStable(l.problems, func(i, j int) bool { return l.problems[i].Line < l.problems[j].Line })
+	return l.problems
+}
+
+// linter 收集 Lint 发现的错误
+type linter struct {
+	problems []Problem
+	ids      map[string]bool // 已出现的规则 id
+	dir      string          // value_from 相对路径的基准目录
+}
+
+func (l *linter) add(line int, rule, msg string) {
+	l.problems = append(l.problems, Problem{Line: line, Rule: rule, Message: msg})
+}
+
+// addErr 记录错误，错误信息中带有 yaml 行号（line N: ...）时改用该行号并从信息中去掉
+func (l *linter) addErr(line int, rule string, err error) {
+	msg := err.Error()
+	if m := yamlLine.FindStringSubmatch(msg); m != nil {
+		line, _ = strconv.Atoi(m[1])
+		msg = strings.Replace(msg, m[0], "", 1)
+	}
+	l.add(line, rule, msg)
+}
+
+// yamlLine yaml 错误信息中的行号
+var yamlLine = regexp.MustCompile(`line (\d+): `)
+
+// decode 解码节点，类型错误逐条记录并返回 false
+func (l *linter) decode(node *yaml.Node, out interface{}, rule string) bool {
+	err := node.Decode(out)
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		for _, e := range typeErr.Errors {
+			l.addErr(node.Line, rule, errors.New(e))
+		}
+		return false
+	}
+	if err != nil {
+		l.addErr(node.Line, rule, err)
+		return false
+	}
+	return true
+}
+
+// rule 检查单条规则；kind 非空时为 kinds: 下的规则。没有发现错误时返回展开别名后的规则
+func (l *linter) rule(node *yaml.Node, label, kind string, aliases map[string]string, params map[string]Param) *engine.Rule {
+	if node.Kind != yaml.MappingNode {
+		l.add(node.Line, label, "rule must be a mapping")
+		return nil
+	}
+	l.unknownFields(node, reflect.TypeOf(engine.Rule{}), label)
+
+	r := &engine.Rule{}
+	if !l.decode(node, r, label) {
+		return nil
+	}
+	if kind != "" && r.Kind != "" && r.Kind != kind {
+		l.add(fieldLine(node, "kind"), label, fmt.Sprintf("kind %q conflicts with section", r.Kind))
+	}
+	if kind != "" && r.Match != nil && r.Match.Kind != "" && r.Match.Kind != kind {
+		l.add(fieldLine(node, "match"), label, fmt.Sprintf("match.kind %q conflicts with section", r.Match.Kind))
+	}
+	if err := expandAlias(r, aliases); err != nil {
+		l.addErr(fieldLine(node, "path"), label, err)
+		return nil
+	}
+
+	// 能定位到字段的错误报告在字段所在行；有这类错误时不再报告 Validate 的重复结果
+	found := len(l.problems)
+	if r.Path != "" {
+		if _, err := path.Parse(r.Path); err != nil {
+			l.add(fieldLine(node, "path"), label, fmt.Sprintf("invalid path: %v", err))
+		}
+	}
+	if r.Action == engine.ActionRegexReplace && r.Pattern != "" {
+		if err := validatePattern(r.Pattern); err != nil {
+			l.add(fieldLine(node, "pattern"), label, err.Error())
+		}
+	}
+	if err := validateWhen(r); err != nil {
+		l.add(fieldLine(node, "when"), label, err.Error())
+	}
+	if err := validateSelector(r); err != nil {
+		l.add(fieldLine(node, "match"), label, err.Error())
+	}
+	if err := validateWhere(r); err != nil {
+		l.add(fieldLine(node, "where"), label, err.Error())
+	}
+	if r.Sunset != "" {
+		if err := CheckSunset(r, time.Now()); err != nil {
+			l.add(fieldLine(node, "sunset"), label, err.Error())
+		}
+	}
+	if r.ID != "" {
+		if !ruleID.MatchString(r.ID) {
+			l.add(fieldLine(node, "id"), label, fmt.Sprintf("invalid id %q, expected letters, digits, '.', '_' or '-'", r.ID))
+		} else if l.ids[r.ID] {
+			l.add(fieldLine(node, "id"), label, fmt.Sprintf("duplicate id %q", r.ID))
+		}
+		if l.ids == nil {
+			l.ids = map[string]bool{}
+		}
+		l.ids[r.ID] = true
+	}
+	if _, ok := params[r.Capture]; ok && r.Capture != "" {
+		l.add(fieldLine(node, "capture"), label, fmt.Sprintf("capture %q conflicts with param of the same name", r.Capture))
+	}
+	if err := ValidateGlobs(r.Files); err != nil {
+		l.add(fieldLine(node, "files"), label, fmt.Sprintf("files: %v", err))
+	}
+	if err := resolveValueFrom(r, l.dir, nil); err != nil {
+		l.add(fieldLine(node, "value_from"), label, err.Error())
+	}
+	if len(l.problems) > found {
+		return nil
+	}
+	if err := Validate(r); err != nil {
+		l.add(node.Line, label, err.Error())
+		return nil
+	}
+	return r
+}
+
+// group 检查 groups: 下的一个组及其规则，seen 记录已出现的组名
+func (l *linter) group(node *yaml.Node, i int, seen map[string]bool, aliases map[string]string, params map[string]Param) {
+	label := fmt.Sprintf("groups[%d]", i)
+	if node.Kind != yaml.MappingNode {
+		l.add(node.Line, label, "group must be a mapping")
+		return
+	}
+
+	// 规则在下面逐条检查，这里只解码组自身的设置
+	var header struct {
+		Name  string           `yaml:"name"`
+		Files []string         `yaml:"files"`
+		Match *engine.Selector `yaml:"match"`
+	}
+	if !l.decode(node, &header, label) {
+		return
+	}
+	g := &Group{Name: header.Name, Files: header.Files, Match: header.Match}
+	switch {
+	case g.Name == "":
+		l.add(node.Line, label, "name is required")
+	case seen[g.Name]:
+		l.add(fieldLine(node, "name"), label, fmt.Sprintf("duplicate group name %q", g.Name))
+	default:
+		label = "groups." + g.Name
+	}
+	seen[g.Name] = true
+	l.unknownFields(node, reflect.TypeOf(Group{}), label)
+	if err := validateGroup(g); err != nil {
+		l.add(node.Line, label, err.Error())
+		return
+	}
+
+	rules := mappingValue(node, "rules")
+	if rules == nil || rules.Kind != yaml.SequenceNode || len(rules.Content) == 0 {
+		l.add(node.Line, label, "rules must be a non-empty list")
+		return
+	}
+	for j, ruleNode := range rules.Content {
+		ruleLabel := fmt.Sprintf("%s rule %d", label, j)
+		if r := l.rule(ruleNode, ruleLabel, "", aliases, params); r != nil {
+			if _, err := groupRule(g, r); err != nil {
+				l.add(ruleNode.Line, ruleLabel, err.Error())
+			}
+		}
+	}
+}
+
+// unknownFields 报告 mapping 中结构体 t 没有的字段，并递归检查结构体类型的字段
+func (l *linter) unknownFields(node *yaml.Node, t reflect.Type, rule string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	fields := yamlFields(t)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		ft, ok := fields[key.Value]
+		if !ok {
+			l.add(key.Line, rule, fmt.Sprintf("unknown field %q", key.Value))
+			continue
+		}
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct {
+			l.unknownFields(value, ft, rule)
+		}
+	}
+}
+
+// yamlFields 返回结构体按 yaml 标签的字段名到类型的映射
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// mappingValue 返回 mapping 中 key 对应的值节点
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// fieldLine 返回 mapping 中字段所在的行，没有该字段时返回 mapping 本身的行
+func fieldLine(node *yaml.Node, key string) int {
+	if v := mappingValue(node, key); v != nil {
+		return v.Line
+	}
+	return node.Line
+}
@@ -3,43 +3,368 @@ package rule
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/dlclark/regexp2"
 	"gopkg.in/yaml.v3"
 	"github.com/glesirok/yamleditor/pkg/engine"
+	"github.com/glesirok/yamleditor/pkg/path"
+	"github.com/glesirok/yamleditor/pkg/version"
 )
 
 // Config 表示规则配置文件
 type Config struct {
-	Rules []*engine.Rule `yaml:"rules"`
+	// Version 规则文件所需的最低特性版本（见 version.ConfigVersion），0 表示不限
+	Version int `yaml:"version,omitempty"`
+
+	Settings Settings         `yaml:"settings,omitempty"`
+	Params   map[string]Param `yaml:"params,omitempty"`
+	Rules    []*engine.Rule   `yaml:"rules"`
+
+	// Aliases 路径前缀别名，规则路径以 $name 开头时在加载时展开
+	Aliases map[string]string `yaml:"aliases,omitempty"`
+
+	// Kinds 按工作负载类型组织的规则，路径相对于该类型的 Pod 模板，加载时展开到 Rules
+	Kinds map[string][]*engine.Rule `yaml:"kinds,omitempty"`
+
+	// Groups 各自限定文件范围与文档选择器的规则组，加载时展开到 Rules
+	Groups []*Group `yaml:"groups,omitempty"`
+}
+
+// Settings 规则文件级别的运行设置
+type Settings struct {
+	// InPlace 为 true 时，命令行未指定 -o 也未指定 --in-place 仍原地修改输入（旧行为），且不需要确认
+	InPlace bool `yaml:"in_place,omitempty"`
+	// Targets 未设置 targets 的规则使用的默认值，见 engine.Rule.Targets
+	Targets string `yaml:"targets,omitempty"`
+	// Interpolate 未设置 interpolate 的规则使用的默认值，见 engine.Rule.Interpolate
+	Interpolate string `yaml:"interpolate,omitempty"`
+	// Include、Exclude 批量处理时只处理、跳过的文件 glob 模式，见 processor.Options.Include
+	Include []string `yaml:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
 }
 
 // LoadFromFile 从文件加载规则
 func LoadFromFile(filePath string) ([]*engine.Rule, error) {
+	config, err := LoadConfig(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return config.Rules, nil
+}
+
+// LoadConfig 从文件加载完整配置（参数声明与规则），value_from 的相对路径相对规则文件所在目录
+func LoadConfig(filePath string) (*Config, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("read file: %w", err)
 	}
+	return parseConfig(data, filepath.Dir(filePath), true)
+}
+
+// ParseConfig 解析并校验配置内容，value_from 的相对路径相对当前目录
+func ParseConfig(data []byte) (*Config, error) {
+	return parseConfig(data, "", true)
+}
+
+// parseConfig 解析并校验配置，files 为 false 时不允许 value_from
+func parseConfig(data []byte, dir string, files bool) (*Config, error) {
+	// 先单独检查版本：新版本的字段可能无法按当前结构解析，应报告版本不足而不是解析错误
+	var header struct {
+		Version int `yaml:"version"`
+	}
+	if err := yaml.Unmarshal(data, &header); err == nil {
+		if err := CheckVersion(header.Version); err != nil {
+			return nil, err
+		}
+	}
 
 	var config Config
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("unmarshal yaml: %w", err)
 	}
 
-	// 校验规则
+	if err := resolveValuesFrom(&config, dir, files); err != nil {
+		return nil, err
+	}
+	if err := expandAliases(&config); err != nil {
+		return nil, err
+	}
+	if err := eachRule(&config, kindToMatch); err != nil {
+		return nil, err
+	}
+	if err := expandKinds(&config); err != nil {
+		return nil, err
+	}
+	if err := expandGroups(&config); err != nil {
+		return nil, err
+	}
+
+	switch config.Settings.Targets {
+	case "", engine.TargetsAnchorsOnly, engine.TargetsResolvedCopies, engine.TargetsError:
+	default:
+		return nil, fmt.Errorf("settings: unknown targets: %s", config.Settings.Targets)
+	}
+	switch config.Settings.Interpolate {
+	case "", engine.InterpolateNone, engine.InterpolateEnv:
+	default:
+		return nil, fmt.Errorf("settings: unknown interpolate: %s, expected %s or %s", config.Settings.Interpolate, engine.InterpolateNone, engine.InterpolateEnv)
+	}
+	if err := ValidateGlobs(config.Settings.Include); err != nil {
+		return nil, fmt.Errorf("settings: include: %w", err)
+	}
+	if err := ValidateGlobs(config.Settings.Exclude); err != nil {
+		return nil, fmt.Errorf("settings: exclude: %w", err)
+	}
+
+	// 校验参数与规则
+	for name := range config.Params {
+		if !captureName.MatchString(name) {
+			return nil, fmt.Errorf("invalid param name %q", name)
+		}
+	}
+	ids := map[string]int{}
 	for i, rule := range config.Rules {
 		if err := Validate(rule); err != nil {
 			return nil, fmt.Errorf("rule %d: %w", i, err)
 		}
+		if j, ok := ids[rule.ID]; ok && rule.ID != "" {
+			return nil, fmt.Errorf("rule %d: duplicate id %q (also rule %d)", i, rule.ID, j)
+		}
+		ids[rule.ID] = i
+		if rule.Targets == "" {
+			rule.Targets = config.Settings.Targets
+		}
+		// regex_replace 的 ${name} 是捕获组引用，不继承 settings.interpolate
+		if rule.Interpolate == "" && rule.Action != engine.ActionRegexReplace {
+			rule.Interpolate = config.Settings.Interpolate
+		}
+		if _, ok := config.Params[rule.Capture]; ok {
+			return nil, fmt.Errorf("rule %d: capture %q conflicts with param of the same name", i, rule.Capture)
+		}
+		if err := CheckSunset(rule, time.Now()); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
 	}
 
-	return config.Rules, nil
+	return &config, nil
+}
+
+// ValidateGlobs 检查 include/exclude 的 glob 模式语法，路径分隔符统一为 / 后逐片段检查（** 除外）
+func ValidateGlobs(patterns []string) error {
+	for _, pattern := range patterns {
+		if pattern == "" {
+			return fmt.Errorf("invalid glob %q", pattern)
+		}
+		for _, part := range strings.Split(filepath.ToSlash(pattern), "/") {
+			if part == "**" {
+				continue
+			}
+			if _, err := filepath.Match(part, ""); err != nil {
+				return fmt.Errorf("invalid glob %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckVersion 检查规则文件要求的特性版本是否被当前工具支持
+func CheckVersion(required int) error {
+	if required < 0 {
+		return fmt.Errorf("invalid version %d", required)
+	}
+	if required > version.ConfigVersion {
+		return fmt.Errorf("rule file requires config version %d, but yamleditor %s supports up to %d; upgrade yamleditor",
+			required, version.Get().Version, version.ConfigVersion)
+	}
+	return nil
+}
+
+// ParseInline 解析命令行内联给出的规则，可以是完整配置、规则列表或单条规则
+func ParseInline(data []byte) (*Config, error) {
+	return parseInline(data, true)
+}
+
+// ParseInlineIsolated 同 ParseInline，但不允许 value_from 读取本机文件，用于解析来自请求等不可信来源的规则
+func ParseInlineIsolated(data []byte) (*Config, error) {
+	return parseInline(data, false)
+}
+
+func parseInline(data []byte, files bool) (*Config, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("unmarshal yaml: %w", err)
+	}
+	if len(node.Content) == 0 {
+		return nil, fmt.Errorf("no rules given")
+	}
+
+	body := node.Content[0]
+	switch {
+	case body.Kind == yaml.SequenceNode:
+	case body.Kind == yaml.MappingNode && hasKey(body, "action"):
+		body = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{body}}
+	default:
+		return parseConfig(data, "", files)
+	}
+
+	wrapped, err := yaml.Marshal(map[string]*yaml.Node{"rules": body})
+	if err != nil {
+		return nil, fmt.Errorf("marshal rules: %w", err)
+	}
+	return parseConfig(wrapped, "", files)
+}
+
+func hasKey(mapping *yaml.Node, key string) bool {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return true
+		}
+	}
+	return false
+}
+
+// anchorName YAML 锚点名不能包含空白和流式集合指示符
+var anchorName = regexp.MustCompile(`^[^\s,\[\]{}]+$`)
+
+// ruleID 规则 id 的写法，便于在日志与命令行中引用
+var ruleID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// captureName capture 变量名需能在模板中以 .Vars.name 访问
+var captureName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// sunsetLayout sunset 日期格式
+const sunsetLayout = "2006-01-02"
+
+// CheckSunset 检查规则是否已过 sunset 日期（当天仍可使用）
+func CheckSunset(rule *engine.Rule, now time.Time) error {
+	if rule.Sunset == "" {
+		return nil
+	}
+	sunset, err := time.Parse(sunsetLayout, rule.Sunset)
+	if err != nil {
+		return fmt.Errorf("invalid sunset %q, expected YYYY-MM-DD", rule.Sunset)
+	}
+	if now.Format(sunsetLayout) <= sunset.Format(sunsetLayout) {
+		return nil
+	}
+
+	msg := fmt.Sprintf("rule passed its sunset date %s and must be removed", rule.Sunset)
+	if rule.Deprecated != "" {
+		msg += ": " + rule.Deprecated
+	}
+	return fmt.Errorf("%s", msg)
 }
 
 // Validate 校验规则的合法性
 func Validate(rule *engine.Rule) error {
-	if rule.Path == "" {
+	if rule.Action == engine.ActionSetHeader {
+		return validateSetHeader(rule)
+	}
+	if rule.Path == "" && !rule.Action.PathOptional() {
 		return fmt.Errorf("path is required")
 	}
+	if rule.Path == "" && rule.Capture != "" {
+		return fmt.Errorf("capture requires a path")
+	}
+	if rule.Path != "" {
+		if _, err := path.Parse(rule.Path); err != nil {
+			return fmt.Errorf("invalid path: %w", err)
+		}
+	}
+
+	if rule.ValueFrom != nil {
+		// 加载规则文件时已读入 value；直接构造的规则不支持
+		return fmt.Errorf("value_from is only supported in rule files")
+	}
+
+	if rule.ID != "" && !ruleID.MatchString(rule.ID) {
+		return fmt.Errorf("invalid id %q, expected letters, digits, '.', '_' or '-'", rule.ID)
+	}
+
+	if rule.Capture != "" && !captureName.MatchString(rule.Capture) {
+		return fmt.Errorf("invalid capture name %q", rule.Capture)
+	}
+
+	if rule.Sunset != "" {
+		if _, err := time.Parse(sunsetLayout, rule.Sunset); err != nil {
+			return fmt.Errorf("invalid sunset %q, expected YYYY-MM-DD", rule.Sunset)
+		}
+	}
+
+	switch rule.Targets {
+	case "", engine.TargetsAnchorsOnly, engine.TargetsResolvedCopies, engine.TargetsError:
+	default:
+		return fmt.Errorf("unknown targets: %s", rule.Targets)
+	}
+
+	switch rule.Interpolate {
+	case "", engine.InterpolateNone:
+	case engine.InterpolateEnv:
+		if rule.Action == engine.ActionRegexReplace {
+			return fmt.Errorf("interpolate: env is not supported by regex_replace, ${name} is a capture group reference; use {{ .Env.NAME }}")
+		}
+	default:
+		return fmt.Errorf("unknown interpolate: %s, expected %s or %s", rule.Interpolate, engine.InterpolateNone, engine.InterpolateEnv)
+	}
+
+	if err := validateSelector(rule); err != nil {
+		return err
+	}
+	if err := validateWhen(rule); err != nil {
+		return err
+	}
+	if err := validateWhere(rule); err != nil {
+		return err
+	}
+	if err := ValidateGlobs(rule.Files); err != nil {
+		return fmt.Errorf("files: %w", err)
+	}
+
+	if rule.Style != "" {
+		switch rule.Action {
+		case engine.ActionReplace, engine.ActionAdd, engine.ActionAppend, engine.ActionPrepend, engine.ActionMerge:
+		default:
+			return fmt.Errorf("style only applies to replace, add, append, prepend and merge")
+		}
+		if !engine.ValidStyle(rule.Style) {
+			return fmt.Errorf("style must be %s, %s, %s, %s or %s", engine.StylePlain, engine.StyleSingle, engine.StyleDouble, engine.StyleLiteral, engine.StyleFolded)
+		}
+	}
+
+	if rule.Strategy != "" && rule.Action != engine.ActionMerge {
+		return fmt.Errorf("strategy only applies to merge")
+	}
+
+	if rule.RequireMatch && rule.Action != engine.ActionRegexReplace {
+		return fmt.Errorf("require_match only applies to regex_replace")
+	}
+
+	if rule.PruneEmpty && rule.Action != engine.ActionDelete {
+		return fmt.Errorf("prune_empty only applies to delete")
+	}
+
+	if rule.Coerce {
+		switch rule.Action {
+		case engine.ActionReplace, engine.ActionRegexReplace, engine.ActionMerge:
+		default:
+			return fmt.Errorf("coerce only applies to replace, regex_replace and merge")
+		}
+	}
+
+	if (rule.By != nil || rule.Min != nil || rule.Max != nil) && rule.Action != engine.ActionIncrement {
+		return fmt.Errorf("by/min/max only apply to increment")
+	}
+
+	if (rule.CreateMissing || rule.SkipMissing) && rule.Action != engine.ActionReplace {
+		return fmt.Errorf("create_missing/skip_missing only apply to replace")
+	}
+	if rule.CreateMissing && rule.SkipMissing {
+		return fmt.Errorf("create_missing and skip_missing are mutually exclusive")
+	}
 
 	switch rule.Action {
 	case engine.ActionReplace:
@@ -51,19 +376,231 @@ func Validate(rule *engine.Rule) error {
 		if rule.Pattern == "" {
 			return fmt.Errorf("pattern is required for regex_replace")
 		}
+		if err := validatePattern(rule.Pattern); err != nil {
+			return err
+		}
 		if rule.Value == nil {
 			return fmt.Errorf("value (replacement) is required for regex_replace")
 		}
-		if _, ok := rule.Value.(string); !ok {
+		replacement, ok := rule.Value.(string)
+		if !ok {
 			return fmt.Errorf("value must be string for regex_replace")
 		}
+		if err := engine.ValidateReplacement(replacement); err != nil {
+			return err
+		}
+		if rule.Count < 0 {
+			return fmt.Errorf("count must not be negative")
+		}
+
+	case engine.ActionAdd:
+		if rule.Value == nil {
+			return fmt.Errorf("value is required for action %s", rule.Action)
+		}
+		p, err := path.Parse(rule.Path)
+		if err != nil {
+			return fmt.Errorf("invalid path: %w", err)
+		}
+		if last := p.Segments[len(p.Segments)-1]; last.Type != path.SegmentTypeField {
+			return fmt.Errorf("add path must end with a field")
+		}
+
+	case engine.ActionAppend, engine.ActionPrepend:
+		if rule.Value == nil {
+			return fmt.Errorf("value is required for action %s", rule.Action)
+		}
+		if rule.InsertAt != nil && rule.Action != engine.ActionAppend {
+			return fmt.Errorf("insert_at only applies to append")
+		}
+		if rule.InsertAt != nil && *rule.InsertAt < 0 {
+			return fmt.Errorf("insert_at must not be negative")
+		}
 
 	case engine.ActionDelete:
 		// delete 不需要 value
 
+	case engine.ActionMerge:
+		if _, ok := rule.Value.(map[string]interface{}); !ok {
+			return fmt.Errorf("value must be a mapping for merge")
+		}
+		switch rule.Strategy {
+		case "", engine.MergeTheirs, engine.MergeOurs, engine.MergeError:
+		default:
+			return fmt.Errorf("strategy must be %s, %s or %s for merge", engine.MergeTheirs, engine.MergeOurs, engine.MergeError)
+		}
+
+	case engine.ActionRenameKey:
+		name, ok := rule.Value.(string)
+		if !ok || name == "" {
+			return fmt.Errorf("value (new key name) must be a non-empty string for rename_key")
+		}
+		p, err := path.Parse(rule.Path)
+		if err != nil {
+			return fmt.Errorf("invalid path: %w", err)
+		}
+		if last := p.Segments[len(p.Segments)-1]; last.Type != path.SegmentTypeField {
+			return fmt.Errorf("rename_key path must end with a field")
+		}
+
+	case engine.ActionRenameKeys:
+		if err := engine.ValidateKeyRenames(rule.Value); err != nil {
+			return err
+		}
+
+	case engine.ActionQuoteStyle:
+		switch rule.Value {
+		case engine.QuotePlain, engine.QuoteSingle, engine.QuoteDouble:
+		default:
+			return fmt.Errorf("value must be %s, %s or %s for quote_style", engine.QuotePlain, engine.QuoteSingle, engine.QuoteDouble)
+		}
+
+	case engine.ActionSortKeys, engine.ActionNormalize:
+		if rule.Value != nil {
+			return fmt.Errorf("%s does not take a value", rule.Action)
+		}
+
+	case engine.ActionIncrement:
+		if rule.Value != nil {
+			return fmt.Errorf("increment takes by, not value")
+		}
+		if rule.Min != nil && rule.Max != nil && *rule.Min > *rule.Max {
+			return fmt.Errorf("min must not be greater than max")
+		}
+
+	case engine.ActionTransform:
+		if err := engine.ValidateTransforms(rule.Value); err != nil {
+			return err
+		}
+
+	case engine.ActionEncrypt, engine.ActionDecrypt:
+		// 密钥由命令行提供，规则本身无额外参数
+
+	case engine.ActionSetAnchor:
+		if rule.Anchor == "" {
+			return fmt.Errorf("anchor is required for set_anchor")
+		}
+		if !anchorName.MatchString(rule.Anchor) {
+			return fmt.Errorf("invalid anchor name %q", rule.Anchor)
+		}
+
+	case engine.ActionDeduplicateAsAnchor:
+		if rule.Anchor != "" && !anchorName.MatchString(rule.Anchor) {
+			return fmt.Errorf("invalid anchor name %q", rule.Anchor)
+		}
+
+	case engine.ActionResolveAliases:
+		// 无额外参数
+
+	case engine.ActionRedact:
+		if rule.HashPrefix < 0 {
+			return fmt.Errorf("hash_prefix must not be negative")
+		}
+
+	case engine.ActionSetImageTag, engine.ActionSetImageRegistry, engine.ActionSetImageDigest:
+		if err := engine.ValidateImageValue(rule.Action, rule.Value); err != nil {
+			return err
+		}
+
 	default:
 		return fmt.Errorf("unknown action: %s", rule.Action)
 	}
 
 	return nil
 }
+
+// kindToMatch 把规则的 kind 改写为 match.kind：kind 是 match.kind 的简写，两者同时设置时报错
+func kindToMatch(rule *engine.Rule) error {
+	if rule.Kind == "" {
+		return nil
+	}
+	var match engine.Selector
+	if rule.Match != nil {
+		if rule.Match.Kind != "" {
+			return fmt.Errorf("kind and match.kind are both set, use match.kind")
+		}
+		match = *rule.Match
+	}
+	match.Kind = rule.Kind
+	rule.Match = &match
+	rule.Kind = ""
+	return nil
+}
+
+// validateSelector 校验 match 选择器
+func validateSelector(rule *engine.Rule) error {
+	m := rule.Match
+	if m == nil {
+		return nil
+	}
+	if *m == (engine.Selector{}) {
+		return fmt.Errorf("match must set at least one of kind, apiVersion, name, namespace")
+	}
+	if m.Kind != "" && rule.Kind != "" {
+		return fmt.Errorf("kind and match.kind are both set, use match.kind")
+	}
+	if m.Name != "" {
+		if _, err := engine.CompileSelectorPattern(m.Name); err != nil {
+			return fmt.Errorf("match.name: %w", err)
+		}
+	}
+	if m.Namespace != "" {
+		if _, err := engine.CompileSelectorPattern(m.Namespace); err != nil {
+			return fmt.Errorf("match.namespace: %w", err)
+		}
+	}
+	return nil
+}
+
+// validatePattern 编译 regex_replace 的 pattern；含 {{ }} 的 pattern 渲染后才能编译，执行时再检查
+func validatePattern(pattern string) error {
+	if strings.Contains(pattern, "{{") {
+		return nil
+	}
+	if _, err := regexp2.Compile(pattern, 0); err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
+	}
+	return nil
+}
+
+// validateWhen 校验 when 表达式的语法
+func validateWhen(rule *engine.Rule) error {
+	if rule.When == "" {
+		return nil
+	}
+	if _, err := engine.ParseWhen(rule.When); err != nil {
+		return fmt.Errorf("when: %w", err)
+	}
+	return nil
+}
+
+// validateWhere 校验 where 条件，规则路径中需有 [?] 使用它
+func validateWhere(rule *engine.Rule) error {
+	if rule.Where == nil {
+		return nil
+	}
+	if err := rule.Where.Validate(); err != nil {
+		return fmt.Errorf("where: %w", err)
+	}
+	if p, err := path.Parse(rule.Path); err == nil {
+		for _, seg := range p.Segments {
+			if seg.Selector != nil && seg.Selector.Type == path.SelectorTypeWhere {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("where requires a [?] selector in path")
+}
+
+// validateSetHeader 校验 set_header：作用于整个文件，只接受字符串 value
+func validateSetHeader(rule *engine.Rule) error {
+	if rule.Path != "" {
+		return fmt.Errorf("set_header applies to the whole file and takes no path")
+	}
+	if rule.Kind != "" || rule.Match != nil || rule.When != "" {
+		return fmt.Errorf("set_header does not support kind, match or when")
+	}
+	if _, ok := rule.Value.(string); !ok {
+		return fmt.Errorf("value must be string for set_header")
+	}
+	return nil
+}
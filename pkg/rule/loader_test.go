@@ -0,0 +1,70 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/glesirok/yamleditor/pkg/engine"
+)
+
+// kind 加载时改写为 match.kind，与 match 的其他字段、组的 match、kinds: 的类型一起合并；kind 与 match.kind 不能同时设置
+func TestKindToMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		want    engine.Selector
+		wantErr bool
+	}{
+		{
+			name:   "kind only",
+			config: "rules:\n- {action: delete, path: a, kind: Deployment}\n",
+			want:   engine.Selector{Kind: "Deployment"},
+		},
+		{
+			name:   "merged with match",
+			config: "rules:\n- {action: delete, path: a, kind: Deployment, match: {name: web}}\n",
+			want:   engine.Selector{Kind: "Deployment", Name: "web"},
+		},
+		{
+			name:   "merged with group match",
+			config: "groups:\n- name: prod\n  match: {namespace: prod}\n  rules:\n  - {action: delete, path: a, kind: Service}\n",
+			want:   engine.Selector{Kind: "Service", Namespace: "prod"},
+		},
+		{
+			name:    "conflicts with group match",
+			config:  "groups:\n- name: svc\n  match: {kind: Service}\n  rules:\n  - {action: delete, path: a, kind: Deployment}\n",
+			wantErr: true,
+		},
+		{
+			name:    "both set",
+			config:  "rules:\n- {action: delete, path: a, kind: Deployment, match: {kind: Deployment}}\n",
+			wantErr: true,
+		},
+		{
+			name:   "kinds section",
+			config: "kinds:\n  Pod:\n  - {action: delete, path: a, match: {name: web}}\n",
+			want:   engine.Selector{Kind: "Pod", Name: "web"},
+		},
+		{
+			name:    "kinds section conflict",
+			config:  "kinds:\n  Pod:\n  - {action: delete, path: a, kind: Deployment}\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		config, err := ParseConfig([]byte(tt.config))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		r := config.Rules[0]
+		if r.Kind != "" || r.Match == nil || *r.Match != tt.want {
+			t.Errorf("%s: kind = %q, match = %+v, want match %+v", tt.name, r.Kind, r.Match, tt.want)
+		}
+	}
+}
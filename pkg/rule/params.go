@@ -0,0 +1,42 @@
+package rule
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Param 规则文件顶部声明的参数
+// 参数值通过 --param name=value 提供，规则中以 {{ .Vars.name }} 引用
+type Param struct {
+	Required    bool   `yaml:"required,omitempty"`    // 必须提供
+	Default     string `yaml:"default,omitempty"`     // 未提供时的默认值
+	Description string `yaml:"description,omitempty"` // 说明，仅用于提示
+}
+
+// ResolveParams 按声明合并提供的参数值与默认值
+// 缺少必需参数时一次性列出所有缺失项；未声明的参数原样保留
+func ResolveParams(params map[string]Param, values map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(params)+len(values))
+	for name, v := range values {
+		resolved[name] = v
+	}
+
+	var missing []string
+	for name, p := range params {
+		if _, ok := resolved[name]; ok {
+			continue
+		}
+		if p.Required {
+			missing = append(missing, name)
+			continue
+		}
+		resolved[name] = p.Default
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing required params: %s", strings.Join(missing, ", "))
+	}
+	return resolved, nil
+}
@@ -0,0 +1,119 @@
+package rule
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/engine"
+)
+
+// resolveValuesFrom 读取 rules、kinds 与 groups 中所有规则的 value_from，
+// 相对路径相对 dir（规则文件所在目录，空串为当前目录）
+// 同一文件只读取一次；展开后规则的 value 为文件内容，value_from 清空。allowed 为 false 时出现 value_from 即报错
+func resolveValuesFrom(config *Config, dir string, allowed bool) error {
+	files := map[string]interface{}{}
+	resolve := func(rules []*engine.Rule, label string) error {
+		for i, r := range rules {
+			if r != nil && r.ValueFrom != nil && !allowed {
+				return fmt.Errorf("%srule %d: value_from is not allowed here", label, i)
+			}
+			if err := resolveValueFrom(r, dir, files); err != nil {
+				return fmt.Errorf("%srule %d: %w", label, i, err)
+			}
+		}
+		return nil
+	}
+
+	if err := resolve(config.Rules, ""); err != nil {
+		return err
+	}
+	for kind, rules := range config.Kinds {
+		if err := resolve(rules, "kinds."+kind+" "); err != nil {
+			return err
+		}
+	}
+	for i, g := range config.Groups {
+		if g == nil {
+			continue
+		}
+		label := fmt.Sprintf("groups[%d] ", i)
+		if g.Name != "" {
+			label = "groups." + g.Name + " "
+		}
+		if err := resolve(g.Rules, label); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// valueFromActions 支持 value_from 的操作
+var valueFromActions = map[engine.ActionType]bool{
+	engine.ActionReplace: true,
+	engine.ActionAdd:     true,
+	engine.ActionAppend:  true,
+	engine.ActionPrepend: true,
+	engine.ActionMerge:   true,
+}
+
+// resolveValueFrom 把 value_from.file 的内容读入 value，files 缓存已读取的文件（可为 nil）
+func resolveValueFrom(r *engine.Rule, dir string, files map[string]interface{}) error {
+	if r == nil || r.ValueFrom == nil {
+		return nil
+	}
+	switch {
+	case r.Value != nil:
+		return fmt.Errorf("value and value_from are mutually exclusive")
+	case r.ValueFrom.File == "":
+		return fmt.Errorf("value_from.file is required")
+	case !valueFromActions[r.Action]:
+		return fmt.Errorf("value_from only applies to replace, add, append, prepend and merge")
+	}
+
+	name := r.ValueFrom.File
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(dir, name)
+	}
+	value, ok := files[name]
+	if !ok {
+		var err error
+		if value, err = readValueFile(name); err != nil {
+			return fmt.Errorf("value_from: %w", err)
+		}
+		if files != nil {
+			files[name] = value
+		}
+	}
+	r.Value, r.ValueFrom = value, nil
+	return nil
+}
+
+// readValueFile 读取只含一个 YAML（或 JSON）文档的文件
+func readValueFile(name string) (interface{}, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s is empty", name)
+		}
+		return nil, fmt.Errorf("parse %s: %w", name, err)
+	}
+	var extra interface{}
+	if err := dec.Decode(&extra); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s must contain a single YAML document", name)
+	}
+	if value == nil {
+		return nil, fmt.Errorf("%s is empty", name)
+	}
+	return value, nil
+}
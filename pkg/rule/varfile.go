@@ -0,0 +1,105 @@
+package rule
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadVarFile 从 YAML 文件加载参数值
+// 文件顶层须为 mapping，值须为标量
+func LoadVarFile(filePath string) (map[string]string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("unmarshal yaml: %w", err)
+	}
+
+	values := map[string]string{}
+	if len(doc.Content) == 0 {
+		return values, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("var file must be a mapping")
+	}
+	for i := 0; i < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if value.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: value of %q must be a scalar", value.Line, key.Value)
+		}
+		if value.ShortTag() == "!!null" {
+			values[key.Value] = ""
+			continue
+		}
+		values[key.Value] = value.Value
+	}
+	return values, nil
+}
+
+// LoadEnvFile 从 .env 文件加载参数值
+// 支持 KEY=VALUE、export 前缀、# 注释以及单/双引号包裹的值（双引号内支持转义）
+func LoadEnvFile(filePath string) (map[string]string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNo)
+		}
+
+		value, err := envValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+	return values, nil
+}
+
+// envValue 解析 .env 中的值：去掉引号，未加引号时去掉行尾 # 注释
+func envValue(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, `"`):
+		end := strings.LastIndex(v, `"`)
+		if end == 0 {
+			return "", fmt.Errorf("unterminated quote")
+		}
+		return strconv.Unquote(v[:end+1])
+	case strings.HasPrefix(v, "'"):
+		end := strings.LastIndex(v, "'")
+		if end == 0 {
+			return "", fmt.Errorf("unterminated quote")
+		}
+		return v[1:end], nil
+	}
+
+	if i := strings.Index(v, " #"); i != -1 {
+		v = strings.TrimSpace(v[:i])
+	}
+	return v, nil
+}
@@ -0,0 +1,114 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const widgetCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+  versions:
+    - name: v1
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              properties:
+                replicas:
+                  type: integer
+                port:
+                  x-kubernetes-int-or-string: true
+                config:
+                  type: object
+                  x-kubernetes-preserve-unknown-fields: true
+                labels:
+                  type: object
+                  additionalProperties:
+                    type: string
+    - name: v1alpha1
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: not-a-crd
+`
+
+// CRD 清单中各版本的 openAPIV3Schema 按 group/version 与 kind 登记，
+// x-kubernetes-preserve-unknown-fields 下的字段不检查，根上的 apiVersion、kind、metadata 总是允许
+func TestLoadDirCRD(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "widgets.yaml"), []byte(widgetCRD), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		doc       string
+		want      []string
+		wantKnown bool
+	}{
+		{"valid", "apiVersion: example.com/v1\nkind: Widget\nmetadata: {name: a}\nspec: {replicas: 3, port: http}\n", nil, true},
+		{"wrong type", "apiVersion: example.com/v1\nkind: Widget\nspec: {replicas: \"3\"}\n", []string{`spec.replicas: expected integer, got string "3"`}, true},
+		{"unknown field", "apiVersion: example.com/v1\nkind: Widget\nspec: {replica: 3}\n", []string{"spec.replica: unknown field"}, true},
+		{"preserve unknown fields", "apiVersion: example.com/v1\nkind: Widget\nspec: {config: {any: {thing: 1}}}\n", nil, true},
+		{"additionalProperties", "apiVersion: example.com/v1\nkind: Widget\nspec: {labels: {app: 1}}\n", []string{`spec.labels.app: expected string, got integer "1"`}, true},
+		{"version without schema", "apiVersion: example.com/v1alpha1\nkind: Widget\nspec: {replica: 3}\n", nil, false},
+		{"other group", "apiVersion: other.com/v1\nkind: Widget\n", nil, false},
+	}
+	for _, tt := range tests {
+		var root yaml.Node
+		if err := yaml.Unmarshal([]byte(tt.doc), &root); err != nil {
+			t.Fatal(err)
+		}
+		got, known := s.Validate(&root)
+		if known != tt.wantKnown || !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Validate = %q, %v, want %q, %v", tt.name, got, known, tt.want, tt.wantKnown)
+		}
+	}
+}
+
+// kubectl get crd -o json 输出的 List 与 OpenAPI 文件放在同一目录
+func TestLoadDirCRDList(t *testing.T) {
+	dir := t.TempDir()
+	list := `{"apiVersion": "v1", "kind": "List", "items": [{"kind": "CustomResourceDefinition", "spec": {"group": "example.com", "names": {"kind": "Gadget"},
+  "versions": [{"name": "v1", "schema": {"openAPIV3Schema": {"type": "object", "properties": {"spec": {"type": "object", "properties": {"size": {"type": "integer"}}}}}}}]}}]}`
+	if err := os.WriteFile(filepath.Join(dir, "crds.json"), []byte(list), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "swagger.json"), builtin, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, doc := range []string{
+		"apiVersion: example.com/v1\nkind: Gadget\nspec: {size: big}\n",
+		"apiVersion: apps/v1\nkind: Deployment\nspec: {replicas: many}\n",
+	} {
+		var root yaml.Node
+		if err := yaml.Unmarshal([]byte(doc), &root); err != nil {
+			t.Fatal(err)
+		}
+		if got, known := s.Validate(&root); !known || len(got) != 1 {
+			t.Errorf("Validate(%q) = %q, %v, want one problem", doc, got, known)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package schema
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"github.com/glesirok/yamleditor/pkg/path"
+)
+
+// builtin 内置的 Kubernetes OpenAPI v2 定义，只含常用类型（工作负载、Service、ConfigMap、Secret 等）的常用字段，
+// 较少改动的子结构（affinity、probe、securityContext 等）不展开检查
+//
+//go:embed builtin.json
+var builtin []byte
+
+// Schema 按文档的 apiVersion 与 kind 查找 OpenAPI 定义并校验文档
+type Schema struct {
+	defs map[string]*definition // 定义名 → 定义
+	gvk  map[string]string      // "apiVersion kind" → 定义名
+	sum  hash.Hash              // 按加载顺序累计的定义文件内容摘要
+}
+
+// definition OpenAPI v2 / v3 中校验用到的部分
+type definition struct {
+	Type        string                 `json:"type"`
+	Format      string                 `json:"format"`
+	Ref         string                 `json:"$ref"`
+	AllOf       []*definition          `json:"allOf"`
+	Properties  map[string]*definition `json:"properties"`
+	Items       *definition            `json:"items"`
+	Additional  *additional            `json:"additionalProperties"`
+	IntOrString bool                   `json:"x-kubernetes-int-or-string"`
+	Preserve    bool                   `json:"x-kubernetes-preserve-unknown-fields"`
+	GVK         []struct {
+		Group   string `json:"group"`
+		Version string `json:"version"`
+		Kind    string `json:"kind"`
+	} `json:"x-kubernetes-group-version-kind"`
+}
+
+// additional additionalProperties，可以是布尔值或值的定义
+type additional struct {
+	allowed bool
+	schema  *definition
+}
+
+func (a *additional) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.allowed); err == nil {
+		return nil
+	}
+	a.allowed = true
+	return json.Unmarshal(data, &a.schema)
+}
+
+// document 一个 OpenAPI 文件：v2 的定义在 definitions，v3 在 components.schemas
+type document struct {
+	Definitions map[string]*definition `json:"definitions"`
+	Components  struct {
+		Schemas map[string]*definition `json:"schemas"`
+	} `json:"components"`
+}
+
+// Builtin 返回内置的定义
+func Builtin() (*Schema, error) {
+	s := newSchema()
+	if err := s.add(builtin); err != nil {
+		return nil, fmt.Errorf("builtin schema: %w", err)
+	}
+	return s, nil
+}
+
+// LoadDir 加载目录（含子目录）中所有 .json 文件里的定义，支持 OpenAPI v2
+// （kubectl get --raw /openapi/v2）与 v3（kubectl get --raw /openapi/v3/apis/apps/v1 等），
+// 以及 .yaml/.yml/.json 文件中的 CustomResourceDefinition 清单（kubectl get crd -o yaml）
+func LoadDir(dir string) (*Schema, error) {
+	s := newSchema()
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(p))
+		if ext != ".json" && ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("read schema: %w", err)
+		}
+		if ext == ".json" && !isCRD(data) {
+			err = s.add(data)
+		} else {
+			err = s.addCRDs(data)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(s.gvk) == 0 {
+		return nil, fmt.Errorf("no Kubernetes kinds found in %s", dir)
+	}
+	return s, nil
+}
+
+func newSchema() *Schema {
+	return &Schema{defs: map[string]*definition{}, gvk: map[string]string{}, sum: sha256.New()}
+}
+
+// Digest 返回加载的全部定义文件内容的 sha256 十六进制，定义变化时随之变化
+func (s *Schema) Digest() string {
+	return hex.EncodeToString(s.sum.Sum(nil))
+}
+
+// add 解析一个 OpenAPI 文件并登记其中的定义
+func (s *Schema) add(data []byte) error {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parse openapi: %w", err)
+	}
+	s.sum.Write(data)
+	for _, defs := range []map[string]*definition{doc.Definitions, doc.Components.Schemas} {
+		for name, def := range defs {
+			s.defs[name] = def
+			for _, k := range def.GVK {
+				s.gvk[apiVersion(k.Group, k.Version)+" "+k.Kind] = name
+			}
+		}
+	}
+	return nil
+}
+
+// crd CustomResourceDefinition（apiextensions.k8s.io/v1）中用到的部分
+type crd struct {
+	Kind  string `yaml:"kind"`
+	Items []crd  `yaml:"items"`
+	Spec  struct {
+		Group string `yaml:"group"`
+		Names struct {
+			Kind string `yaml:"kind"`
+		} `yaml:"names"`
+		Versions []struct {
+			Name   string `yaml:"name"`
+			Schema struct {
+				OpenAPIV3Schema map[string]any `yaml:"openAPIV3Schema"`
+			} `yaml:"schema"`
+		} `yaml:"versions"`
+	} `yaml:"spec"`
+}
+
+// isCRD 判断 JSON 文件是否是 CRD 清单（或 kubectl get crd -o json 的 List）而不是 OpenAPI 文件
+func isCRD(data []byte) bool {
+	var head struct {
+		Kind string `json:"kind"`
+	}
+	return json.Unmarshal(data, &head) == nil && (head.Kind == "CustomResourceDefinition" || head.Kind == "List")
+}
+
+// addCRDs 登记文件中每个 CRD 各版本的 openAPIV3Schema，其他文档忽略；
+// 定义名为 "group/version kind"，与 OpenAPI 文件中的定义名不会冲突
+func (s *Schema) addCRDs(data []byte) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc crd
+		if err := dec.Decode(&doc); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("parse crd: %w", err)
+		}
+		for _, c := range append([]crd{doc}, doc.Items...) {
+			if err := s.addCRD(c); err != nil {
+				return err
+			}
+		}
+	}
+	s.sum.Write(data)
+	return nil
+}
+
+// addCRD 登记一个 CRD；没有 schema 的版本不登记，这些版本的文档不检查
+func (s *Schema) addCRD(c crd) error {
+	if c.Kind != "CustomResourceDefinition" {
+		return nil
+	}
+	for _, v := range c.Spec.Versions {
+		if v.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+		raw, err := json.Marshal(v.Schema.OpenAPIV3Schema)
+		if err != nil {
+			return fmt.Errorf("crd %s.%s: %w", c.Spec.Names.Kind, c.Spec.Group, err)
+		}
+		def := &definition{}
+		if err := json.Unmarshal(raw, def); err != nil {
+			return fmt.Errorf("crd %s.%s: %w", c.Spec.Names.Kind, c.Spec.Group, err)
+		}
+		// apiserver 总是接受 apiVersion、kind 与 metadata，CRD 的 schema 通常不写出它们
+		if len(def.Properties) > 0 {
+			for name, typ := range map[string]string{"apiVersion": "string", "kind": "string", "metadata": "object"} {
+				if _, ok := def.Properties[name]; !ok {
+					def.Properties[name] = &definition{Type: typ}
+				}
+			}
+		}
+		key := apiVersion(c.Spec.Group, v.Name) + " " + c.Spec.Names.Kind
+		s.defs[key] = def
+		s.gvk[key] = key
+	}
+	return nil
+}
+
+// apiVersion 由 group 与 version 组成文档中的 apiVersion，core 组只有 version
+func apiVersion(group, version string) string {
+	if group == "" {
+		return version
+	}
+	return group + "/" + version
+}
+
+// Validate 校验文档，返回发现的问题（"具体路径: 原因"，按路径排序）；
+// 文档没有 apiVersion/kind 或定义中没有该类型时 known 为 false，不做检查
+func (s *Schema) Validate(root *yaml.Node) (problems []string, known bool) {
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, false
+	}
+	name, ok := s.gvk[scalarField(root, "apiVersion")+" "+scalarField(root, "kind")]
+	if !ok {
+		return nil, false
+	}
+
+	v := &validator{schema: s}
+	v.check(root, s.defs[name], "")
+	sort.Strings(v.problems)
+	return v.problems, true
+}
+
+// scalarField 返回 mapping 中标量字段的值
+func scalarField(mapping *yaml.Node, key string) string {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key && mapping.Content[i+1].Kind == yaml.ScalarNode {
+			return mapping.Content[i+1].Value
+		}
+	}
+	return ""
+}
+
+// validator 单个文档的校验状态
+type validator struct {
+	schema   *Schema
+	problems []string
+}
+
+func (v *validator) addf(p, format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf("%s: %s", cmpPath(p), fmt.Sprintf(format, args...)))
+}
+
+// cmpPath 文档根的路径显示为 <root>
+func cmpPath(p string) string {
+	if p == "" {
+		return "<root>"
+	}
+	return p
+}
+
+// resolve 展开 $ref 与只有一项的 allOf（v3 用 allOf 包裹引用）
+func (v *validator) resolve(def *definition) *definition {
+	for depth := 0; def != nil && depth < 32; depth++ {
+		switch {
+		case def.Ref != "":
+			name := def.Ref[strings.LastIndex(def.Ref, "/")+1:]
+			def = v.schema.defs[name]
+		case len(def.AllOf) == 1 && def.Type == "" && len(def.Properties) == 0:
+			def = def.AllOf[0]
+		default:
+			return def
+		}
+	}
+	return def
+}
+
+// check 按定义检查节点；没有定义的位置不检查，null 在任何位置都允许
+func (v *validator) check(node *yaml.Node, def *definition, p string) {
+	def = v.resolve(def)
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	if def == nil || (node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null") {
+		return
+	}
+	if def.IntOrString || def.Format == "int-or-string" {
+		if tag := node.ShortTag(); node.Kind != yaml.ScalarNode || (tag != "!!int" && tag != "!!str") {
+			v.addf(p, "expected integer or string, got %s", typeName(node))
+		}
+		return
+	}
+
+	typ := def.Type
+	if typ == "" && len(def.Properties) > 0 {
+		typ = "object"
+	}
+	switch typ {
+	case "object":
+		if node.Kind != yaml.MappingNode {
+			v.addf(p, "expected object, got %s", typeName(node))
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.ShortTag() == "!!merge" {
+				continue
+			}
+			switch prop, ok := def.Properties[key.Value]; {
+			case ok:
+				v.check(value, prop, path.FieldPath(p, key.Value))
+			case def.Additional != nil && def.Additional.schema != nil:
+				v.check(value, def.Additional.schema, path.FieldPath(p, key.Value))
+			case def.Preserve || len(def.Properties) == 0 || (def.Additional != nil && def.Additional.allowed):
+				// 自由结构
+			default:
+				v.addf(path.FieldPath(p, key.Value), "unknown field")
+			}
+		}
+	case "array":
+		if node.Kind != yaml.SequenceNode {
+			v.addf(p, "expected array, got %s", typeName(node))
+			return
+		}
+		for i, item := range node.Content {
+			v.check(item, def.Items, path.ElemPath(p, i))
+		}
+	case "string":
+		// 未加引号的日期解析为 !!timestamp，序列化为 JSON 时仍是字符串
+		if tag := node.ShortTag(); node.Kind != yaml.ScalarNode || (tag != "!!str" && tag != "!!timestamp" && tag != "!!binary") {
+			v.addf(p, "expected string, got %s", typeName(node))
+		}
+	case "integer":
+		if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!int" {
+			v.addf(p, "expected integer, got %s", typeName(node))
+		}
+	case "number":
+		if tag := node.ShortTag(); node.Kind != yaml.ScalarNode || (tag != "!!int" && tag != "!!float") {
+			v.addf(p, "expected number, got %s", typeName(node))
+		}
+	case "boolean":
+		if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!bool" {
+			v.addf(p, "expected boolean, got %s", typeName(node))
+		}
+	}
+}
+
+// typeName 返回节点在报错信息中的类型名，标量带上原值
+func typeName(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	names := map[string]string{"!!str": "string", "!!int": "integer", "!!float": "number", "!!bool": "boolean", "!!timestamp": "timestamp"}
+	name, ok := names[node.ShortTag()]
+	if !ok {
+		name = strings.TrimPrefix(node.ShortTag(), "!!")
+	}
+	return fmt.Sprintf("%s %q", name, node.Value)
+}
@@ -0,0 +1,44 @@
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version 工具版本，发布构建时写入：
+//
+//	go build -ldflags "-X github.com/glesirok/yamleditor/pkg/version.Version=v1.2.3" ./cmd/yamleditor
+//
+// 未写入时使用 go install 记录的模块版本，仍无法确定时为 dev
+var Version = "dev"
+
+// ConfigVersion 本工具支持的规则文件特性版本
+// 新增规则字段、操作类型或路径语法时递增；规则文件的 version 大于该值时拒绝执行，
+// 避免旧版本静默忽略不认识的字段
+const ConfigVersion = 20
+
+// Info 版本信息，用于 yamleditor version
+type Info struct {
+	Version       string `json:"version"`
+	ConfigVersion int    `json:"config_version"`
+	Commit        string `json:"commit,omitempty"` // 构建时的 VCS 修订，未知时为空
+	GoVersion     string `json:"go_version"`
+}
+
+// Get 返回当前二进制的版本信息
+func Get() Info {
+	info := Info{Version: Version, ConfigVersion: ConfigVersion, GoVersion: runtime.Version()}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "dev" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+		info.Version = build.Main.Version
+	}
+	for _, s := range build.Settings {
+		if s.Key == "vcs.revision" {
+			info.Commit = s.Value
+		}
+	}
+	return info
+}